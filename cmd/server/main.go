@@ -8,12 +8,14 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/dnakolan/trail-data-service/internal/config"
 	"github.com/dnakolan/trail-data-service/internal/handlers"
 	"github.com/dnakolan/trail-data-service/internal/middleware"
+	"github.com/dnakolan/trail-data-service/internal/models"
 	"github.com/dnakolan/trail-data-service/internal/services"
 	"github.com/dnakolan/trail-data-service/internal/storage"
 	"github.com/gin-gonic/gin"
@@ -27,23 +29,90 @@ func main() {
 
 	router := gin.Default()
 	gin.SetMode(cfg.Server.GinMode)
+	// Client IPs are only read from X-Forwarded-For when the request came through a trusted proxy,
+	// otherwise anyone could dodge the rate limits by making up the header
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("error: invalid trusted proxies: %v", err)
+	}
+
+	stores, err := newStorage(cfg.Storage)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	defer stores.close()
+
+	rater, err := newDifficultyRater(cfg.Difficulty)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+
+	keys, err := services.NewKeySet(cfg.JWT)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
 
-	trailsStorage := storage.NewTrailStorage()
+	trailsService := services.NewTrailsService(stores.trails, rater, cfg.Duplicates)
+	loginService := services.NewLoginService(stores.users, stores.tokens, cfg.Auth, cfg.JWT, keys)
+	apiKeyService := services.NewAPIKeyService(stores.apiKeys, stores.users)
 
-	trailsService := services.NewTrailsService(trailsStorage)
-	loginService := services.NewLoginService()
+	if username, password := os.Getenv("ADMIN_USERNAME"), os.Getenv("ADMIN_PASSWORD"); username != "" && password != "" {
+		if err := loginService.EnsureAdmin(context.Background(), username, password); err != nil {
+			log.Fatalf("error: failed to create admin %q: %v", username, err)
+		}
+	}
 
 	healthHandler := handlers.NewHealthHandler()
 	trailsHandler := handlers.NewTrailsHandler(trailsService)
 	loginHandler := handlers.NewLoginHandler(loginService)
+	jwksHandler := handlers.NewJWKSHandler(keys)
+	apiKeysHandler := handlers.NewAPIKeysHandler(apiKeyService)
+
+	// Rate limits are kept in memory, so each replica counts requests separately
+	limiter := storage.NewRateLimiter()
+	// Counted by IP ahead of authentication, the per client limits below count after it
+	authLimit := middleware.RateLimitByIP(limiter, "auth", cfg.RateLimit.Auth)
+	loginLimit := middleware.RateLimit(limiter, "login", cfg.RateLimit.Login)
+	readLimit := middleware.RateLimit(limiter, "reads", cfg.RateLimit.Reads)
+	writeLimit := middleware.RateLimit(limiter, "writes", cfg.RateLimit.Writes)
 
-	router.POST("/login", loginHandler.LoginHandler)
+	authMiddleware := middleware.JwtAuthMiddleware(cfg.JWT, keys, loginService, apiKeyService)
+	writers := []gin.HandlerFunc{authLimit, authMiddleware, middleware.RequireRole(models.RoleContributor), writeLimit}
+	readers := []gin.HandlerFunc{authLimit, authMiddleware, middleware.RequireRole(models.RoleViewer), readLimit}
+	if cfg.Auth.PublicReads {
+		readers = []gin.HandlerFunc{readLimit}
+	}
+
+	router.POST("/login", loginLimit, loginHandler.LoginHandler)
+	router.POST("/register", loginLimit, loginHandler.RegisterHandler)
+	router.POST("/token/refresh", loginLimit, loginHandler.RefreshHandler)
+	router.POST("/logout", authLimit, authMiddleware, writeLimit, loginHandler.LogoutHandler)
 	router.GET("/health", healthHandler.GetHealthHandler)
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKSHandler)
+	if cfg.OIDC.Enabled() {
+		oidcService := services.NewOIDCService(cfg.OIDC, loginService, nil)
+		oidcHandler := handlers.NewOIDCHandler(oidcService, strings.HasPrefix(cfg.OIDC.RedirectURL, "https://"))
+		router.GET("/oidc/login", loginLimit, oidcHandler.LoginHandler)
+		router.GET("/oidc/callback", loginLimit, oidcHandler.CallbackHandler)
+	}
+
+	router.PUT("/users/:username/role", authLimit, authMiddleware, middleware.RequireRole(models.RoleAdmin), writeLimit, loginHandler.SetRoleHandler)
+	router.POST("/api-keys", authLimit, authMiddleware, writeLimit, apiKeysHandler.CreateAPIKeyHandler)
+	router.GET("/api-keys", authLimit, authMiddleware, readLimit, apiKeysHandler.ListAPIKeysHandler)
+	router.DELETE("/api-keys/:id", authLimit, authMiddleware, writeLimit, apiKeysHandler.RevokeAPIKeyHandler)
 
-	router.POST("/trails", middleware.JwtAuthMiddleware(), trailsHandler.CreateTrailHandler)
-	router.GET("/trails/:uid", middleware.JwtAuthMiddleware(), trailsHandler.GetTrailsHandler)
-	router.GET("/trails", middleware.JwtAuthMiddleware(), trailsHandler.ListTrailsHandler)
-	router.GET("/trails/nearby", middleware.JwtAuthMiddleware(), trailsHandler.ListTrailsHandler)
+	router.POST("/trails", append(writers, trailsHandler.CreateTrailHandler)...)
+	router.POST("/trails/import/gpx", append(writers, trailsHandler.ImportGPXHandler)...)
+	router.POST("/trails/search", append(readers, trailsHandler.SearchTrailsHandler)...)
+	router.GET("/trails/suggest", append(readers, trailsHandler.SuggestTrailsHandler)...)
+	router.GET("/trails/:uid", append(readers, trailsHandler.GetTrailsHandler)...)
+	router.GET("/trails/:uid/elevation-profile", append(readers, trailsHandler.ElevationProfileHandler)...)
+	router.GET("/trails/:uid/export", append(readers, trailsHandler.ExportTrailHandler)...)
+	router.PUT("/trails/:uid", append(writers, trailsHandler.UpdateTrailHandler)...)
+	router.PATCH("/trails/:uid", append(writers, trailsHandler.PatchTrailHandler)...)
+	router.DELETE("/trails/:uid", append(writers, trailsHandler.DeleteTrailHandler)...)
+	router.GET("/trails", append(readers, trailsHandler.ListTrailsHandler)...)
+	router.GET("/trails/nearby", append(readers, trailsHandler.ListTrailsHandler)...)
+	router.GET("/me/trails", authLimit, authMiddleware, middleware.RequireRole(models.RoleContributor), readLimit, trailsHandler.ListMyTrailsHandler)
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%s", cfg.Server.Port),
@@ -69,6 +138,58 @@ func main() {
 		os.Exit(1)
 	}
 	slog.Info("Server exited properly")
+}
+
+type stores struct {
+	trails  storage.TrailStorage
+	users   storage.UserStorage
+	tokens  storage.TokenStorage
+	apiKeys storage.APIKeyStorage
+	close   func()
+}
 
-	os.Exit(0)
+func newStorage(cfg config.StorageConfig) (*stores, error) {
+	switch cfg.Driver {
+	case "", "memory":
+		return &stores{
+			trails:  storage.NewTrailStorage(),
+			users:   storage.NewUserStorage(),
+			tokens:  storage.NewTokenStorage(),
+			apiKeys: storage.NewAPIKeyStorage(),
+			close:   func() {},
+		}, nil
+	case "sqlite":
+		db, err := storage.OpenSQLite(cfg.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sqlite storage: %w", err)
+		}
+		return &stores{
+			trails:  storage.NewSQLiteTrailStorage(db),
+			users:   storage.NewSQLiteUserStorage(db),
+			tokens:  storage.NewSQLiteTokenStorage(db),
+			apiKeys: storage.NewSQLiteAPIKeyStorage(db),
+			close: func() {
+				if err := db.Close(); err != nil {
+					slog.Error("failed to close sqlite storage", "error", err)
+				}
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+func newDifficultyRater(cfg config.DifficultyConfig) (models.DifficultyRater, error) {
+	mediumScore, hardScore := cfg.MediumScore, cfg.HardScore
+	if mediumScore == 0 {
+		mediumScore = models.DefaultMediumDifficultyScore
+	}
+	if hardScore == 0 {
+		hardScore = models.DefaultHardDifficultyScore
+	}
+	rater, err := models.NewShenandoahRater(mediumScore, hardScore)
+	if err != nil {
+		return nil, fmt.Errorf("invalid difficulty config: %w", err)
+	}
+	return rater, nil
 }
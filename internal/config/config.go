@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"time"
 
@@ -8,23 +10,132 @@ import (
 )
 
 const (
-	SECRET_KEY            = "your-secret-key"
-	TOKEN_EXPIRATION_TIME = 1 * time.Hour
-	TOKEN_ISSUER          = "trail-data-service"
-	TOKEN_SUBJECT         = "user-auth"
+	// DEV_SECRET_KEY is only accepted in debug mode, anywhere else a real secret must be configured
+	DEV_SECRET_KEY                = "your-secret-key"
+	TOKEN_EXPIRATION_TIME         = 1 * time.Hour
+	REFRESH_TOKEN_EXPIRATION_TIME = 30 * 24 * time.Hour
+	TOKEN_ISSUER                  = "trail-data-service"
+	TOKEN_SUBJECT                 = "user-auth"
+	// DEFAULT_ROLE only lets newly registered accounts read, as anyone can register
+	DEFAULT_ROLE = "viewer"
 
 	DUPLICATE_TRAIL_RADIUS_KM = 25.0
+	DUPLICATE_TRAIL_THRESHOLD = 0.75
+
+	OIDC_USERNAME_CLAIM = "preferred_username"
+	OIDC_ROLES_CLAIM    = "roles"
 )
 
 var readFile = os.ReadFile
 
 type Config struct {
-	Server ServerConfig `yaml:"server"`
+	Server     ServerConfig     `yaml:"server"`
+	Storage    StorageConfig    `yaml:"storage"`
+	Difficulty DifficultyConfig `yaml:"difficulty"`
+	Auth       AuthConfig       `yaml:"auth"`
+	JWT        JWTConfig        `yaml:"jwt"`
+	OIDC       OIDCConfig       `yaml:"oidc"`
+	RateLimit  RateLimitConfig  `yaml:"rate_limit"`
+	Duplicates DuplicateConfig  `yaml:"duplicates"`
 }
 
 type ServerConfig struct {
 	Port    string `yaml:"port"`
 	GinMode string `yaml:"gin_mode"`
+	// TrustedProxies are the addresses or CIDR ranges of proxies whose X-Forwarded-For header is believed
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type StorageConfig struct {
+	// Driver selects the trail storage backend, either "memory" or "sqlite"
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+}
+
+type DifficultyConfig struct {
+	// MediumScore is the lowest score rated medium, anything below it is easy
+	MediumScore float64 `yaml:"medium_score"`
+	HardScore   float64 `yaml:"hard_score"`
+}
+
+type AuthConfig struct {
+	// MaxFailedLogins is how many wrong passwords in a row lock an account, 0 disables lockout. Failures are
+	// counted per account from any address, so anyone who knows a username can keep it locked out. This is
+	// accepted to stop guessing spread over many addresses, and the login rate limit slows down both.
+	MaxFailedLogins int           `yaml:"max_failed_logins"`
+	LockoutDuration time.Duration `yaml:"lockout_duration"`
+	DefaultRole     string        `yaml:"default_role"`
+	PublicReads     bool          `yaml:"public_reads"`
+}
+
+// JWTConfig can be overridden with JWT_SECRET, JWT_EXPIRATION, JWT_REFRESH_EXPIRATION and JWT_ISSUER
+type JWTConfig struct {
+	// Secret signs tokens with HS256 when no keys are configured. Alongside keys it is only
+	// used to verify HS256 tokens issued before switching over.
+	Secret            string         `yaml:"secret"`
+	Expiration        time.Duration  `yaml:"expiration"`
+	RefreshExpiration time.Duration  `yaml:"refresh_expiration"`
+	Issuer            string         `yaml:"issuer"`
+	Keys              []JWTKeyConfig `yaml:"keys"`
+	// SigningKeyID defaults to the first key with a private key
+	SigningKeyID string `yaml:"signing_key_id"`
+}
+
+// JWTKeyConfig only needs the public key for keys being rotated out
+type JWTKeyConfig struct {
+	ID             string `yaml:"id"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
+// OIDCConfig is enabled by setting an issuer URL, the client secret can be set with OIDC_CLIENT_SECRET
+type OIDCConfig struct {
+	IssuerURL    string   `yaml:"issuer_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+	// UsernameClaim only names new accounts, accounts are matched by issuer and subject
+	UsernameClaim string `yaml:"username_claim"`
+	// RolesClaim can hold a string or a list of strings
+	RolesClaim string `yaml:"roles_claim"`
+	// RoleMapping needs no entry for names that are already role names
+	RoleMapping map[string]string `yaml:"role_mapping"`
+}
+
+type DuplicateConfig struct {
+	RadiusKm float64 `yaml:"radius_km"`
+	// Threshold is the score from 0 to 1 at which a trail is a duplicate, trails with the same name within
+	// RadiusKm are duplicates whatever they score
+	Threshold float64 `yaml:"threshold"`
+}
+
+// RateLimitConfig counts Auth by IP address before credentials are checked, so bad tokens and API keys are limited too
+type RateLimitConfig struct {
+	Auth   RateLimitRule `yaml:"auth"`
+	Login  RateLimitRule `yaml:"login"`
+	Reads  RateLimitRule `yaml:"reads"`
+	Writes RateLimitRule `yaml:"writes"`
+}
+
+type RateLimitRule struct {
+	// Requests is how many requests a client can make every Per, 0 disables the limit
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	// Burst is how many requests a client can make at once, defaulting to Requests
+	Burst int `yaml:"burst"`
+}
+
+func (r *RateLimitRule) Enabled() bool {
+	return r.Requests > 0
+}
+
+func (r *RateLimitRule) rate() float64 {
+	return float64(r.Requests) / r.Per.Seconds()
+}
+
+func (c *OIDCConfig) Enabled() bool {
+	return c.IssuerURL != ""
 }
 
 func NewConfig() (*Config, error) {
@@ -39,6 +150,214 @@ func NewConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
+
+func (c *Config) applyEnv() error {
+	if secret, ok := os.LookupEnv("JWT_SECRET"); ok {
+		c.JWT.Secret = secret
+	}
+	if expiration, ok := os.LookupEnv("JWT_EXPIRATION"); ok {
+		parsed, err := time.ParseDuration(expiration)
+		if err != nil {
+			return fmt.Errorf("invalid JWT_EXPIRATION: %w", err)
+		}
+		c.JWT.Expiration = parsed
+	}
+	if expiration, ok := os.LookupEnv("JWT_REFRESH_EXPIRATION"); ok {
+		parsed, err := time.ParseDuration(expiration)
+		if err != nil {
+			return fmt.Errorf("invalid JWT_REFRESH_EXPIRATION: %w", err)
+		}
+		c.JWT.RefreshExpiration = parsed
+	}
+	if issuer, ok := os.LookupEnv("JWT_ISSUER"); ok {
+		c.JWT.Issuer = issuer
+	}
+	if role, ok := os.LookupEnv("AUTH_DEFAULT_ROLE"); ok {
+		c.Auth.DefaultRole = role
+	}
+	if secret, ok := os.LookupEnv("OIDC_CLIENT_SECRET"); ok {
+		c.OIDC.ClientSecret = secret
+	}
+	return nil
+}
+
+func (c *Config) applyDefaults() {
+	if c.JWT.Secret == "" && len(c.JWT.Keys) == 0 && c.IsDebug() {
+		c.JWT.Secret = DEV_SECRET_KEY
+	}
+	if c.JWT.SigningKeyID == "" {
+		for _, key := range c.JWT.Keys {
+			if key.PrivateKeyFile != "" {
+				c.JWT.SigningKeyID = key.ID
+				break
+			}
+		}
+	}
+	if c.Auth.DefaultRole == "" {
+		c.Auth.DefaultRole = DEFAULT_ROLE
+	}
+	if c.JWT.Expiration == 0 {
+		c.JWT.Expiration = TOKEN_EXPIRATION_TIME
+	}
+	if c.JWT.RefreshExpiration == 0 {
+		c.JWT.RefreshExpiration = REFRESH_TOKEN_EXPIRATION_TIME
+	}
+	if c.JWT.Issuer == "" {
+		c.JWT.Issuer = TOKEN_ISSUER
+	}
+	for _, rule := range []*RateLimitRule{&c.RateLimit.Auth, &c.RateLimit.Login, &c.RateLimit.Reads, &c.RateLimit.Writes} {
+		if rule.Per == 0 {
+			rule.Per = time.Minute
+		}
+		if rule.Burst == 0 {
+			rule.Burst = rule.Requests
+		}
+	}
+	if c.Duplicates.RadiusKm == 0 {
+		c.Duplicates.RadiusKm = DUPLICATE_TRAIL_RADIUS_KM
+	}
+	if c.Duplicates.Threshold == 0 {
+		c.Duplicates.Threshold = DUPLICATE_TRAIL_THRESHOLD
+	}
+	if c.OIDC.Enabled() {
+		if len(c.OIDC.Scopes) == 0 {
+			c.OIDC.Scopes = []string{"openid", "profile"}
+		}
+		if c.OIDC.UsernameClaim == "" {
+			c.OIDC.UsernameClaim = OIDC_USERNAME_CLAIM
+		}
+		if c.OIDC.RolesClaim == "" {
+			c.OIDC.RolesClaim = OIDC_ROLES_CLAIM
+		}
+	}
+}
+
+// IsDebug is also true when no mode is set, as that is gin's default
+func (c *Config) IsDebug() bool {
+	return c.Server.GinMode == "" || c.Server.GinMode == "debug"
+}
+
+func (c *Config) Validate() error {
+	if !c.IsDebug() && c.JWT.Secret == DEV_SECRET_KEY {
+		return errors.New("a jwt secret must be configured outside debug mode, set jwt.secret or JWT_SECRET")
+	}
+	if c.JWT.Secret == "" && len(c.JWT.Keys) == 0 {
+		if c.IsDebug() {
+			return errors.New("a jwt secret or signing key must be configured")
+		}
+		return errors.New("a jwt secret must be configured outside debug mode, set jwt.secret or JWT_SECRET")
+	}
+	if !isRole(c.Auth.DefaultRole) {
+		return fmt.Errorf("invalid default role %q, must be viewer, contributor, moderator or admin", c.Auth.DefaultRole)
+	}
+	if c.JWT.Expiration < 0 || c.JWT.RefreshExpiration < 0 {
+		return errors.New("jwt expiration must be positive")
+	}
+	if err := c.OIDC.validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	if c.Duplicates.RadiusKm < 0 {
+		return errors.New("duplicates radius_km must not be negative")
+	}
+	if c.Duplicates.Threshold < 0 || c.Duplicates.Threshold > 1 {
+		return errors.New("duplicates threshold must be between 0 and 1")
+	}
+	return c.JWT.validateKeys()
+}
+
+func isRole(role string) bool {
+	switch role {
+	case "viewer", "contributor", "moderator", "admin":
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *OIDCConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.ClientID == "" {
+		return errors.New("oidc client_id is required when an issuer_url is set")
+	}
+	if c.RedirectURL == "" {
+		return errors.New("oidc redirect_url is required when an issuer_url is set")
+	}
+	for name, role := range c.RoleMapping {
+		if !isRole(role) {
+			return fmt.Errorf("oidc role_mapping maps %q to invalid role %q", name, role)
+		}
+	}
+	return nil
+}
+
+func (c *RateLimitConfig) validate() error {
+	rules := map[string]RateLimitRule{"auth": c.Auth, "login": c.Login, "reads": c.Reads, "writes": c.Writes}
+	for name, rule := range rules {
+		if rule.Requests < 0 || rule.Per < 0 || rule.Burst < 0 {
+			return fmt.Errorf("rate_limit %s must not be negative", name)
+		}
+	}
+	// Login is the only limit on guessing passwords, so it must not let a client through faster than the others
+	if !c.Login.Enabled() {
+		return nil
+	}
+	for _, name := range []string{"auth", "reads", "writes"} {
+		rule := rules[name]
+		if rule.Enabled() && (c.Login.rate() > rule.rate() || c.Login.Burst > rule.Burst) {
+			return fmt.Errorf("rate_limit login must not allow more requests than %s", name)
+		}
+	}
+	return nil
+}
+
+func (c *JWTConfig) validateKeys() error {
+	if len(c.Keys) == 0 {
+		return nil
+	}
+
+	ids := make(map[string]bool, len(c.Keys))
+	for _, key := range c.Keys {
+		if key.ID == "" {
+			return errors.New("jwt keys must each have an id")
+		}
+		if ids[key.ID] {
+			return fmt.Errorf("jwt key id %q is used more than once", key.ID)
+		}
+		ids[key.ID] = true
+		if key.PrivateKeyFile == "" && key.PublicKeyFile == "" {
+			return fmt.Errorf("jwt key %q needs a private_key_file or public_key_file", key.ID)
+		}
+	}
+
+	if c.SigningKeyID == "" {
+		return errors.New("jwt keys need at least one key with a private_key_file to sign with")
+	}
+	for _, key := range c.Keys {
+		if key.ID == c.SigningKeyID {
+			if key.PrivateKeyFile == "" {
+				return fmt.Errorf("jwt signing key %q has no private_key_file", key.ID)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("jwt signing key %q is not one of the configured keys", c.SigningKeyID)
+}
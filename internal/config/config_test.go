@@ -0,0 +1,249 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfig(t *testing.T) {
+	tests := []struct {
+		name               string
+		yaml               string
+		env                map[string]string
+		expectedError      string
+		expectedSecret     string
+		expectedExpiration time.Duration
+		expectedIssuer     string
+	}{
+		{
+			name:               "debug mode falls back to the development secret",
+			yaml:               "server:\n  gin_mode: debug\n",
+			expectedSecret:     DEV_SECRET_KEY,
+			expectedExpiration: TOKEN_EXPIRATION_TIME,
+			expectedIssuer:     TOKEN_ISSUER,
+		},
+		{
+			name:               "values from the file",
+			yaml:               "server:\n  gin_mode: release\njwt:\n  secret: file-secret\n  expiration: 30m\n  issuer: trails\n",
+			expectedSecret:     "file-secret",
+			expectedExpiration: 30 * time.Minute,
+			expectedIssuer:     "trails",
+		},
+		{
+			name:               "environment overrides the file",
+			yaml:               "server:\n  gin_mode: release\njwt:\n  secret: file-secret\n  expiration: 30m\n",
+			env:                map[string]string{"JWT_SECRET": "env-secret", "JWT_EXPIRATION": "2h", "JWT_ISSUER": "env-issuer"},
+			expectedSecret:     "env-secret",
+			expectedExpiration: 2 * time.Hour,
+			expectedIssuer:     "env-issuer",
+		},
+		{
+			name:          "release mode without a secret",
+			yaml:          "server:\n  gin_mode: release\n",
+			expectedError: "a jwt secret must be configured outside debug mode, set jwt.secret or JWT_SECRET",
+		},
+		{
+			name:          "release mode with the development secret",
+			yaml:          "server:\n  gin_mode: release\n",
+			env:           map[string]string{"JWT_SECRET": DEV_SECRET_KEY},
+			expectedError: "a jwt secret must be configured outside debug mode, set jwt.secret or JWT_SECRET",
+		},
+		{
+			name:          "unknown default role",
+			yaml:          "server:\n  gin_mode: debug\nauth:\n  default_role: ranger\n",
+			expectedError: `invalid default role "ranger", must be viewer, contributor, moderator or admin`,
+		},
+		{
+			name:          "oidc without a client id",
+			yaml:          "server:\n  gin_mode: debug\noidc:\n  issuer_url: https://idp.example.com\n  redirect_url: http://localhost/oidc/callback\n",
+			expectedError: "oidc client_id is required when an issuer_url is set",
+		},
+		{
+			name:          "oidc role mapped to an unknown role",
+			yaml:          "server:\n  gin_mode: debug\noidc:\n  issuer_url: https://idp.example.com\n  client_id: trails\n  redirect_url: http://localhost/oidc/callback\n  role_mapping:\n    editors: superuser\n",
+			expectedError: `oidc role_mapping maps "editors" to invalid role "superuser"`,
+		},
+		{
+			name:          "negative rate limit",
+			yaml:          "server:\n  gin_mode: debug\nrate_limit:\n  writes:\n    requests: -1\n",
+			expectedError: "rate_limit writes must not be negative",
+		},
+		{
+			name:          "login rate limit looser than reads",
+			yaml:          "server:\n  gin_mode: debug\nrate_limit:\n  login:\n    requests: 20\n    per: 1s\n  reads:\n    requests: 600\n    per: 1m\n",
+			expectedError: "rate_limit login must not allow more requests than reads",
+		},
+		{
+			name:          "duplicate threshold above one",
+			yaml:          "server:\n  gin_mode: debug\nduplicates:\n  threshold: 1.5\n",
+			expectedError: "duplicates threshold must be between 0 and 1",
+		},
+		{
+			name:          "negative duplicate radius",
+			yaml:          "server:\n  gin_mode: debug\nduplicates:\n  radius_km: -5\n",
+			expectedError: "duplicates radius_km must not be negative",
+		},
+		{
+			name:          "invalid expiration in the environment",
+			yaml:          "server:\n  gin_mode: debug\n",
+			env:           map[string]string{"JWT_EXPIRATION": "soon"},
+			expectedError: `invalid JWT_EXPIRATION: time: invalid duration "soon"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := readFile
+			readFile = func(string) ([]byte, error) { return []byte(tt.yaml), nil }
+			t.Cleanup(func() { readFile = original })
+			for _, key := range []string{"JWT_SECRET", "JWT_EXPIRATION", "JWT_ISSUER"} {
+				// Setenv restores the variable after the test, unset it when the case leaves it out
+				value, ok := tt.env[key]
+				t.Setenv(key, value)
+				if !ok {
+					os.Unsetenv(key)
+				}
+			}
+
+			cfg, err := NewConfig()
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSecret, cfg.JWT.Secret)
+			assert.Equal(t, tt.expectedExpiration, cfg.JWT.Expiration)
+			assert.Equal(t, tt.expectedIssuer, cfg.JWT.Issuer)
+			assert.Equal(t, "viewer", cfg.Auth.DefaultRole)
+		})
+	}
+}
+
+func TestNewConfig_Keys(t *testing.T) {
+	tests := []struct {
+		name                 string
+		yaml                 string
+		expectedError        string
+		expectedSigningKeyID string
+	}{
+		{
+			name: "keys stand in for the secret outside debug mode",
+			yaml: "server:\n  gin_mode: release\njwt:\n  keys:\n" +
+				"    - id: new\n      private_key_file: new.pem\n" +
+				"    - id: old\n      public_key_file: old.pub.pem\n",
+			expectedSigningKeyID: "new",
+		},
+		{
+			name: "signing key picked explicitly",
+			yaml: "jwt:\n  signing_key_id: b\n  keys:\n" +
+				"    - id: a\n      private_key_file: a.pem\n" +
+				"    - id: b\n      private_key_file: b.pem\n",
+			expectedSigningKeyID: "b",
+		},
+		{
+			name:          "signing key without a private key",
+			yaml:          "jwt:\n  signing_key_id: old\n  keys:\n    - id: old\n      public_key_file: old.pub.pem\n",
+			expectedError: `jwt signing key "old" has no private_key_file`,
+		},
+		{
+			name:          "only public keys",
+			yaml:          "jwt:\n  keys:\n    - id: old\n      public_key_file: old.pub.pem\n",
+			expectedError: "jwt keys need at least one key with a private_key_file to sign with",
+		},
+		{
+			name:          "unknown signing key",
+			yaml:          "jwt:\n  signing_key_id: other\n  keys:\n    - id: a\n      private_key_file: a.pem\n",
+			expectedError: `jwt signing key "other" is not one of the configured keys`,
+		},
+		{
+			name: "duplicate key id",
+			yaml: "jwt:\n  keys:\n" +
+				"    - id: a\n      private_key_file: a.pem\n" +
+				"    - id: a\n      public_key_file: a.pub.pem\n",
+			expectedError: `jwt key id "a" is used more than once`,
+		},
+		{
+			name:          "key without files",
+			yaml:          "jwt:\n  keys:\n    - id: a\n",
+			expectedError: `jwt key "a" needs a private_key_file or public_key_file`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := readFile
+			readFile = func(string) ([]byte, error) { return []byte(tt.yaml), nil }
+			t.Cleanup(func() { readFile = original })
+
+			cfg, err := NewConfig()
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSigningKeyID, cfg.JWT.SigningKeyID)
+			assert.Empty(t, cfg.JWT.Secret)
+		})
+	}
+}
+
+func TestNewConfig_OIDCDefaults(t *testing.T) {
+	original := readFile
+	readFile = func(string) ([]byte, error) {
+		return []byte("server:\n  gin_mode: debug\noidc:\n  issuer_url: https://idp.example.com\n  client_id: trails\n  redirect_url: http://localhost/oidc/callback\n"), nil
+	}
+	t.Cleanup(func() { readFile = original })
+	t.Setenv("OIDC_CLIENT_SECRET", "env-client-secret")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.True(t, cfg.OIDC.Enabled())
+	assert.Equal(t, "env-client-secret", cfg.OIDC.ClientSecret)
+	assert.Equal(t, []string{"openid", "profile"}, cfg.OIDC.Scopes)
+	assert.Equal(t, OIDC_USERNAME_CLAIM, cfg.OIDC.UsernameClaim)
+	assert.Equal(t, OIDC_ROLES_CLAIM, cfg.OIDC.RolesClaim)
+}
+
+func TestNewConfig_RateLimitDefaults(t *testing.T) {
+	original := readFile
+	readFile = func(string) ([]byte, error) {
+		return []byte("server:\n  gin_mode: debug\nrate_limit:\n  auth:\n    requests: 1200\n  login:\n    requests: 10\n  reads:\n    requests: 100\n    per: 10s\n    burst: 20\n"), nil
+	}
+	t.Cleanup(func() { readFile = original })
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitRule{Requests: 1200, Per: time.Minute, Burst: 1200}, cfg.RateLimit.Auth)
+	assert.Equal(t, RateLimitRule{Requests: 10, Per: time.Minute, Burst: 10}, cfg.RateLimit.Login)
+	assert.Equal(t, RateLimitRule{Requests: 100, Per: 10 * time.Second, Burst: 20}, cfg.RateLimit.Reads)
+	assert.False(t, cfg.RateLimit.Writes.Enabled())
+}
+
+func TestNewConfig_DefaultRoleFromEnv(t *testing.T) {
+	original := readFile
+	readFile = func(string) ([]byte, error) {
+		return []byte("server:\n  gin_mode: debug\n"), nil
+	}
+	t.Cleanup(func() { readFile = original })
+	t.Setenv("AUTH_DEFAULT_ROLE", "contributor")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "contributor", cfg.Auth.DefaultRole)
+}
+
+func TestNewConfig_DuplicateDefaults(t *testing.T) {
+	original := readFile
+	readFile = func(string) ([]byte, error) {
+		return []byte("server:\n  gin_mode: debug\nduplicates:\n  radius_km: 5\n"), nil
+	}
+	t.Cleanup(func() { readFile = original })
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.Equal(t, DuplicateConfig{RadiusKm: 5, Threshold: DUPLICATE_TRAIL_THRESHOLD}, cfg.Duplicates)
+}
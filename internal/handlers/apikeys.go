@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dnakolan/trail-data-service/internal/middleware"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeysHandler struct {
+	service services.APIKeyService
+}
+
+func NewAPIKeysHandler(service services.APIKeyService) *APIKeysHandler {
+	return &APIKeysHandler{service: service}
+}
+
+// CreateAPIKeyHandler is the only response the key is ever shown in
+func (h *APIKeysHandler) CreateAPIKeyHandler(c *gin.Context) {
+	claims, ok := tokenClaims(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := req.Validate(); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	issued, err := h.service.CreateAPIKey(c.Request.Context(), claims.Username, &req)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusCreated, issued)
+}
+
+func (h *APIKeysHandler) ListAPIKeysHandler(c *gin.Context) {
+	claims, ok := tokenClaims(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.ListAPIKeys(c.Request.Context(), claims.Username)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+func (h *APIKeysHandler) RevokeAPIKeyHandler(c *gin.Context) {
+	claims, ok := tokenClaims(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(c.Request.Context(), claims.Username, c.Param("id")); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// tokenClaims refuses API keys, so a leaked key cannot be used to mint more keys or hide itself
+func tokenClaims(c *gin.Context) (*models.Claims, bool) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		middleware.AbortWithProblem(c, http.StatusUnauthorized, "authentication required")
+		return nil, false
+	}
+	if claims.APIKeyID != "" {
+		middleware.AbortWithProblem(c, http.StatusForbidden, "API keys can only be managed after logging in")
+		return nil, false
+	}
+	return claims, true
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/middleware"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/services"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeysHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, err := services.NewKeySet(testJWTConfig)
+	require.NoError(t, err)
+	users := storage.NewUserStorage()
+	loginService := services.NewLoginService(users, storage.NewTokenStorage(), config.AuthConfig{LockoutDuration: time.Minute, DefaultRole: "contributor"}, testJWTConfig, keys)
+	apiKeyService := services.NewAPIKeyService(storage.NewAPIKeyStorage(), users)
+	loginHandler := NewLoginHandler(loginService)
+	handler := NewAPIKeysHandler(apiKeyService)
+	auth := middleware.JwtAuthMiddleware(testJWTConfig, keys, loginService, apiKeyService)
+
+	router := gin.New()
+	router.POST("/register", loginHandler.RegisterHandler)
+	router.POST("/login", loginHandler.LoginHandler)
+	router.POST("/api-keys", auth, handler.CreateAPIKeyHandler)
+	router.GET("/api-keys", auth, handler.ListAPIKeysHandler)
+	router.DELETE("/api-keys/:id", auth, handler.RevokeAPIKeyHandler)
+	router.POST("/trails", auth, middleware.RequireRole(models.RoleContributor), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	// send authenticates with a bearer token, or with an API key when it has the tds_ prefix
+	send := func(method, url, body, credential string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if strings.HasPrefix(credential, "tds_") {
+			req.Header.Set("X-API-Key", credential)
+		} else if credential != "" {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusCreated, send(http.MethodPost, "/register", `{"username": "ranger", "password": "correct horse"}`, "").Code)
+	w := send(http.MethodPost, "/login", `{"username": "ranger", "password": "correct horse"}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var tokens models.TokenPair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/api-keys", `{"name": "ingest", "scopes": ["delete:trails"]}`, tokens.AccessToken).Code)
+
+	w = send(http.MethodPost, "/api-keys", `{"name": "ingest", "scopes": ["read:trails", "write:trails"]}`, tokens.AccessToken)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var issued struct {
+		ID  string `json:"key_id"`
+		Key string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	require.NotEmpty(t, issued.Key)
+
+	// The key can write trails but cannot manage keys
+	assert.Equal(t, http.StatusCreated, send(http.MethodPost, "/trails", `{}`, issued.Key).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api-keys", "", issued.Key).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/api-keys", `{"name": "more", "scopes": ["read:trails"]}`, issued.Key).Code)
+
+	w = send(http.MethodGet, "/api-keys", "", tokens.AccessToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), issued.Key)
+	var list struct {
+		Keys []models.APIKey `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Keys, 1)
+	assert.Equal(t, issued.ID, list.Keys[0].ID.String())
+
+	assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/api-keys/"+uuid.New().String(), "", tokens.AccessToken).Code)
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/api-keys/"+issued.ID, "", tokens.AccessToken).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/trails", `{}`, issued.Key).Code)
+}
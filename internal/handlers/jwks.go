@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dnakolan/trail-data-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	keys *services.KeySet
+}
+
+func NewJWKSHandler(keys *services.KeySet) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+func (h *JWKSHandler) GetJWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
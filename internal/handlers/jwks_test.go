@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetJWKSHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, err := services.NewKeySet(testJWTConfig)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/.well-known/jwks.json", NewJWKSHandler(keys).GetJWKSHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+
+	var jwks models.JWKS
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	assert.NotNil(t, jwks.Keys)
+	assert.Empty(t, jwks.Keys, "only a secret is configured so there is nothing to publish")
+}
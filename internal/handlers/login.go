@@ -3,6 +3,8 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/dnakolan/trail-data-service/internal/middleware"
+	"github.com/dnakolan/trail-data-service/internal/models"
 	"github.com/dnakolan/trail-data-service/internal/services"
 	"github.com/gin-gonic/gin"
 )
@@ -21,17 +23,100 @@ func (l *loginHandler) LoginHandler(c *gin.Context) {
 		Password string `json:"password"`
 	}
 
-	if err := c.ShouldBindJSON(&credentials); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+	if !bindJSON(c, &credentials) {
 		return
 	}
 
-	token, err := l.service.Login(c.Request.Context(), credentials.Username, credentials.Password)
+	tokens, err := l.service.Login(c.Request.Context(), credentials.Username, credentials.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to login"})
+		middleware.AbortWithError(c, err)
 		return
 	}
 
 	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (l *loginHandler) RefreshHandler(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, err := l.service.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (l *loginHandler) LogoutHandler(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		middleware.AbortWithProblem(c, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if c.Request.ContentLength != 0 {
+		if !bindJSON(c, &req) {
+			return
+		}
+	}
+
+	if err := l.service.Logout(c.Request.Context(), claims, req.RefreshToken); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (l *loginHandler) RegisterHandler(c *gin.Context) {
+	var req models.RegisterUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	user, err := l.service.Register(c.Request.Context(), &req)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusCreated, user)
+}
+
+func (l *loginHandler) SetRoleHandler(c *gin.Context) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !models.IsValidRole(req.Role) {
+		middleware.AbortWithError(c, models.NewValidationError("role", "role must be viewer, contributor, moderator or admin"))
+		return
+	}
+
+	user, err := l.service.SetRole(c.Request.Context(), c.Param("username"), models.Role(req.Role))
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, user)
 }
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/middleware"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/services"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testJWTConfig = config.JWTConfig{
+	Secret:            "test-secret",
+	Expiration:        time.Hour,
+	RefreshExpiration: 24 * time.Hour,
+	Issuer:            "trail-data-service",
+}
+
+func newTestLoginRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	keys, err := services.NewKeySet(testJWTConfig)
+	require.NoError(t, err)
+	auth := config.AuthConfig{MaxFailedLogins: 2, LockoutDuration: time.Minute}
+	service := services.NewLoginService(storage.NewUserStorage(), storage.NewTokenStorage(), auth, testJWTConfig, keys)
+	require.NoError(t, service.EnsureAdmin(context.Background(), "warden", "warden password"))
+	handler := NewLoginHandler(service)
+
+	router := gin.New()
+	router.POST("/login", handler.LoginHandler)
+	router.POST("/register", handler.RegisterHandler)
+	router.POST("/token/refresh", handler.RefreshHandler)
+	router.POST("/logout", middleware.JwtAuthMiddleware(testJWTConfig, keys, service, nil), handler.LogoutHandler)
+	router.PUT("/users/:username/role", middleware.JwtAuthMiddleware(testJWTConfig, keys, service, nil), middleware.RequireRole(models.RoleAdmin), handler.SetRoleHandler)
+	router.GET("/protected", middleware.JwtAuthMiddleware(testJWTConfig, keys, service, nil), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestLoginAndRegisterHandlers(t *testing.T) {
+	router := newTestLoginRouter(t)
+
+	// Steps run in order against the same router
+	steps := []struct {
+		name           string
+		url            string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "register",
+			url:            "/register",
+			body:           `{"username": "ranger", "password": "correct horse"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "register taken username",
+			url:            "/register",
+			body:           `{"username": "Ranger", "password": "correct horse"}`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "register weak password",
+			url:            "/register",
+			body:           `{"username": "hiker", "password": "short"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "login",
+			url:            "/login",
+			body:           `{"username": "ranger", "password": "correct horse"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "login missing password",
+			url:            "/login",
+			body:           `{"username": "ranger"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "login unknown user",
+			url:            "/login",
+			body:           `{"username": "nobody", "password": "correct horse"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "first wrong password",
+			url:            "/login",
+			body:           `{"username": "ranger", "password": "wrong horse"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "second wrong password locks the account",
+			url:            "/login",
+			body:           `{"username": "ranger", "password": "wrong horse"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "locked account",
+			url:            "/login",
+			body:           `{"username": "ranger", "password": "correct horse"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, step.url, bytes.NewBufferString(step.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, step.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestRefreshAndLogoutHandlers(t *testing.T) {
+	router := newTestLoginRouter(t)
+
+	post := func(url, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) models.TokenPair {
+		var tokens models.TokenPair
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		return tokens
+	}
+
+	require.Equal(t, http.StatusCreated, post("/register", `{"username": "ranger", "password": "correct horse"}`, "").Code)
+	w := post("/login", `{"username": "ranger", "password": "correct horse"}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	login := decode(w)
+
+	w = post("/token/refresh", `{"refresh_token": "`+login.RefreshToken+`"}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	refreshed := decode(w)
+	assert.NotEmpty(t, refreshed.AccessToken)
+
+	assert.Equal(t, http.StatusUnauthorized, post("/token/refresh", `{"refresh_token": "`+login.RefreshToken+`"}`, "").Code)
+	assert.Equal(t, http.StatusBadRequest, post("/token/refresh", `{}`, "").Code)
+
+	// Log in again as the reused token revoked the previous family
+	w = post("/login", `{"username": "ranger", "password": "correct horse"}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	login = decode(w)
+
+	get := func(url, token string) int {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, get("/protected", login.AccessToken))
+
+	assert.Equal(t, http.StatusNoContent, post("/logout", `{"refresh_token": "`+login.RefreshToken+`"}`, login.AccessToken).Code)
+	assert.Equal(t, http.StatusUnauthorized, get("/protected", login.AccessToken))
+	assert.Equal(t, http.StatusUnauthorized, post("/token/refresh", `{"refresh_token": "`+login.RefreshToken+`"}`, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, post("/logout", "", "").Code)
+}
+
+func TestSetRoleHandler(t *testing.T) {
+	router := newTestLoginRouter(t)
+
+	send := func(method, url, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username, password string) string {
+		w := send(http.MethodPost, "/login", `{"username": "`+username+`", "password": "`+password+`"}`, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var tokens models.TokenPair
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		return tokens.AccessToken
+	}
+
+	require.Equal(t, http.StatusCreated, send(http.MethodPost, "/register", `{"username": "ranger", "password": "correct horse"}`, "").Code)
+	admin := login("warden", "warden password")
+	contributor := login("ranger", "correct horse")
+
+	tests := []struct {
+		name           string
+		username       string
+		body           string
+		token          string
+		expectedStatus int
+	}{
+		{
+			name:           "admin promotes a user",
+			username:       "ranger",
+			body:           `{"role": "moderator"}`,
+			token:          admin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown role",
+			username:       "ranger",
+			body:           `{"role": "superuser"}`,
+			token:          admin,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown user",
+			username:       "nobody",
+			body:           `{"role": "viewer"}`,
+			token:          admin,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "not an admin",
+			username:       "ranger",
+			body:           `{"role": "admin"}`,
+			token:          contributor,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "not logged in",
+			username:       "ranger",
+			body:           `{"role": "admin"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(http.MethodPut, "/users/"+tt.username+"/role", tt.body, tt.token)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				var user models.User
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+				assert.Equal(t, models.RoleModerator, user.Role)
+			}
+		})
+	}
+}
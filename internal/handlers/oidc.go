@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/dnakolan/trail-data-service/internal/middleware"
+	"github.com/dnakolan/trail-data-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookie ties a login to the browser that started it, so nobody can be logged in to someone else's account
+const oidcStateCookie = "oidc_state"
+
+type OIDCHandler struct {
+	service       services.OIDCService
+	secureCookies bool
+}
+
+func NewOIDCHandler(service services.OIDCService, secureCookies bool) *OIDCHandler {
+	return &OIDCHandler{service: service, secureCookies: secureCookies}
+}
+
+func (h *OIDCHandler) LoginHandler(c *gin.Context) {
+	target, state, err := h.service.AuthCodeURL(c.Request.Context())
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	// Lax, as the provider sends the user back with a cross site redirect
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(services.OIDCLoginTimeout.Seconds()), "/oidc", "", h.secureCookies, true)
+	c.Redirect(http.StatusFound, target)
+}
+
+func (h *OIDCHandler) CallbackHandler(c *gin.Context) {
+	cookieState, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, "/oidc", "", h.secureCookies, true)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		message := providerErr
+		if description := c.Query("error_description"); description != "" {
+			message += ": " + description
+		}
+		middleware.AbortWithProblem(c, http.StatusUnauthorized, message)
+		return
+	}
+
+	state := c.Query("state")
+	if cookieState == "" || subtle.ConstantTimeCompare([]byte(cookieState), []byte(state)) != 1 {
+		middleware.AbortWithError(c, services.ErrInvalidLoginState)
+		return
+	}
+
+	tokens, err := h.service.Callback(c.Request.Context(), c.Query("code"), state)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, tokens)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubOIDCService answers for the identity provider, the full flow is tested against a fake provider in services
+type stubOIDCService struct {
+	authURL string
+	err     error
+}
+
+func (s *stubOIDCService) AuthCodeURL(ctx context.Context) (string, string, error) {
+	return s.authURL, "abc", s.err
+}
+
+func (s *stubOIDCService) Callback(ctx context.Context, code string, state string) (*models.TokenPair, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.TokenPair{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 3600}, nil
+}
+
+func TestOIDCHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name             string
+		url              string
+		service          *stubOIDCService
+		cookie           string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "login redirects to the provider",
+			url:              "/oidc/login",
+			service:          &stubOIDCService{authURL: "https://idp.example.com/authorize?state=abc"},
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://idp.example.com/authorize?state=abc",
+		},
+		{
+			name:           "provider unavailable",
+			url:            "/oidc/login",
+			service:        &stubOIDCService{err: models.Errorf(models.ErrUnavailable, "failed to fetch oidc discovery document")},
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "too many logins in progress",
+			url:            "/oidc/login",
+			service:        &stubOIDCService{err: services.ErrTooManyLogins},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "successful callback",
+			url:            "/oidc/callback?code=abc&state=xyz",
+			cookie:         "xyz",
+			service:        &stubOIDCService{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "login started in another browser",
+			url:            "/oidc/callback?code=abc&state=xyz",
+			service:        &stubOIDCService{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "state not matching the browser's login",
+			url:            "/oidc/callback?code=abc&state=xyz",
+			service:        &stubOIDCService{},
+			cookie:         "abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "user declined at the provider",
+			url:            "/oidc/callback?error=access_denied&state=xyz",
+			cookie:         "xyz",
+			service:        &stubOIDCService{},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "unknown state",
+			url:            "/oidc/callback?code=abc&state=xyz",
+			cookie:         "xyz",
+			service:        &stubOIDCService{err: services.ErrInvalidLoginState},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid id token",
+			url:            "/oidc/callback?code=abc&state=xyz",
+			cookie:         "xyz",
+			service:        &stubOIDCService{err: models.Errorf(models.ErrUnauthenticated, "invalid id token: nonce does not match")},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "invalid username from the provider",
+			url:            "/oidc/callback?code=abc&state=xyz",
+			cookie:         "xyz",
+			service:        &stubOIDCService{err: services.ErrInvalidExternalUsername},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "username taken",
+			url:            "/oidc/callback?code=abc&state=xyz",
+			cookie:         "xyz",
+			service:        &stubOIDCService{err: services.ErrUsernameTaken},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "unexpected error",
+			url:            "/oidc/callback?code=abc&state=xyz",
+			cookie:         "xyz",
+			service:        &stubOIDCService{err: errors.New("disk on fire")},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewOIDCHandler(tt.service, true)
+			router := gin.New()
+			router.GET("/oidc/login", handler.LoginHandler)
+			router.GET("/oidc/callback", handler.CallbackHandler)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedLocation != "" {
+				assert.Equal(t, tt.expectedLocation, w.Header().Get("Location"))
+				// The browser keeps the state so the callback can tell it started the login
+				cookie := w.Result().Cookies()[0]
+				assert.Equal(t, oidcStateCookie, cookie.Name)
+				assert.Equal(t, "abc", cookie.Value)
+				assert.True(t, cookie.HttpOnly)
+				assert.True(t, cookie.Secure)
+				assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+			}
+		})
+	}
+}
@@ -1,17 +1,39 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dnakolan/trail-data-service/internal/middleware"
 	"github.com/dnakolan/trail-data-service/internal/models"
 	"github.com/dnakolan/trail-data-service/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+	maxNearest       = 100
+
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+
+	maxGPXUploadBytes = 10 << 20
+	maxAreaBytes      = 1 << 20
+
+	defaultProfileResolutionM = 100
+	minProfileResolutionM     = 1
+	maxProfileSamples         = 10000
+)
+
 type TrailsHandler struct {
 	service services.TrailsService
 }
@@ -22,22 +44,36 @@ func NewTrailsHandler(service services.TrailsService) *TrailsHandler {
 
 func (h *TrailsHandler) CreateTrailHandler(c *gin.Context) {
 	var req models.CreateTrailRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
+	req.DeriveFromTrack()
 	if err := req.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		middleware.AbortWithProblem(c, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	force, err := forceRequested(c, claims)
+	if err != nil {
+		middleware.AbortWithError(c, err)
 		return
 	}
 
 	now := time.Now()
 	trail := models.NewTrailFromRequest(&req)
 	trail.CreatedAt = &now
+	trail.CreatedBy = claims.Username
+	trail.UpdatedBy = claims.Username
 
-	if err := h.service.CreateTrail(c.Request.Context(), trail); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.CreateTrail(c.Request.Context(), trail, force); err != nil {
+		middleware.AbortWithError(c, err)
 		return
 	}
 
@@ -45,35 +81,467 @@ func (h *TrailsHandler) CreateTrailHandler(c *gin.Context) {
 	c.JSON(http.StatusCreated, trail)
 }
 
+// ImportGPXHandler takes the file as the raw body or the "file" field of a multipart form
+func (h *TrailsHandler) ImportGPXHandler(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		middleware.AbortWithProblem(c, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	var difficulty *models.TrailDifficulty
+	if difficultyStr := c.Query("difficulty"); difficultyStr != "" {
+		if !models.IsValidTrailDifficulty(difficultyStr) {
+			middleware.AbortWithError(c, models.NewValidationError("difficulty", "trail difficulty must be easy, medium, or hard"))
+			return
+		}
+		d := models.TrailDifficulty(difficultyStr)
+		difficulty = &d
+	}
+	force, err := forceRequested(c, claims)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxGPXUploadBytes)
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			middleware.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			middleware.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	trails, err := models.ParseGPX(body)
+	if err != nil {
+		middleware.AbortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	type importFailure struct {
+		Name      string              `json:"name"`
+		Error     string              `json:"error"`
+		Errors    []models.FieldError `json:"errors,omitempty"`
+		Conflicts []string            `json:"conflicts,omitempty"`
+	}
+	newFailure := func(trail *models.Trail, err error) importFailure {
+		failure := importFailure{Name: *trail.Name, Error: err.Error()}
+		var validation *models.ValidationError
+		if errors.As(err, &validation) {
+			failure.Errors = validation.Fields
+		}
+		var conflict *models.ConflictError
+		if errors.As(err, &conflict) {
+			failure.Conflicts = conflict.IDs
+		}
+		return failure
+	}
+	created := make([]*models.Trail, 0, len(trails))
+	failed := make([]importFailure, 0)
+
+	now := time.Now()
+	for _, trail := range trails {
+		trail.Difficulty = difficulty
+		trail.CreatedAt = &now
+		trail.CreatedBy = claims.Username
+		trail.UpdatedBy = claims.Username
+
+		if err := trail.Validate(); err != nil {
+			failed = append(failed, newFailure(trail, err))
+			continue
+		}
+		if err := h.service.CreateTrail(c.Request.Context(), trail, force); err != nil {
+			failed = append(failed, newFailure(trail, err))
+			continue
+		}
+		created = append(created, trail)
+	}
+
+	status := http.StatusCreated
+	if len(created) == 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	c.Header("Content-Type", "application/json")
+	c.JSON(status, gin.H{"created": created, "failed": failed})
+}
+
 func (h *TrailsHandler) GetTrailsHandler(c *gin.Context) {
-	uid := c.Param("uid")
-	trail, err := h.service.GetTrail(c.Request.Context(), uid)
+	trail, ok := h.findTrail(c)
+	if !ok {
+		return
+	}
+
+	geoJSON, err := wantsGeoJSON(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	if geoJSON {
+		feature, err := trail.ToGeoJSONFeature()
+		if err != nil {
+			middleware.AbortWithError(c, err)
+			return
+		}
+		c.Header("Content-Type", models.GeoJSONContentType)
+		c.JSON(http.StatusOK, feature)
+		return
+	}
+
+	polyline, err := wantsPolylineTracks(c)
 	if err != nil {
-		if err.Error() == "trail not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	if polyline {
+		c.JSON(http.StatusOK, trail.WithPolylineTrack())
+		return
+	}
+	c.JSON(http.StatusOK, trail)
+}
+
+func (h *TrailsHandler) ExportTrailHandler(c *gin.Context) {
+	trail, ok := h.findTrail(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "gpx")
+	var contentType string
+	var write func(io.Writer) error
+	switch format {
+	case "gpx":
+		contentType, write = models.GPXContentType, trail.WriteGPX
+	case "kml":
+		contentType, write = models.KMLContentType, trail.WriteKML
+	default:
+		middleware.AbortWithError(c, models.NewValidationError("format", fmt.Sprintf("invalid format: %s", format)))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": trail.FileName(format),
+	}))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+func (h *TrailsHandler) ElevationProfileHandler(c *gin.Context) {
+	resolutionM := float64(defaultProfileResolutionM)
+	if resolutionStr := c.Query("resolution-m"); resolutionStr != "" {
+		val, err := strconv.ParseFloat(resolutionStr, 64)
+		if err != nil {
+			middleware.AbortWithError(c, models.NewValidationError("resolution-m", fmt.Sprintf("invalid resolution-m: %v", err)))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if val < minProfileResolutionM {
+			middleware.AbortWithError(c, models.NewValidationError("resolution-m", fmt.Sprintf("invalid resolution-m: must be at least %d", minProfileResolutionM)))
+			return
+		}
+		resolutionM = val
+	}
+
+	trail, ok := h.findTrail(c)
+	if !ok {
+		return
+	}
+	if !trail.Track.HasElevation() {
+		middleware.AbortWithProblem(c, http.StatusNotFound, "trail has no elevation data")
+		return
+	}
+	if samples := models.TrackLengthKm(trail.Track) * 1000 / resolutionM; samples > maxProfileSamples {
+		middleware.AbortWithError(c, models.NewValidationError("resolution-m", fmt.Sprintf("invalid resolution-m: profile would have more than %d samples", maxProfileSamples)))
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{
+		"trail_id":     trail.UID,
+		"resolution_m": resolutionM,
+		"elevation":    trail.Track.ElevationStats(),
+		"samples":      trail.Track.ElevationProfile(resolutionM),
+	})
+}
+
+func (h *TrailsHandler) findTrail(c *gin.Context) (*models.Trail, bool) {
+	uid := c.Param("uid")
+	trail, err := h.service.GetTrail(c.Request.Context(), uid)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return nil, false
+	}
+	return trail, true
+}
+
+func (h *TrailsHandler) findChangeableTrail(c *gin.Context) (*models.Trail, bool) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		middleware.AbortWithProblem(c, http.StatusUnauthorized, "authentication required")
+		return nil, false
+	}
+	trail, ok := h.findTrail(c)
+	if !ok {
+		return nil, false
+	}
+	if !models.CanChangeTrail(claims.Username, claims.Role, trail) {
+		middleware.AbortWithProblem(c, http.StatusForbidden, "only the trail's creator or a moderator can change it")
+		return nil, false
+	}
+	return trail, true
+}
+
+func (h *TrailsHandler) UpdateTrailHandler(c *gin.Context) {
+	existing, ok := h.findChangeableTrail(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateTrailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	req.DeriveFromTrack()
+	if err := req.Validate(); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	trail := *existing
+	trail.CreateTrailRequest = req
+	trail.DifficultyRated = false
+	h.saveTrail(c, &trail)
+}
+
+func (h *TrailsHandler) PatchTrailHandler(c *gin.Context) {
+	existing, ok := h.findChangeableTrail(c)
+	if !ok {
+		return
+	}
+
+	var patch models.TrailPatch
+	if !bindJSON(c, &patch) {
+		return
+	}
+
+	trail := *existing
+	trail.ApplyPatch(&patch)
+
+	if err := trail.Validate(); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	h.saveTrail(c, &trail)
+}
+
+func (h *TrailsHandler) saveTrail(c *gin.Context, trail *models.Trail) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		middleware.AbortWithProblem(c, http.StatusUnauthorized, "authentication required")
 		return
 	}
+	force, err := forceRequested(c, claims)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	trail.UpdatedBy = claims.Username
+	if err := h.service.UpdateTrail(c.Request.Context(), trail, force); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
 	c.Header("Content-Type", "application/json")
 	c.JSON(http.StatusOK, trail)
 }
 
+func (h *TrailsHandler) DeleteTrailHandler(c *gin.Context) {
+	trail, ok := h.findChangeableTrail(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteTrail(c.Request.Context(), trail.UID.String()); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (h *TrailsHandler) ListTrailsHandler(c *gin.Context) {
 	filter, err := parseFilter(c.Request.URL.Query())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.AbortWithError(c, err)
+		return
+	}
+	h.listTrails(c, filter)
+}
+
+func (h *TrailsHandler) ListMyTrailsHandler(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		middleware.AbortWithProblem(c, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	filter, err := parseFilter(c.Request.URL.Query())
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	filter.Owner = &claims.Username
+	h.listTrails(c, filter)
+}
+
+func (h *TrailsHandler) SearchTrailsHandler(c *gin.Context) {
+	filter, err := parseFilter(c.Request.URL.Query())
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxAreaBytes))
+	if err != nil {
+		middleware.AbortWithProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	area, err := models.ParseGeoJSONArea(body)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	filter.Area = area
+	h.listTrails(c, filter)
+}
+
+func forceRequested(c *gin.Context, claims *models.Claims) (bool, error) {
+	forceStr := c.Query("force")
+	if forceStr == "" {
+		return false, nil
+	}
+	force, err := strconv.ParseBool(forceStr)
+	if err != nil {
+		return false, models.NewValidationError("force", fmt.Sprintf("invalid force: %s", forceStr))
+	}
+	if force && !claims.Role.AtLeast(models.RoleModerator) {
+		return false, models.Errorf(models.ErrForbidden, "only moderators can force saving duplicate trails")
+	}
+	return force, nil
+}
+
+func (h *TrailsHandler) SuggestTrailsHandler(c *gin.Context) {
+	errs := &models.ValidationError{}
+	prefix := c.Query("prefix")
+	if prefix == "" {
+		errs.Add("prefix", "prefix is required")
+	} else if len(models.Tokenize(prefix)) == 0 {
+		errs.Add("prefix", "invalid prefix: must contain a letter or digit")
+	}
+	limit := defaultSuggestLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		val, err := strconv.Atoi(limitStr)
+		if err != nil {
+			errs.Addf("limit", "invalid limit: %v", err)
+		} else if val < 1 || val > maxSuggestLimit {
+			errs.Addf("limit", "invalid limit: must be between 1 and %d", maxSuggestLimit)
+		} else {
+			limit = val
+		}
+	}
+	if err := errs.Err(); err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+
+	suggestions, err := h.service.SuggestTrails(c.Request.Context(), prefix, limit)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.TrailSuggestions{Suggestions: suggestions})
+}
+
+func (h *TrailsHandler) listTrails(c *gin.Context, filter *models.TrailFilter) {
+	geoJSON, err := wantsGeoJSON(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
+		return
+	}
+	polyline, err := wantsPolylineTracks(c)
+	if err != nil {
+		middleware.AbortWithError(c, err)
 		return
 	}
 
-	trails, err := h.service.GetAllTrails(c.Request.Context(), filter)
+	page, err := h.service.ListTrails(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.AbortWithError(c, err)
 		return
 	}
+
+	if geoJSON {
+		collection, err := page.ToGeoJSON()
+		if err != nil {
+			middleware.AbortWithError(c, err)
+			return
+		}
+		c.Header("Content-Type", models.GeoJSONContentType)
+		c.JSON(http.StatusOK, collection)
+		return
+	}
+
 	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, trails)
+	if polyline {
+		c.JSON(http.StatusOK, page.WithPolylineTracks())
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func wantsPolylineTracks(c *gin.Context) (bool, error) {
+	switch format := c.Query("track-format"); format {
+	case "polyline":
+		return true, nil
+	case "", "points":
+		return false, nil
+	default:
+		return false, models.NewValidationError("track-format", fmt.Sprintf("invalid track-format: %s", format))
+	}
+}
+
+func wantsGeoJSON(c *gin.Context) (bool, error) {
+	switch format := c.Query("format"); format {
+	case "geojson":
+		return true, nil
+	case "json":
+		return false, nil
+	case "":
+	default:
+		return false, models.NewValidationError("format", fmt.Sprintf("invalid format: %s", format))
+	}
+
+	for _, accept := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(accept), ";")
+		if strings.EqualFold(mediaType, models.GeoJSONContentType) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func parseFilter(query url.Values) (*models.TrailFilter, error) {
@@ -81,73 +549,224 @@ func parseFilter(query url.Values) (*models.TrailFilter, error) {
 	var lat *float64
 	var lon *float64
 	var radiusKm *float64
-	var difficulty *models.TrailDifficulty
+	var difficulties []models.TrailDifficulty
 	var lengthKm *float64
+	var minLengthKm *float64
+	var maxLengthKm *float64
+	var createdAfter *time.Time
+	var createdBefore *time.Time
+	var sortBy *models.TrailSortField
+	var sortDesc bool
+	var cursor *models.TrailCursor
+	var owner *string
+	var bbox *models.BBox
+	var nearest *int
+	var textQuery *models.TextQuery
+	limit := defaultPageLimit
+	errs := &models.ValidationError{}
 
 	nameStr := query.Get("name")
 	if nameStr != "" {
 		name = &nameStr
 	}
 
+	qStr := query.Get("q")
+	if qStr != "" {
+		textQuery = models.NewTextQuery(qStr, false)
+	}
+
 	latStr := query.Get("lat")
 	if latStr != "" {
 		val, err := strconv.ParseFloat(latStr, 64)
 		if err != nil {
-			return nil, fmt.Errorf("invalid lat: %w", err)
+			errs.Addf("lat", "invalid lat: %v", err)
+		} else {
+			lat = &val
 		}
-		lat = &val
 	}
 
 	lonStr := query.Get("lon")
 	if lonStr != "" {
 		val, err := strconv.ParseFloat(lonStr, 64)
 		if err != nil {
-			return nil, fmt.Errorf("invalid min_lng: %w", err)
+			errs.Addf("lon", "invalid lon: %v", err)
+		} else {
+			lon = &val
 		}
-		lon = &val
 	}
 
 	radiusKmStr := query.Get("radius-km")
 	if radiusKmStr != "" {
 		val, err := strconv.ParseFloat(radiusKmStr, 64)
 		if err != nil {
-			return nil, fmt.Errorf("invalid radius-km: %w", err)
+			errs.Addf("radius-km", "invalid radius-km: %v", err)
+		} else {
+			radiusKm = &val
+		}
+	}
+
+	nearestStr := query.Get("nearest")
+	if nearestStr != "" {
+		val, err := strconv.Atoi(nearestStr)
+		if err != nil {
+			errs.Addf("nearest", "invalid nearest: %v", err)
+		} else if val > maxNearest {
+			errs.Addf("nearest", "invalid nearest: must be at most %d", maxNearest)
+		} else {
+			nearest = &val
 		}
-		radiusKm = &val
 	}
 
 	difficultyStr := query.Get("difficulty")
 	if difficultyStr != "" {
-		if !models.IsValidTrailDifficulty(difficultyStr) {
-			return nil, fmt.Errorf("invalid difficulty: %s", difficultyStr)
+		for _, val := range strings.Split(difficultyStr, ",") {
+			val = strings.TrimSpace(val)
+			if !models.IsValidTrailDifficulty(val) {
+				errs.Addf("difficulty", "invalid difficulty: %s", val)
+			} else {
+				difficulties = append(difficulties, models.TrailDifficulty(val))
+			}
 		}
-		dif := models.TrailDifficulty(difficultyStr)
-		difficulty = &dif
 	}
 
 	lengthKmStr := query.Get("length-km")
 	if lengthKmStr != "" {
 		val, err := strconv.ParseFloat(lengthKmStr, 64)
 		if err != nil {
-			return nil, fmt.Errorf("invalid length-km: %w", err)
+			errs.Addf("length-km", "invalid length-km: %v", err)
+		} else {
+			lengthKm = &val
+		}
+	}
+
+	minLengthKmStr := query.Get("min-length-km")
+	if minLengthKmStr != "" {
+		val, err := strconv.ParseFloat(minLengthKmStr, 64)
+		if err != nil {
+			errs.Addf("min-length-km", "invalid min-length-km: %v", err)
+		} else {
+			minLengthKm = &val
+		}
+	}
+
+	maxLengthKmStr := query.Get("max-length-km")
+	if maxLengthKmStr != "" {
+		val, err := strconv.ParseFloat(maxLengthKmStr, 64)
+		if err != nil {
+			errs.Addf("max-length-km", "invalid max-length-km: %v", err)
+		} else {
+			maxLengthKm = &val
+		}
+	}
+
+	createdAfterStr := query.Get("created-after")
+	if createdAfterStr != "" {
+		val, err := parseTimeParam(createdAfterStr)
+		if err != nil {
+			errs.Addf("created-after", "invalid created-after: %v", err)
+		} else {
+			createdAfter = &val
+		}
+	}
+
+	createdBeforeStr := query.Get("created-before")
+	if createdBeforeStr != "" {
+		val, err := parseTimeParam(createdBeforeStr)
+		if err != nil {
+			errs.Addf("created-before", "invalid created-before: %v", err)
+		} else {
+			createdBefore = &val
+		}
+	}
+
+	sortStr := query.Get("sort")
+	if sortStr != "" {
+		// A leading "-" reverses the order, e.g. sort=-length_km
+		field := strings.TrimPrefix(sortStr, "-")
+		if !models.IsValidTrailSortField(field) {
+			errs.Addf("sort", "invalid sort: %s", sortStr)
+		} else {
+			sf := models.TrailSortField(field)
+			sortBy = &sf
+			sortDesc = strings.HasPrefix(sortStr, "-")
+		}
+	}
+
+	limitStr := query.Get("limit")
+	if limitStr != "" {
+		val, err := strconv.Atoi(limitStr)
+		if err != nil {
+			errs.Addf("limit", "invalid limit: %v", err)
+		} else if val > maxPageLimit {
+			errs.Addf("limit", "invalid limit: must be at most %d", maxPageLimit)
+		} else {
+			limit = val
+		}
+	}
+
+	ownerStr := query.Get("owner")
+	if ownerStr != "" {
+		val := models.NormalizeUsername(ownerStr)
+		owner = &val
+	}
+
+	bboxStr := query.Get("bbox")
+	if bboxStr != "" {
+		val, err := models.ParseBBox(bboxStr)
+		if err != nil {
+			errs.Add("bbox", err.Error())
+		} else {
+			bbox = val
+		}
+	}
+
+	cursorStr := query.Get("cursor")
+	if cursorStr != "" {
+		val, err := models.ParseTrailCursor(cursorStr)
+		if err != nil {
+			errs.Add("cursor", err.Error())
+		} else {
+			cursor = val
 		}
-		lengthKm = &val
 	}
 
 	filter := &models.TrailFilter{
 		CreateTrailRequest: models.CreateTrailRequest{
-			Name:       name,
-			Lat:        lat,
-			Lon:        lon,
-			Difficulty: difficulty,
-			LengthKm:   lengthKm,
+			Name:     name,
+			Lat:      lat,
+			Lon:      lon,
+			LengthKm: lengthKm,
 		},
-		RadiusKm: radiusKm,
+		RadiusKm:      radiusKm,
+		SortBy:        sortBy,
+		SortDesc:      sortDesc,
+		Limit:         &limit,
+		Cursor:        cursor,
+		Owner:         owner,
+		BBox:          bbox,
+		Difficulties:  difficulties,
+		MinLengthKm:   minLengthKm,
+		MaxLengthKm:   maxLengthKm,
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+		Query:         textQuery,
+		Nearest:       nearest,
 	}
 
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
 	if err := filter.Validate(); err != nil {
 		return nil, err
 	}
 
 	return filter, nil
 }
+
+// parseTimeParam takes a plain date as midnight UTC
+func parseTimeParam(s string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, s); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
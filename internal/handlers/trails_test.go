@@ -0,0 +1,1309 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/services"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTrailsRouter serves the trail handlers to a moderator over memory storage seeded with one trail plus any others given
+func newTestTrailsRouter(t *testing.T, others ...*models.Trail) (*gin.Engine, *models.Trail) {
+	return newTestTrailsRouterAs(t, &models.Claims{Username: "ranger", Role: models.RoleModerator}, others...)
+}
+
+// newTestTrailsRouterAs is newTestTrailsRouter with every request made as the user in claims
+func newTestTrailsRouterAs(t *testing.T, claims *models.Claims, others ...*models.Trail) (*gin.Engine, *models.Trail) {
+	gin.SetMode(gin.TestMode)
+
+	trailStorage := storage.NewTrailStorage()
+	trail := models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53)
+	for _, seeded := range append([]*models.Trail{trail}, others...) {
+		require.NoError(t, trailStorage.Save(context.Background(), seeded))
+	}
+
+	rater := &models.ShenandoahRater{MediumScore: models.DefaultMediumDifficultyScore, HardScore: models.DefaultHardDifficultyScore}
+	duplicates := config.DuplicateConfig{RadiusKm: config.DUPLICATE_TRAIL_RADIUS_KM, Threshold: config.DUPLICATE_TRAIL_THRESHOLD}
+	handler := NewTrailsHandler(services.NewTrailsService(trailStorage, rater, duplicates))
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("claims", claims)
+	})
+	router.POST("/trails", handler.CreateTrailHandler)
+	router.GET("/trails", handler.ListTrailsHandler)
+	router.POST("/trails/search", handler.SearchTrailsHandler)
+	router.GET("/trails/suggest", handler.SuggestTrailsHandler)
+	router.GET("/me/trails", handler.ListMyTrailsHandler)
+	router.POST("/trails/import/gpx", handler.ImportGPXHandler)
+	router.GET("/trails/:uid", handler.GetTrailsHandler)
+	router.GET("/trails/:uid/export", handler.ExportTrailHandler)
+	router.GET("/trails/:uid/elevation-profile", handler.ElevationProfileHandler)
+	router.PUT("/trails/:uid", handler.UpdateTrailHandler)
+	router.PATCH("/trails/:uid", handler.PatchTrailHandler)
+	router.DELETE("/trails/:uid", handler.DeleteTrailHandler)
+	return router, trail
+}
+
+func TestUpdateTrailHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		uid            func(trail *models.Trail) string
+		body           string
+		expectedStatus int
+		expectedName   string
+	}{
+		{
+			name:           "successful replace",
+			uid:            func(trail *models.Trail) string { return trail.UID.String() },
+			body:           `{"name":"Slough Creek Trail","lat":44.9446,"lon":-110.3066,"difficulty":"medium","length_km":17.7}`,
+			expectedStatus: http.StatusOK,
+			expectedName:   "Slough Creek Trail",
+		},
+		{
+			name:           "missing required field",
+			uid:            func(trail *models.Trail) string { return trail.UID.String() },
+			body:           `{"name":"Slough Creek Trail","lat":44.9446,"lon":-110.3066,"difficulty":"medium"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "trail not found",
+			uid:            func(trail *models.Trail) string { return uuid.New().String() },
+			body:           `{"name":"Slough Creek Trail","lat":44.9446,"lon":-110.3066,"difficulty":"medium","length_km":17.7}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, trail := newTestTrailsRouter(t)
+
+			req := httptest.NewRequest(http.MethodPut, "/trails/"+tt.uid(trail), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var updated models.Trail
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+				assert.Equal(t, trail.UID, updated.UID)
+				assert.Equal(t, tt.expectedName, *updated.Name)
+			}
+		})
+	}
+}
+
+func TestUpdateTrailHandlerDuplicates(t *testing.T) {
+	tests := []struct {
+		name           string
+		role           models.Role
+		otherName      string
+		method         string
+		url            string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "renamed onto an existing trail",
+			role:           models.RoleContributor,
+			otherName:      "Slough Creek Trail",
+			method:         http.MethodPatch,
+			body:           `{"name":"Lamar River"}`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "replaced with an existing trail",
+			role:           models.RoleContributor,
+			otherName:      "Slough Creek Trail",
+			method:         http.MethodPut,
+			body:           `{"name":"Lamar Rivr Trail","lat":44.85,"lon":-109.63,"difficulty":"hard","length_km":53}`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "moderator can force",
+			role:           models.RoleModerator,
+			otherName:      "Slough Creek Trail",
+			method:         http.MethodPatch,
+			url:            "?force=true",
+			body:           `{"name":"Lamar River"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "contributor cannot force",
+			role:           models.RoleContributor,
+			otherName:      "Slough Creek Trail",
+			method:         http.MethodPatch,
+			url:            "?force=true",
+			body:           `{"name":"Lamar River"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "other changes to a forced duplicate",
+			role:           models.RoleContributor,
+			otherName:      "Lamar River Trail",
+			method:         http.MethodPatch,
+			body:           `{"length_km":54}`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := models.NewTrail(tt.otherName, 44.85, -109.63, models.TrailDifficultyMedium, 17.7)
+			other.CreatedBy = "ranger"
+			router, trail := newTestTrailsRouterAs(t, &models.Claims{Username: "ranger", Role: tt.role}, other)
+
+			req := httptest.NewRequest(tt.method, "/trails/"+other.UID.String()+tt.url, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedStatus == http.StatusConflict {
+				var problem models.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+				assert.Equal(t, []string{trail.UID.String()}, problem.Conflicts)
+			}
+		})
+	}
+}
+
+func TestPatchTrailHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		uid            func(trail *models.Trail) string
+		body           string
+		expectedStatus int
+		expectedName   string
+		expectedLength float64
+	}{
+		{
+			name:           "partial update keeps other fields",
+			uid:            func(trail *models.Trail) string { return trail.UID.String() },
+			body:           `{"length_km":54.5}`,
+			expectedStatus: http.StatusOK,
+			expectedName:   "Lamar River Trail",
+			expectedLength: 54.5,
+		},
+		{
+			name:           "invalid patched value",
+			uid:            func(trail *models.Trail) string { return trail.UID.String() },
+			body:           `{"lat":120}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "null clears a required field",
+			uid:            func(trail *models.Trail) string { return trail.UID.String() },
+			body:           `{"name":null}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "trail not found",
+			uid:            func(trail *models.Trail) string { return uuid.New().String() },
+			body:           `{"length_km":54.5}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, trail := newTestTrailsRouter(t)
+
+			req := httptest.NewRequest(http.MethodPatch, "/trails/"+tt.uid(trail), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var updated models.Trail
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+				assert.Equal(t, tt.expectedName, *updated.Name)
+				assert.Equal(t, tt.expectedLength, *updated.LengthKm)
+			}
+		})
+	}
+}
+
+func TestDeleteTrailHandler(t *testing.T) {
+	router, trail := newTestTrailsRouter(t)
+
+	tests := []struct {
+		name           string
+		uid            string
+		expectedStatus int
+	}{
+		{
+			name:           "successful deletion",
+			uid:            trail.UID.String(),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "already deleted",
+			uid:            trail.UID.String(),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "trail not found",
+			uid:            uuid.New().String(),
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/trails/"+tt.uid, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestTrailOwnership(t *testing.T) {
+	owned := models.NewTrail("Slough Creek Trail", 44.9446, -110.3066, models.TrailDifficultyMedium, 17.7)
+	owned.CreatedBy = "ranger"
+
+	tests := []struct {
+		name           string
+		claims         *models.Claims
+		method         string
+		trail          func(seeded *models.Trail) *models.Trail
+		expectedStatus int
+	}{
+		{
+			name:           "creator can patch",
+			claims:         &models.Claims{Username: "ranger", Role: models.RoleContributor},
+			method:         http.MethodPatch,
+			trail:          func(*models.Trail) *models.Trail { return owned },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "creator can delete",
+			claims:         &models.Claims{Username: "ranger", Role: models.RoleContributor},
+			method:         http.MethodDelete,
+			trail:          func(*models.Trail) *models.Trail { return owned },
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "another contributor cannot patch",
+			claims:         &models.Claims{Username: "hiker", Role: models.RoleContributor},
+			method:         http.MethodPatch,
+			trail:          func(*models.Trail) *models.Trail { return owned },
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "another contributor cannot delete",
+			claims:         &models.Claims{Username: "hiker", Role: models.RoleContributor},
+			method:         http.MethodDelete,
+			trail:          func(*models.Trail) *models.Trail { return owned },
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "contributor cannot change a trail without a creator",
+			claims:         &models.Claims{Username: "ranger", Role: models.RoleContributor},
+			method:         http.MethodPatch,
+			trail:          func(seeded *models.Trail) *models.Trail { return seeded },
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "moderator can change any trail",
+			claims:         &models.Claims{Username: "warden", Role: models.RoleModerator},
+			method:         http.MethodPatch,
+			trail:          func(*models.Trail) *models.Trail { return owned },
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, seeded := newTestTrailsRouterAs(t, tt.claims, owned)
+
+			req := httptest.NewRequest(tt.method, "/trails/"+tt.trail(seeded).UID.String(), bytes.NewBufferString(`{"length_km":18.2}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestCreateTrailHandlerRecordsCreator(t *testing.T) {
+	router, _ := newTestTrailsRouterAs(t, &models.Claims{Username: "ranger", Role: models.RoleContributor})
+
+	req := httptest.NewRequest(http.MethodPost, "/trails", bytes.NewBufferString(`{"name":"Slough Creek Trail","lat":44.9446,"lon":-110.3066,"difficulty":"medium","length_km":17.7}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created models.Trail
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "ranger", created.CreatedBy)
+	assert.Equal(t, "ranger", created.UpdatedBy)
+}
+
+func TestCreateTrailHandlerDuplicates(t *testing.T) {
+	const misspelled = `{"name":"Lamar Rivr Trail","lat":44.85,"lon":-109.63,"difficulty":"hard","length_km":53}`
+
+	tests := []struct {
+		name              string
+		role              models.Role
+		url               string
+		expectedStatus    int
+		expectedConflicts bool
+	}{
+		{
+			name:              "misspelled name nearby",
+			role:              models.RoleContributor,
+			url:               "/trails",
+			expectedStatus:    http.StatusConflict,
+			expectedConflicts: true,
+		},
+		{
+			name:           "moderator can force",
+			role:           models.RoleModerator,
+			url:            "/trails?force=true",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "contributor cannot force",
+			role:           models.RoleContributor,
+			url:            "/trails?force=true",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "invalid force",
+			role:           models.RoleModerator,
+			url:            "/trails?force=maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, trail := newTestTrailsRouterAs(t, &models.Claims{Username: "ranger", Role: tt.role})
+
+			req := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString(misspelled))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedConflicts {
+				var problem models.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+				assert.Equal(t, []string{trail.UID.String()}, problem.Conflicts)
+			}
+		})
+	}
+}
+
+func TestPatchTrailHandlerRecordsEditor(t *testing.T) {
+	router, trail := newTestTrailsRouter(t)
+
+	req := httptest.NewRequest(http.MethodPatch, "/trails/"+trail.UID.String(), bytes.NewBufferString(`{"length_km":54}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var updated models.Trail
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Empty(t, updated.CreatedBy)
+	assert.Equal(t, "ranger", updated.UpdatedBy)
+}
+
+func TestPatchTrailHandlerRatesDifficultyAgain(t *testing.T) {
+	router, _ := newTestTrailsRouter(t)
+	send := func(method, url, body string) *models.Trail {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Contains(t, []int{http.StatusOK, http.StatusCreated}, w.Code, w.Body.String())
+		var trail models.Trail
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trail))
+		return &trail
+	}
+	const gentle = `{"track":[{"lat":46.0,"lon":-112.0,"ele":1000},{"lat":46.004,"lon":-112.0,"ele":1010}]}`
+	const steep = `{"track":[{"lat":46.0,"lon":-112.0,"ele":1000},{"lat":46.05,"lon":-112.0,"ele":4000}]}`
+
+	created := send(http.MethodPost, "/trails", `{"name":"Gallatin Crest","track":[{"lat":46.0,"lon":-112.0,"ele":1000},{"lat":46.004,"lon":-112.0,"ele":1010}]}`)
+	assert.Equal(t, models.TrailDifficultyEasy, *created.Difficulty)
+	assert.True(t, created.DifficultyRated)
+	url := "/trails/" + created.UID.String()
+
+	// A new track rates the difficulty again
+	updated := send(http.MethodPatch, url, steep)
+	assert.Equal(t, models.TrailDifficultyHard, *updated.Difficulty)
+	assert.True(t, updated.DifficultyRated)
+
+	// Once a client gives the difficulty it is kept whatever the track
+	updated = send(http.MethodPatch, url, `{"difficulty":"medium"}`)
+	assert.Equal(t, models.TrailDifficultyMedium, *updated.Difficulty)
+	assert.False(t, updated.DifficultyRated)
+	updated = send(http.MethodPatch, url, gentle)
+	assert.Equal(t, models.TrailDifficultyMedium, *updated.Difficulty)
+	assert.False(t, updated.DifficultyRated)
+}
+
+func TestListMyTrailsHandler(t *testing.T) {
+	mine := models.NewTrail("Slough Creek Trail", 44.9446, -110.3066, models.TrailDifficultyMedium, 17.7)
+	mine.CreatedBy = "ranger"
+	theirs := models.NewTrail("Trail of Ten Falls", 43.8242, -121.5654, models.TrailDifficultyMedium, 10)
+	theirs.CreatedBy = "hiker"
+	router, _ := newTestTrailsRouterAs(t, &models.Claims{Username: "ranger", Role: models.RoleContributor}, mine, theirs)
+
+	tests := []struct {
+		name          string
+		url           string
+		expectedNames []string
+	}{
+		{
+			name:          "my trails",
+			url:           "/me/trails",
+			expectedNames: []string{"Slough Creek Trail"},
+		},
+		{
+			name:          "my trails ignore the owner parameter",
+			url:           "/me/trails?owner=hiker",
+			expectedNames: []string{"Slough Creek Trail"},
+		},
+		{
+			name:          "filter any list by owner",
+			url:           "/trails?owner=Hiker",
+			expectedNames: []string{"Trail of Ten Falls"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var page models.TrailPage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			names := make([]string, len(page.Trails))
+			for i, trail := range page.Trails {
+				names[i] = *trail.Name
+			}
+			assert.ElementsMatch(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func TestListTrailsHandlerPagination(t *testing.T) {
+	router, _ := newTestTrailsRouter(t,
+		models.NewTrail("Trail of Ten Falls", 44.8779, -122.6554, models.TrailDifficultyEasy, 12.5),
+		models.NewTrail("Angel's Rest", 45.6789, -122.3456, models.TrailDifficultyMedium, 7.8),
+	)
+
+	// Walk the listing one trail at a time until the cursor runs out
+	var names []string
+	url := "/trails?sort=-name&limit=1"
+	for url != "" {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var page models.TrailPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, 3, page.Total)
+		for _, trail := range page.Trails {
+			names = append(names, *trail.Name)
+		}
+
+		url = ""
+		if page.NextCursor != nil {
+			url = "/trails?sort=-name&limit=1&cursor=" + *page.NextCursor
+		}
+	}
+	assert.Equal(t, []string{"Trail of Ten Falls", "Lamar River Trail", "Angel's Rest"}, names)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{name: "invalid sort", url: "/trails?sort=elevation", expectedStatus: http.StatusBadRequest},
+		{name: "invalid limit", url: "/trails?limit=abc", expectedStatus: http.StatusBadRequest},
+		{name: "limit too large", url: "/trails?limit=1000", expectedStatus: http.StatusBadRequest},
+		{name: "invalid cursor", url: "/trails?cursor=abc", expectedStatus: http.StatusBadRequest},
+		{name: "distance sort without radius", url: "/trails?sort=distance", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestListTrailsHandlerFilters(t *testing.T) {
+	tenFalls := models.NewTrail("Trail of Ten Falls", 44.8779, -122.6554, models.TrailDifficultyEasy, 12.5)
+	angelsRest := models.NewTrail("Angel's Rest", 45.6789, -122.3456, models.TrailDifficultyMedium, 7.8)
+	june := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	july := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	tenFalls.CreatedAt = &june
+	angelsRest.CreatedAt = &july
+	router, _ := newTestTrailsRouter(t, tenFalls, angelsRest)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedNames  []string
+	}{
+		{
+			name:           "several difficulties",
+			url:            "/trails?sort=name&difficulty=easy,medium",
+			expectedStatus: http.StatusOK,
+			expectedNames:  []string{"Angel's Rest", "Trail of Ten Falls"},
+		},
+		{
+			name:           "length range",
+			url:            "/trails?sort=name&min-length-km=10&max-length-km=20",
+			expectedStatus: http.StatusOK,
+			expectedNames:  []string{"Trail of Ten Falls"},
+		},
+		{
+			name:           "created after a date",
+			url:            "/trails?sort=name&created-after=2024-06-15",
+			expectedStatus: http.StatusOK,
+			expectedNames:  []string{"Angel's Rest"},
+		},
+		{
+			name:           "created between timestamps",
+			url:            "/trails?sort=name&created-after=2024-01-01T00:00:00Z&created-before=2024-06-01T00:00:01Z",
+			expectedStatus: http.StatusOK,
+			expectedNames:  []string{"Trail of Ten Falls"},
+		},
+		{name: "unknown difficulty in list", url: "/trails?difficulty=easy,extreme", expectedStatus: http.StatusBadRequest},
+		{name: "invalid min length", url: "/trails?min-length-km=short", expectedStatus: http.StatusBadRequest},
+		{name: "min length above max", url: "/trails?min-length-km=20&max-length-km=10", expectedStatus: http.StatusBadRequest},
+		{name: "invalid date", url: "/trails?created-after=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "dates reversed", url: "/trails?created-after=2024-07-01&created-before=2024-06-01", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var page models.TrailPage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			names := make([]string, len(page.Trails))
+			for i, trail := range page.Trails {
+				names[i] = *trail.Name
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func TestTextSearch(t *testing.T) {
+	router, trail := newTestTrailsRouter(t,
+		models.NewTrail("Lamar River Cutoff Trail", 44.85, -109.63, models.TrailDifficultyHard, 20),
+		models.NewTrail("Lámar Valley Loop", 44.9, -110.2, models.TrailDifficultyEasy, 8),
+		models.NewTrail("Angel's Rest", 45.6789, -122.3456, models.TrailDifficultyMedium, 7.8),
+	)
+
+	t.Run("q ranks matches by relevance", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trails?q=lamar+rivr", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var page models.TrailPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Trails, 2)
+		assert.Equal(t, trail.UID, page.Trails[0].UID)
+		assert.Equal(t, "Lamar River Cutoff Trail", *page.Trails[1].Name)
+	})
+
+	t.Run("q pages by relevance", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trails?q=lamar&limit=2", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var page models.TrailPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, 3, page.Total)
+		require.NotNil(t, page.NextCursor)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trails?q=lamar&limit=2&cursor="+*page.NextCursor, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Trails, 1)
+		assert.Equal(t, "Lamar River Cutoff Trail", *page.Trails[0].Name)
+	})
+
+	t.Run("suggest", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trails/suggest?prefix=angels+re", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var suggestions models.TrailSuggestions
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &suggestions))
+		require.Len(t, suggestions.Suggestions, 1)
+		assert.Equal(t, "Angel's Rest", suggestions.Suggestions[0].Name)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trails/suggest?prefix=lam&limit=1", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &suggestions))
+		assert.Len(t, suggestions.Suggestions, 1)
+	})
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{name: "q without words", url: "/trails?q=%21%21", expectedStatus: http.StatusBadRequest},
+		{name: "relevance sort without q", url: "/trails?sort=relevance", expectedStatus: http.StatusBadRequest},
+		{name: "suggest without prefix", url: "/trails/suggest", expectedStatus: http.StatusBadRequest},
+		{name: "suggest limit too large", url: "/trails/suggest?prefix=lam&limit=500", expectedStatus: http.StatusBadRequest},
+		{name: "suggest prefix without words", url: "/trails/suggest?prefix=%21", expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestListNearestTrails(t *testing.T) {
+	router, _ := newTestTrailsRouter(t,
+		models.NewTrail("Trail of Ten Falls", 44.8779, -122.6554, models.TrailDifficultyEasy, 12.5),
+		models.NewTrail("Angel's Rest", 45.6789, -122.3456, models.TrailDifficultyMedium, 7.8),
+	)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trails?lat=45.5&lon=-122.5&nearest=2", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var page models.TrailPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 2, page.Total)
+	require.Len(t, page.Trails, 2)
+	assert.Equal(t, "Angel's Rest", *page.Trails[0].Name)
+	assert.InDelta(t, 23.2, *page.Trails[0].DistanceKm, 0.1)
+	assert.InDelta(t, 31.1, *page.Trails[0].BearingDeg, 0.1)
+	assert.Equal(t, "Trail of Ten Falls", *page.Trails[1].Name)
+	assert.InDelta(t, 70.2, *page.Trails[1].DistanceKm, 0.1)
+	assert.InDelta(t, 190.0, *page.Trails[1].BearingDeg, 0.1)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{name: "without a point", url: "/trails?nearest=2", expectedStatus: http.StatusBadRequest},
+		{name: "not a number", url: "/trails?lat=45.5&lon=-122.5&nearest=two", expectedStatus: http.StatusBadRequest},
+		{name: "too many", url: "/trails?lat=45.5&lon=-122.5&nearest=1000", expectedStatus: http.StatusBadRequest},
+		{name: "zero", url: "/trails?lat=45.5&lon=-122.5&nearest=0", expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestSearchTrailsInArea(t *testing.T) {
+	router, _ := newTestTrailsRouter(t,
+		models.NewTrail("Trail of Ten Falls", 44.8779, -122.6554, models.TrailDifficultyEasy, 12.5),
+		models.NewTrail("Angel's Rest", 45.6789, -122.3456, models.TrailDifficultyMedium, 7.8),
+	)
+
+	oregon := `{"type":"Polygon","coordinates":[[[-124.5,42],[-116.5,42],[-116.5,46.2],[-124.5,46.2],[-124.5,42]]]}`
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		expectedStatus int
+		expectedNames  []string
+	}{
+		{
+			name:           "bbox query",
+			method:         http.MethodGet,
+			url:            "/trails?bbox=-123,45,-122,46",
+			expectedStatus: http.StatusOK,
+			expectedNames:  []string{"Angel's Rest"},
+		},
+		{
+			name:           "polygon",
+			method:         http.MethodPost,
+			url:            "/trails/search?sort=name",
+			body:           oregon,
+			expectedStatus: http.StatusOK,
+			expectedNames:  []string{"Angel's Rest", "Trail of Ten Falls"},
+		},
+		{
+			name:           "feature with query filters",
+			method:         http.MethodPost,
+			url:            "/trails/search?difficulty=easy",
+			body:           `{"type":"Feature","properties":{"name":"Oregon"},"geometry":` + oregon + `}`,
+			expectedStatus: http.StatusOK,
+			expectedNames:  []string{"Trail of Ten Falls"},
+		},
+		{
+			name:           "invalid bbox",
+			method:         http.MethodGet,
+			url:            "/trails?bbox=-123,46,-122,45",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unclosed ring",
+			method:         http.MethodPost,
+			url:            "/trails/search",
+			body:           `{"type":"Polygon","coordinates":[[[-124.5,42],[-116.5,42],[-116.5,46.2],[-124.5,46.2]]]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "not an area",
+			method:         http.MethodPost,
+			url:            "/trails/search",
+			body:           `{"type":"Point","coordinates":[-122,45]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var page models.TrailPage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			names := make([]string, len(page.Trails))
+			for i, trail := range page.Trails {
+				names[i] = *trail.Name
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func TestGeoJSONNegotiation(t *testing.T) {
+	router, trail := newTestTrailsRouter(t)
+
+	tests := []struct {
+		name                string
+		url                 string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedType        string
+	}{
+		{
+			name:                "single trail with accept header",
+			url:                 "/trails/" + trail.UID.String(),
+			accept:              "application/geo+json",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/geo+json",
+			expectedType:        "Feature",
+		},
+		{
+			name:                "listing with format parameter",
+			url:                 "/trails?format=geojson",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/geo+json",
+			expectedType:        "FeatureCollection",
+		},
+		{
+			name:                "listing with weighted accept header",
+			url:                 "/trails",
+			accept:              "application/json;q=0.5, application/geo+json",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/geo+json",
+			expectedType:        "FeatureCollection",
+		},
+		{
+			name:                "plain json by default",
+			url:                 "/trails/" + trail.UID.String(),
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+		},
+		{
+			name:           "unknown format",
+			url:            "/trails?format=kml",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedContentType != "" {
+				assert.Equal(t, tt.expectedContentType, w.Header().Get("Content-Type"))
+			}
+			if tt.expectedType != "" {
+				var body map[string]any
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.expectedType, body["type"])
+			}
+		})
+	}
+}
+
+const testImportGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <name>Slough Creek Trail</name>
+    <trkseg>
+      <trkpt lat="44.9446" lon="-110.3066"><ele>1900</ele></trkpt>
+      <trkpt lat="44.9500" lon="-110.3000"><ele>1920</ele></trkpt>
+    </trkseg>
+  </trk>
+  <trk>
+    <name>Lamar River Trail</name>
+    <trkseg>
+      <trkpt lat="44.8472" lon="-109.6278"/>
+      <trkpt lat="44.8500" lon="-109.6200"/>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestImportGPXHandler(t *testing.T) {
+	multipartBody := func() (*bytes.Buffer, string) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		part, _ := writer.CreateFormFile("file", "hikes.gpx")
+		part.Write([]byte(testImportGPX))
+		writer.Close()
+		return &buf, writer.FormDataContentType()
+	}
+
+	tests := []struct {
+		name            string
+		url             string
+		body            func() (*bytes.Buffer, string)
+		expectedStatus  int
+		expectedCreated []string
+		expectedFailed  []string
+		// conflicts is whether the failures are duplicates of the seeded trail
+		conflicts bool
+	}{
+		{
+			name: "raw body skips existing duplicate",
+			url:  "/trails/import/gpx?difficulty=medium",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString(testImportGPX), "application/gpx+xml"
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedCreated: []string{"Slough Creek Trail"},
+			expectedFailed:  []string{"Lamar River Trail"},
+			conflicts:       true,
+		},
+		{
+			name: "forced import keeps duplicates",
+			url:  "/trails/import/gpx?difficulty=medium&force=true",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString(testImportGPX), "application/gpx+xml"
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedCreated: []string{"Slough Creek Trail", "Lamar River Trail"},
+			expectedFailed:  []string{},
+		},
+		{
+			name:            "multipart upload",
+			url:             "/trails/import/gpx?difficulty=easy",
+			body:            multipartBody,
+			expectedStatus:  http.StatusCreated,
+			expectedCreated: []string{"Slough Creek Trail"},
+			expectedFailed:  []string{"Lamar River Trail"},
+			conflicts:       true,
+		},
+		{
+			name: "missing difficulty rates trails with elevation",
+			url:  "/trails/import/gpx",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString(testImportGPX), "application/gpx+xml"
+			},
+			expectedStatus:  http.StatusCreated,
+			expectedCreated: []string{"Slough Creek Trail"},
+			expectedFailed:  []string{"Lamar River Trail"},
+		},
+		{
+			name: "invalid difficulty",
+			url:  "/trails/import/gpx?difficulty=extreme",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString(testImportGPX), "application/gpx+xml"
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "invalid gpx",
+			url:  "/trails/import/gpx?difficulty=easy",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString("<gpx></gpx>"), "application/gpx+xml"
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, seeded := newTestTrailsRouter(t)
+
+			body, contentType := tt.body()
+			req := httptest.NewRequest(http.MethodPost, tt.url, body)
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+
+			var result struct {
+				Created []*models.Trail `json:"created"`
+				Failed  []struct {
+					Name      string   `json:"name"`
+					Conflicts []string `json:"conflicts"`
+				} `json:"failed"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+
+			created := make([]string, len(result.Created))
+			for i, trail := range result.Created {
+				created[i] = *trail.Name
+				assert.Len(t, trail.Track, 2)
+			}
+			failed := make([]string, len(result.Failed))
+			for i, f := range result.Failed {
+				failed[i] = f.Name
+				if tt.conflicts {
+					assert.Equal(t, []string{seeded.UID.String()}, f.Conflicts)
+				}
+			}
+			assert.Equal(t, tt.expectedCreated, created)
+			assert.Equal(t, tt.expectedFailed, failed)
+		})
+	}
+}
+
+func TestExportTrailHandler(t *testing.T) {
+	router, trail := newTestTrailsRouter(t)
+
+	tests := []struct {
+		name                string
+		url                 string
+		expectedStatus      int
+		expectedContentType string
+		expectedDisposition string
+	}{
+		{
+			name:                "gpx by default",
+			url:                 "/trails/" + trail.UID.String() + "/export",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/gpx+xml",
+			expectedDisposition: `attachment; filename=lamar-river-trail.gpx`,
+		},
+		{
+			name:                "kml",
+			url:                 "/trails/" + trail.UID.String() + "/export?format=kml",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/vnd.google-earth.kml+xml",
+			expectedDisposition: `attachment; filename=lamar-river-trail.kml`,
+		},
+		{
+			name:           "unknown format",
+			url:            "/trails/" + trail.UID.String() + "/export?format=shp",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "trail not found",
+			url:            "/trails/" + uuid.New().String() + "/export",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.expectedContentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, tt.expectedDisposition, w.Header().Get("Content-Disposition"))
+				assert.Contains(t, w.Body.String(), "<name>Lamar River Trail</name>")
+			}
+		})
+	}
+}
+
+func TestCreateTrailHandlerWithTrack(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "track as points derives start and length",
+			body:           `{"name":"Slough Creek Trail","difficulty":"medium","track":[{"lat":44.9446,"lon":-110.3066,"ele":1900},{"lat":44.95,"lon":-110.30}]}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "track as encoded polyline",
+			body:           `{"name":"Slough Creek Trail","difficulty":"medium","track":"` + models.EncodePolyline([]models.Coordinate{{Lat: 44.9446, Lon: -110.3066}, {Lat: 44.95, Lon: -110.30}}) + `"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "track with a single point",
+			body:           `{"name":"Slough Creek Trail","difficulty":"medium","track":[{"lat":44.9446,"lon":-110.3066}]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := newTestTrailsRouter(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/trails", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+
+			var created models.Trail
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+			assert.Len(t, created.Track, 2)
+			assert.InDelta(t, 44.9446, *created.Lat, 1e-9)
+			assert.InDelta(t, models.TrackLengthKm(created.Track), *created.LengthKm, 1e-9)
+
+			// The same trail can be read back with its track as an encoded polyline
+			req = httptest.NewRequest(http.MethodGet, "/trails/"+created.UID.String()+"?track-format=polyline", nil)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, models.EncodePolyline(created.Track), body["track"])
+		})
+	}
+}
+
+func TestElevationProfileHandler(t *testing.T) {
+	low, high := 1900.0, 1950.0
+	withElevation := models.NewTrailFromTrack("Slough Creek Trail", []models.Coordinate{
+		{Lat: 44.9446, Lon: -110.3066, Ele: &low},
+		{Lat: 44.9500, Lon: -110.3000, Ele: &high},
+	}, nil)
+	router, withoutElevation := newTestTrailsRouter(t, withElevation)
+
+	tests := []struct {
+		name            string
+		url             string
+		expectedStatus  int
+		expectedSamples int
+	}{
+		{
+			name:            "default resolution",
+			url:             "/trails/" + withElevation.UID.String() + "/elevation-profile",
+			expectedStatus:  http.StatusOK,
+			expectedSamples: 9,
+		},
+		{
+			name:            "requested resolution",
+			url:             "/trails/" + withElevation.UID.String() + "/elevation-profile?resolution-m=500",
+			expectedStatus:  http.StatusOK,
+			expectedSamples: 3,
+		},
+		{
+			name:           "resolution too small",
+			url:            "/trails/" + withElevation.UID.String() + "/elevation-profile?resolution-m=0.1",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "trail without elevation",
+			url:            "/trails/" + withoutElevation.UID.String() + "/elevation-profile",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "trail not found",
+			url:            "/trails/" + uuid.New().String() + "/elevation-profile",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Elevation *models.ElevationStats   `json:"elevation"`
+				Samples   []models.ElevationSample `json:"samples"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Len(t, body.Samples, tt.expectedSamples)
+			assert.Equal(t, 50.0, body.Elevation.AscentM)
+		})
+	}
+}
+
+func TestTrailErrorResponses(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		url            func(trail *models.Trail) string
+		body           string
+		expectedStatus int
+		expectedDetail string
+		expectedFields []models.FieldError
+		// conflicts is whether the seeded trail is listed as a conflict
+		conflicts bool
+	}{
+		{
+			name:           "every invalid field is reported",
+			method:         http.MethodPost,
+			url:            func(trail *models.Trail) string { return "/trails" },
+			body:           `{"lat":95,"lon":-110.3066,"difficulty":"extreme"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedDetail: "trail name is required; trail start latitude must be between -90 and 90; trail difficulty must be easy, medium, or hard; trail length is required",
+			expectedFields: []models.FieldError{
+				{Field: "name", Message: "trail name is required"},
+				{Field: "lat", Message: "trail start latitude must be between -90 and 90"},
+				{Field: "difficulty", Message: "trail difficulty must be easy, medium, or hard"},
+				{Field: "length_km", Message: "trail length is required"},
+			},
+		},
+		{
+			name:           "every invalid query parameter is reported",
+			method:         http.MethodGet,
+			url:            func(trail *models.Trail) string { return "/trails?lat=north&lon=west&difficulty=extreme&limit=1000" },
+			expectedStatus: http.StatusBadRequest,
+			expectedDetail: `invalid lat: strconv.ParseFloat: parsing "north": invalid syntax; invalid lon: strconv.ParseFloat: parsing "west": invalid syntax; invalid difficulty: extreme; invalid limit: must be at most 500`,
+			expectedFields: []models.FieldError{
+				{Field: "lat", Message: `invalid lat: strconv.ParseFloat: parsing "north": invalid syntax`},
+				{Field: "lon", Message: `invalid lon: strconv.ParseFloat: parsing "west": invalid syntax`},
+				{Field: "difficulty", Message: "invalid difficulty: extreme"},
+				{Field: "limit", Message: "invalid limit: must be at most 500"},
+			},
+		},
+		{
+			name:   "ranges out of order",
+			method: http.MethodGet,
+			url: func(trail *models.Trail) string {
+				return "/trails?min-length-km=20&max-length-km=10&created-after=2024-07-01&created-before=2024-06-01"
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedDetail: "invalid min length filter must not be greater than max length; invalid created after filter must not be later than created before",
+			expectedFields: []models.FieldError{
+				{Field: "min-length-km", Message: "invalid min length filter must not be greater than max length"},
+				{Field: "created-after", Message: "invalid created after filter must not be later than created before"},
+			},
+		},
+		{
+			name:           "duplicate trail",
+			method:         http.MethodPost,
+			url:            func(trail *models.Trail) string { return "/trails" },
+			body:           `{"name":"Lamar River Trail","lat":44.85,"lon":-109.63,"difficulty":"hard","length_km":53}`,
+			expectedStatus: http.StatusConflict,
+			expectedDetail: "trail already exists",
+			conflicts:      true,
+		},
+		{
+			name:           "trail not found",
+			method:         http.MethodGet,
+			url:            func(trail *models.Trail) string { return "/trails/" + uuid.New().String() },
+			expectedStatus: http.StatusNotFound,
+			expectedDetail: "trail not found",
+		},
+		{
+			name:           "malformed body",
+			method:         http.MethodPost,
+			url:            func(trail *models.Trail) string { return "/trails" },
+			body:           `{"name":`,
+			expectedStatus: http.StatusBadRequest,
+			expectedDetail: "invalid request body: unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, trail := newTestTrailsRouter(t)
+			url := tt.url(trail)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, url, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, models.ProblemContentType, w.Header().Get("Content-Type"))
+			var problem models.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			var conflicts []string
+			if tt.conflicts {
+				conflicts = []string{trail.UID.String()}
+			}
+			assert.Equal(t, models.Problem{
+				Type:      "about:blank",
+				Title:     http.StatusText(tt.expectedStatus),
+				Status:    tt.expectedStatus,
+				Detail:    tt.expectedDetail,
+				Instance:  strings.Split(url, "?")[0],
+				Errors:    tt.expectedFields,
+				Conflicts: conflicts,
+			}, problem)
+		})
+	}
+}
@@ -1,22 +1,26 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/dnakolan/trail-data-service/internal/config"
 	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Middleware to validate the JWT token from the Authorization header
-func JwtAuthMiddleware() gin.HandlerFunc {
+// Middleware to validate the JWT token from the Authorization header, or an API key in the X-API-Key header
+func JwtAuthMiddleware(cfg config.JWTConfig, keys *services.KeySet, revocations services.RevocationChecker, apiKeys services.APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Authorization header required"})
+			if apiKey := c.GetHeader("X-API-Key"); apiKey != "" && apiKeys != nil {
+				authenticateAPIKey(c, apiKeys, apiKey)
+				return
+			}
+			AbortWithProblem(c, http.StatusUnauthorized, "authorization header required")
 			return
 		}
 
@@ -25,21 +29,25 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 
 		// Parse the token
 		claims := &models.Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			// Check the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(config.SECRET_KEY), nil
-		})
+		token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc, jwt.WithIssuer(cfg.Issuer))
 
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Invalid token"})
+			AbortWithProblem(c, http.StatusUnauthorized, "invalid token")
 			return
 		}
 
 		if !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Invalid token"})
+			AbortWithProblem(c, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		revoked, err := revocations.IsTokenRevoked(c.Request.Context(), claims.ID)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+		if revoked {
+			AbortWithProblem(c, http.StatusUnauthorized, "token has been revoked")
 			return
 		}
 
@@ -48,3 +56,14 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+func authenticateAPIKey(c *gin.Context, apiKeys services.APIKeyAuthenticator, apiKey string) {
+	claims, err := apiKeys.AuthenticateAPIKey(c.Request.Context(), apiKey)
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
+
+	c.Set("claims", claims)
+	c.Next()
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/services"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLoginService(t *testing.T, cfg config.JWTConfig) services.LoginService {
+	keys, err := services.NewKeySet(cfg)
+	require.NoError(t, err)
+	service := services.NewLoginService(storage.NewUserStorage(), storage.NewTokenStorage(), config.AuthConfig{}, cfg, keys)
+	_, err = service.Register(context.Background(), &models.RegisterUserRequest{Username: "ranger", Password: "correct horse"})
+	require.NoError(t, err)
+	return service
+}
+
+func login(t *testing.T, service services.LoginService) string {
+	tokens, err := service.Login(context.Background(), "ranger", "correct horse")
+	require.NoError(t, err)
+	return tokens.AccessToken
+}
+
+func newTestAPIKeyService(t *testing.T) services.APIKeyService {
+	users := storage.NewUserStorage()
+	user, err := models.NewUser(&models.RegisterUserRequest{Username: "ranger", Password: "correct horse"}, models.RoleContributor)
+	require.NoError(t, err)
+	require.NoError(t, users.Create(context.Background(), user))
+	return services.NewAPIKeyService(storage.NewAPIKeyStorage(), users)
+}
+
+func issueAPIKey(t *testing.T, apiKeys services.APIKeyService) string {
+	issued, err := apiKeys.CreateAPIKey(context.Background(), "ranger", &models.CreateAPIKeyRequest{Name: "ingest", Scopes: []models.APIKeyScope{models.ScopeReadTrails}})
+	require.NoError(t, err)
+	return issued.Key
+}
+
+func TestJwtAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.JWTConfig{Secret: "test-secret", Expiration: time.Hour, Issuer: "trail-data-service"}
+	service := newTestLoginService(t, cfg)
+	apiKeys := newTestAPIKeyService(t)
+
+	tests := []struct {
+		name           string
+		authorization  func(t *testing.T) string
+		apiKey         func(t *testing.T) string
+		expectedStatus int
+	}{
+		{
+			name:           "valid token",
+			authorization:  func(t *testing.T) string { return "Bearer " + login(t, service) },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "missing header",
+			authorization:  func(t *testing.T) string { return "" },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "signed with another secret",
+			authorization: func(t *testing.T) string {
+				other := cfg
+				other.Secret = "other-secret"
+				return "Bearer " + login(t, newTestLoginService(t, other))
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "issued by another issuer",
+			authorization: func(t *testing.T) string {
+				other := cfg
+				other.Issuer = "someone-else"
+				return "Bearer " + login(t, newTestLoginService(t, other))
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "expired token",
+			authorization: func(t *testing.T) string {
+				expired := cfg
+				expired.Expiration = -time.Minute
+				return "Bearer " + login(t, newTestLoginService(t, expired))
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "valid api key",
+			authorization:  func(t *testing.T) string { return "" },
+			apiKey:         func(t *testing.T) string { return issueAPIKey(t, apiKeys) },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown api key",
+			authorization:  func(t *testing.T) string { return "" },
+			apiKey:         func(t *testing.T) string { return "tds_unknown" },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "revoked token",
+			authorization: func(t *testing.T) string {
+				token := login(t, service)
+				claims := &models.Claims{}
+				_, _, err := jwt.NewParser().ParseUnverified(token, claims)
+				require.NoError(t, err)
+				require.NoError(t, service.Logout(context.Background(), claims, ""))
+				return "Bearer " + token
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	keys, err := services.NewKeySet(cfg)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/protected", JwtAuthMiddleware(cfg, keys, service, apiKeys), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			if authorization := tt.authorization(t); authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			if tt.apiKey != nil {
+				req.Header.Set("X-API-Key", tt.apiKey(t))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func GetClaims(c *gin.Context) (*models.Claims, bool) {
+	value, ok := c.Get("claims")
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*models.Claims)
+	return claims, ok
+}
+
+// RequireRole must run after JwtAuthMiddleware
+func RequireRole(min models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			AbortWithProblem(c, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		if !claims.Role.AtLeast(min) {
+			AbortWithProblem(c, http.StatusForbidden, "insufficient role")
+			return
+		}
+		c.Next()
+	}
+}
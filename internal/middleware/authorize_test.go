@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		claims         *models.Claims
+		expectedStatus int
+	}{
+		{
+			name:           "role high enough",
+			claims:         &models.Claims{Username: "ranger", Role: models.RoleModerator},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "exact role",
+			claims:         &models.Claims{Username: "ranger", Role: models.RoleContributor},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "role too low",
+			claims:         &models.Claims{Username: "ranger", Role: models.RoleViewer},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "token without a role",
+			claims:         &models.Claims{Username: "ranger"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "not authenticated",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/protected", func(c *gin.Context) {
+				if tt.claims != nil {
+					c.Set("claims", tt.claims)
+				}
+			}, RequireRole(models.RoleContributor), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/protected", nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
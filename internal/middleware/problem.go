@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+var problemStatuses = []struct {
+	kind   error
+	status int
+}{
+	{models.ErrValidation, http.StatusBadRequest},
+	{models.ErrUnauthenticated, http.StatusUnauthorized},
+	{models.ErrForbidden, http.StatusForbidden},
+	{models.ErrNotFound, http.StatusNotFound},
+	{models.ErrDuplicate, http.StatusConflict},
+	{models.ErrLocked, http.StatusLocked},
+	{models.ErrUnavailable, http.StatusBadGateway},
+	{models.ErrOverloaded, http.StatusServiceUnavailable},
+}
+
+func StatusForError(err error) int {
+	for _, mapping := range problemStatuses {
+		if errors.Is(err, mapping.kind) {
+			return mapping.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// AbortWithError logs the message of unexpected errors rather than sending it, as it may give away internals
+func AbortWithError(c *gin.Context, err error) {
+	status := StatusForError(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+		AbortWithProblem(c, status, "")
+		return
+	}
+
+	problem := newProblem(c, status, err.Error())
+	var validation *models.ValidationError
+	if errors.As(err, &validation) {
+		problem.Errors = validation.Fields
+	}
+	var conflict *models.ConflictError
+	if errors.As(err, &conflict) {
+		problem.Conflicts = conflict.IDs
+	}
+	abortWithProblem(c, problem)
+}
+
+func AbortWithProblem(c *gin.Context, status int, detail string) {
+	abortWithProblem(c, newProblem(c, status, detail))
+}
+
+func newProblem(c *gin.Context, status int, detail string) *models.Problem {
+	return &models.Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+	}
+}
+
+func abortWithProblem(c *gin.Context, problem *models.Problem) {
+	c.Header("Content-Type", models.ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
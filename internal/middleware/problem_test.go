@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbortWithError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "validation error",
+			err:            models.NewValidationError("name", "trail name is required"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"type":"about:blank","title":"Bad Request","status":400,"detail":"trail name is required","instance":"/trails","errors":[{"field":"name","message":"trail name is required"}]}`,
+		},
+		{
+			name:           "not found",
+			err:            models.Errorf(models.ErrNotFound, "trail not found"),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"type":"about:blank","title":"Not Found","status":404,"detail":"trail not found","instance":"/trails"}`,
+		},
+		{
+			name:           "duplicate",
+			err:            models.Errorf(models.ErrDuplicate, "trail already exists"),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"type":"about:blank","title":"Conflict","status":409,"detail":"trail already exists","instance":"/trails"}`,
+		},
+		{
+			name:           "conflict with existing resources",
+			err:            &models.ConflictError{Err: models.Errorf(models.ErrDuplicate, "trail already exists"), IDs: []string{"6f03765b-6a3d-44df-9c1f-f3341f089c23"}},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"type":"about:blank","title":"Conflict","status":409,"detail":"trail already exists","instance":"/trails","conflicts":["6f03765b-6a3d-44df-9c1f-f3341f089c23"]}`,
+		},
+		{
+			name:           "locked",
+			err:            models.Errorf(models.ErrLocked, "account is locked"),
+			expectedStatus: http.StatusLocked,
+			expectedBody:   `{"type":"about:blank","title":"Locked","status":423,"detail":"account is locked","instance":"/trails"}`,
+		},
+		{
+			name:           "unexpected errors are not given away",
+			err:            errors.New("database is locked: /var/lib/trails.db"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/trails"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/trails", func(c *gin.Context) {
+				AbortWithError(c, tt.err)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/trails", nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, models.ProblemContentType, w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
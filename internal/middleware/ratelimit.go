@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit counts clients by API key or username when placed after JwtAuthMiddleware, otherwise by IP address
+func RateLimit(limiter storage.RateLimiter, name string, rule config.RateLimitRule) gin.HandlerFunc {
+	return rateLimit(limiter, name, rule, rateLimitClient)
+}
+
+// RateLimitByIP goes in front of JwtAuthMiddleware so bad credentials are limited before they are checked
+func RateLimitByIP(limiter storage.RateLimiter, name string, rule config.RateLimitRule) gin.HandlerFunc {
+	return rateLimit(limiter, name, rule, func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	})
+}
+
+func rateLimit(limiter storage.RateLimiter, name string, rule config.RateLimitRule, client func(c *gin.Context) string) gin.HandlerFunc {
+	if !rule.Enabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	limit := models.RateLimit{Rate: float64(rule.Requests) / rule.Per.Seconds(), Burst: rule.Burst}
+	return func(c *gin.Context) {
+		result, err := limiter.Take(c.Request.Context(), name+":"+client(c), limit, time.Now())
+		if err != nil {
+			// Better to let requests through than to take the API down with the rate limit backend
+			slog.Error("failed to check rate limit", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+			AbortWithProblem(c, http.StatusTooManyRequests, "too many requests")
+			return
+		}
+		c.Next()
+	}
+}
+
+func rateLimitClient(c *gin.Context) string {
+	if claims, ok := GetClaims(c); ok {
+		if claims.APIKeyID != "" {
+			return "key:" + claims.APIKeyID
+		}
+		if claims.Username != "" {
+			return "user:" + claims.Username
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingRateLimiter struct{}
+
+func (failingRateLimiter) Take(ctx context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitResult, error) {
+	return models.RateLimitResult{}, errors.New("backend unavailable")
+}
+
+func newTestRateLimitRouter(limiter storage.RateLimiter, rule config.RateLimitRule) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/limited", func(c *gin.Context) {
+		switch c.GetHeader("X-Test-User") {
+		case "":
+		case "key":
+			c.Set("claims", &models.Claims{Username: "ranger", APIKeyID: "key-1"})
+		default:
+			c.Set("claims", &models.Claims{Username: c.GetHeader("X-Test-User")})
+		}
+	}, RateLimit(limiter, "reads", rule), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestRateLimit(t *testing.T) {
+	router := newTestRateLimitRouter(storage.NewRateLimiter(), config.RateLimitRule{Requests: 2, Per: time.Hour, Burst: 2})
+	request := func(user string, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.RemoteAddr = ip + ":1234"
+		if user != "" {
+			req.Header.Set("X-Test-User", user)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1800", w.Header().Get("RateLimit-Reset"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, request("", "10.0.0.1").Code)
+	w = request("", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1800", w.Header().Get("Retry-After"))
+	assert.Equal(t, models.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"too many requests","instance":"/limited"}`, w.Body.String())
+
+	// Other addresses, users and API keys each have their own bucket, wherever they connect from
+	assert.Equal(t, http.StatusOK, request("", "10.0.0.2").Code)
+	for range 2 {
+		assert.Equal(t, http.StatusOK, request("ranger", "10.0.0.1").Code)
+		assert.Equal(t, http.StatusOK, request("key", "10.0.0.1").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, request("ranger", "10.0.0.3").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("key", "10.0.0.3").Code)
+	assert.Equal(t, http.StatusOK, request("explorer", "10.0.0.1").Code)
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/limited", func(c *gin.Context) {
+		c.Set("claims", &models.Claims{Username: c.GetHeader("X-Test-User")})
+	}, RateLimitByIP(storage.NewRateLimiter(), "auth", config.RateLimitRule{Requests: 2, Per: time.Hour, Burst: 2}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	request := func(user string, ip string) int {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Every request from an address shares its bucket, whoever it claims to be
+	assert.Equal(t, http.StatusOK, request("ranger", "10.0.0.1"))
+	assert.Equal(t, http.StatusOK, request("explorer", "10.0.0.1"))
+	assert.Equal(t, http.StatusTooManyRequests, request("warden", "10.0.0.1"))
+	assert.Equal(t, http.StatusOK, request("warden", "10.0.0.2"))
+}
+
+func TestRateLimit_Disabled(t *testing.T) {
+	router := newTestRateLimitRouter(storage.NewRateLimiter(), config.RateLimitRule{})
+
+	for range 5 {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/limited", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimit_BackendError(t *testing.T) {
+	router := newTestRateLimitRouter(failingRateLimiter{}, config.RateLimitRule{Requests: 1, Per: time.Hour, Burst: 1})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/limited", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
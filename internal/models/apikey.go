@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyScope limits what a request made with an API key can do
+type APIKeyScope string
+
+const (
+	ScopeReadTrails  APIKeyScope = "read:trails"
+	ScopeWriteTrails APIKeyScope = "write:trails"
+)
+
+const maxAPIKeyNameLength = 100
+
+// APIKey only keeps a hash of the key, the prefix is enough for its owner to tell keys apart
+type APIKey struct {
+	ID        uuid.UUID     `json:"key_id"`
+	Name      string        `json:"name"`
+	Username  string        `json:"username"`
+	Prefix    string        `json:"prefix"`
+	Hash      string        `json:"-"`
+	Scopes    []APIKeyScope `json:"scopes"`
+	CreatedAt *time.Time    `json:"created_at"`
+	RevokedAt *time.Time    `json:"revoked_at,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string        `json:"name"`
+	Scopes []APIKeyScope `json:"scopes"`
+}
+
+type IssuedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+func IsValidAPIKeyScope(s string) bool {
+	switch APIKeyScope(s) {
+	case ScopeReadTrails, ScopeWriteTrails:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s APIKeyScope) Role() Role {
+	if s == ScopeWriteTrails {
+		return RoleContributor
+	}
+	return RoleViewer
+}
+
+func (r *CreateAPIKeyRequest) Validate() error {
+	errs := &ValidationError{}
+	name := strings.TrimSpace(r.Name)
+	if name == "" {
+		errs.Add("name", "api key name is required")
+	}
+	if len(name) > maxAPIKeyNameLength {
+		errs.Addf("name", "api key name must be at most %d characters", maxAPIKeyNameLength)
+	}
+	if len(r.Scopes) == 0 {
+		errs.Add("scopes", "api key needs at least one scope")
+	}
+	for i, scope := range r.Scopes {
+		if !IsValidAPIKeyScope(string(scope)) {
+			errs.Addf(fmt.Sprintf("scopes[%d]", i), "invalid api key scope %q, must be read:trails or write:trails", scope)
+		}
+	}
+	return errs.Err()
+}
+
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// RoleFor never grants more than the owner currently has
+func (k *APIKey) RoleFor(owner Role) Role {
+	role := Role("")
+	for _, scope := range k.Scopes {
+		if scope.Role().Rank() > role.Rank() {
+			role = scope.Role()
+		}
+	}
+	if owner.Rank() < role.Rank() {
+		return owner
+	}
+	return role
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAPIKeyRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           CreateAPIKeyRequest
+		expectedError string
+	}{
+		{
+			name: "valid request",
+			req:  CreateAPIKeyRequest{Name: "nightly ingest", Scopes: []APIKeyScope{ScopeReadTrails, ScopeWriteTrails}},
+		},
+		{
+			name:          "missing name",
+			req:           CreateAPIKeyRequest{Name: "  ", Scopes: []APIKeyScope{ScopeReadTrails}},
+			expectedError: "api key name is required",
+		},
+		{
+			name:          "name too long",
+			req:           CreateAPIKeyRequest{Name: strings.Repeat("a", 101), Scopes: []APIKeyScope{ScopeReadTrails}},
+			expectedError: "api key name must be at most 100 characters",
+		},
+		{
+			name:          "no scopes",
+			req:           CreateAPIKeyRequest{Name: "nightly ingest"},
+			expectedError: "api key needs at least one scope",
+		},
+		{
+			name:          "unknown scope",
+			req:           CreateAPIKeyRequest{Name: "nightly ingest", Scopes: []APIKeyScope{"delete:trails"}},
+			expectedError: `invalid api key scope "delete:trails", must be read:trails or write:trails`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAPIKey_RoleFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []APIKeyScope
+		owner    Role
+		expected Role
+	}{
+		{name: "read only", scopes: []APIKeyScope{ScopeReadTrails}, owner: RoleAdmin, expected: RoleViewer},
+		{name: "read and write", scopes: []APIKeyScope{ScopeReadTrails, ScopeWriteTrails}, owner: RoleModerator, expected: RoleContributor},
+		{name: "owner demoted below the scopes", scopes: []APIKeyScope{ScopeWriteTrails}, owner: RoleViewer, expected: RoleViewer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &APIKey{Scopes: tt.scopes}
+			assert.Equal(t, tt.expected, key.RoleFor(tt.owner))
+		})
+	}
+}
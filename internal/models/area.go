@@ -0,0 +1,210 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BBox is a box of longitudes and latitudes, as in a map viewport. A box whose MinLon is greater than
+// its MaxLon crosses the antimeridian, e.g. 170,-20,-170,-10 covers 170 to 180 and -180 to -170.
+type BBox struct {
+	MinLon float64 `json:"min_lon"`
+	MinLat float64 `json:"min_lat"`
+	MaxLon float64 `json:"max_lon"`
+	MaxLat float64 `json:"max_lat"`
+}
+
+// ParseBBox reads minLon,minLat,maxLon,maxLat, the order used by GeoJSON
+func ParseBBox(s string) (*BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, errors.New("invalid bbox: must be minLon,minLat,maxLon,maxLat")
+	}
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		val, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(val) {
+			return nil, fmt.Errorf("invalid bbox: %q is not a number", part)
+		}
+		values[i] = val
+	}
+
+	box := &BBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if err := box.validate(); err != nil {
+		return nil, err
+	}
+	return box, nil
+}
+
+func (b *BBox) validate() error {
+	if b.MinLon < -180 || b.MinLon > 180 || b.MaxLon < -180 || b.MaxLon > 180 {
+		return errors.New("invalid bbox: longitudes must be between -180 and 180")
+	}
+	if b.MinLat < -90 || b.MinLat > 90 || b.MaxLat < -90 || b.MaxLat > 90 {
+		return errors.New("invalid bbox: latitudes must be between -90 and 90")
+	}
+	if b.MinLat > b.MaxLat {
+		return errors.New("invalid bbox: min latitude must not be greater than max latitude")
+	}
+	return nil
+}
+
+func (b *BBox) CrossesAntimeridian() bool {
+	return b.MinLon > b.MaxLon
+}
+
+func (b *BBox) Contains(lat, lon float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return lon >= b.MinLon || lon <= b.MaxLon
+	}
+	return lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// Position is longitude first
+type Position [2]float64
+
+type Ring []Position
+
+type Polygon []Ring
+
+// Area edges are straight lines between longitudes and latitudes, so as RFC 7946 asks, areas crossing the
+// antimeridian should be split into a MultiPolygon with a part on either side
+type Area struct {
+	Polygons []Polygon
+}
+
+func ParseGeoJSONArea(data []byte) (*Area, error) {
+	var object struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometry    json.RawMessage `json:"geometry"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, NewValidationError("area", fmt.Sprintf("invalid area: %v", err))
+	}
+
+	var polygons [][][][]float64
+	switch object.Type {
+	case "Feature":
+		if len(object.Geometry) == 0 || string(object.Geometry) == "null" {
+			return nil, NewValidationError("geometry", "invalid area: feature has no geometry")
+		}
+		return ParseGeoJSONArea(object.Geometry)
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(object.Coordinates, &polygon); err != nil {
+			return nil, NewValidationError("coordinates", fmt.Sprintf("invalid polygon coordinates: %v", err))
+		}
+		polygons = [][][][]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(object.Coordinates, &polygons); err != nil {
+			return nil, NewValidationError("coordinates", fmt.Sprintf("invalid multipolygon coordinates: %v", err))
+		}
+	default:
+		return nil, NewValidationError("type", fmt.Sprintf("invalid area type %q, must be Polygon, MultiPolygon or a Feature with one", object.Type))
+	}
+
+	errs := &ValidationError{}
+	area := &Area{Polygons: make([]Polygon, 0, len(polygons))}
+	for i, rings := range polygons {
+		field := "coordinates"
+		if object.Type == "MultiPolygon" {
+			field = fmt.Sprintf("coordinates[%d]", i)
+		}
+		area.Polygons = append(area.Polygons, parsePolygon(field, rings, errs))
+	}
+	if len(area.Polygons) == 0 {
+		errs.Add("coordinates", "invalid area: must have at least one polygon")
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return area, nil
+}
+
+func parsePolygon(field string, rings [][][]float64, errs *ValidationError) Polygon {
+	if len(rings) == 0 {
+		errs.Add(field, "invalid polygon: must have an outer ring")
+	}
+	polygon := make(Polygon, 0, len(rings))
+	for i, positions := range rings {
+		ringField := fmt.Sprintf("%s[%d]", field, i)
+		ring := make(Ring, 0, len(positions))
+		for j, position := range positions {
+			if len(position) < 2 {
+				errs.Add(fmt.Sprintf("%s[%d]", ringField, j), "invalid position: must have a longitude and latitude")
+				continue
+			}
+			lon, lat := position[0], position[1]
+			if lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+				errs.Add(fmt.Sprintf("%s[%d]", ringField, j), "invalid position: longitude must be between -180 and 180 and latitude between -90 and 90")
+				continue
+			}
+			ring = append(ring, Position{lon, lat})
+		}
+		if len(positions) < 4 {
+			errs.Add(ringField, "invalid ring: must have at least 4 positions")
+		} else if len(ring) == len(positions) && ring[0] != ring[len(ring)-1] {
+			errs.Add(ringField, "invalid ring: first and last positions must be the same")
+		}
+		polygon = append(polygon, ring)
+	}
+	return polygon
+}
+
+func (a *Area) Contains(lat, lon float64) bool {
+	for _, polygon := range a.Polygons {
+		if polygon.contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Polygon) contains(lat, lon float64) bool {
+	if len(p) == 0 || !p[0].contains(lat, lon) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.contains(lat, lon) {
+			return false
+		}
+	}
+	return true
+}
+
+// contains counts the ring edges crossed by a ray along the point's latitude, an odd count is inside
+func (r Ring) contains(lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		lonI, latI := r[i][0], r[i][1]
+		lonJ, latJ := r[j][0], r[j][1]
+		if (latI > lat) != (latJ > lat) && lon < (lonJ-lonI)*(lat-latI)/(latJ-latI)+lonI {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (a *Area) Bounds() BBox {
+	box := BBox{MinLon: 180, MinLat: 90, MaxLon: -180, MaxLat: -90}
+	for _, polygon := range a.Polygons {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, position := range polygon[0] {
+			box.MinLon = math.Min(box.MinLon, position[0])
+			box.MaxLon = math.Max(box.MaxLon, position[0])
+			box.MinLat = math.Min(box.MinLat, position[1])
+			box.MaxLat = math.Max(box.MaxLat, position[1])
+		}
+	}
+	return box
+}
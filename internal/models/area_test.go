@@ -0,0 +1,167 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBBox(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      *BBox
+		expectedError string
+	}{
+		{
+			name:     "valid box",
+			input:    "-123,45,-122,46",
+			expected: &BBox{MinLon: -123, MinLat: 45, MaxLon: -122, MaxLat: 46},
+		},
+		{
+			name:     "spaces around values",
+			input:    "170, -20, -170, -10",
+			expected: &BBox{MinLon: 170, MinLat: -20, MaxLon: -170, MaxLat: -10},
+		},
+		{
+			name:          "too few values",
+			input:         "-123,45,-122",
+			expectedError: "invalid bbox: must be minLon,minLat,maxLon,maxLat",
+		},
+		{
+			name:          "not a number",
+			input:         "-123,north,-122,46",
+			expectedError: `invalid bbox: "north" is not a number`,
+		},
+		{
+			name:          "longitude out of range",
+			input:         "-190,45,-122,46",
+			expectedError: "invalid bbox: longitudes must be between -180 and 180",
+		},
+		{
+			name:          "latitude out of range",
+			input:         "-123,45,-122,95",
+			expectedError: "invalid bbox: latitudes must be between -90 and 90",
+		},
+		{
+			name:          "latitudes reversed",
+			input:         "-123,46,-122,45",
+			expectedError: "invalid bbox: min latitude must not be greater than max latitude",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := ParseBBox(tt.input)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, box)
+		})
+	}
+}
+
+func TestBBox_Contains(t *testing.T) {
+	box := &BBox{MinLon: -123, MinLat: 45, MaxLon: -122, MaxLat: 46}
+	assert.True(t, box.Contains(45.5, -122.5))
+	assert.True(t, box.Contains(45, -123))
+	assert.False(t, box.Contains(44.9, -122.5))
+	assert.False(t, box.Contains(45.5, -121.9))
+
+	wrapped := &BBox{MinLon: 179, MinLat: -17, MaxLon: -179, MaxLat: -16}
+	assert.True(t, wrapped.CrossesAntimeridian())
+	assert.True(t, wrapped.Contains(-16.5, 179.5))
+	assert.True(t, wrapped.Contains(-16.5, -179.5))
+	assert.False(t, wrapped.Contains(-16.5, 0))
+}
+
+func TestParseGeoJSONArea(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expected       *Area
+		expectedFields []FieldError
+	}{
+		{
+			name:  "polygon",
+			input: `{"type":"Polygon","coordinates":[[[0,0],[2,0],[2,2],[0,2],[0,0]]]}`,
+			expected: &Area{Polygons: []Polygon{
+				{{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}},
+			}},
+		},
+		{
+			name:  "multipolygon",
+			input: `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,5]]]]}`,
+			expected: &Area{Polygons: []Polygon{
+				{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+				{{{5, 5}, {6, 5}, {6, 6}, {5, 5}}},
+			}},
+		},
+		{
+			name:  "feature",
+			input: `{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}}`,
+			expected: &Area{Polygons: []Polygon{
+				{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+			}},
+		},
+		{
+			name:           "unsupported type",
+			input:          `{"type":"Point","coordinates":[0,0]}`,
+			expectedFields: []FieldError{{Field: "type", Message: `invalid area type "Point", must be Polygon, MultiPolygon or a Feature with one`}},
+		},
+		{
+			name:           "feature without geometry",
+			input:          `{"type":"Feature","geometry":null}`,
+			expectedFields: []FieldError{{Field: "geometry", Message: "invalid area: feature has no geometry"}},
+		},
+		{
+			name:           "empty multipolygon",
+			input:          `{"type":"MultiPolygon","coordinates":[]}`,
+			expectedFields: []FieldError{{Field: "coordinates", Message: "invalid area: must have at least one polygon"}},
+		},
+		{
+			name:  "every ring problem is reported",
+			input: `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,1]]],[[[0,0],[1,0],[0,0]],[[0,0],[200,0],[1,1],[0,0]]]]}`,
+			expectedFields: []FieldError{
+				{Field: "coordinates[0][0]", Message: "invalid ring: first and last positions must be the same"},
+				{Field: "coordinates[1][0]", Message: "invalid ring: must have at least 4 positions"},
+				{Field: "coordinates[1][1][1]", Message: "invalid position: longitude must be between -180 and 180 and latitude between -90 and 90"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			area, err := ParseGeoJSONArea([]byte(tt.input))
+			if tt.expectedFields != nil {
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.ErrorIs(t, err, ErrValidation)
+				assert.Equal(t, tt.expectedFields, validationErr.Fields)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, area)
+		})
+	}
+}
+
+func TestArea_Contains(t *testing.T) {
+	square := func(min, max float64) Ring {
+		return Ring{{min, min}, {max, min}, {max, max}, {min, max}, {min, min}}
+	}
+	area := &Area{Polygons: []Polygon{
+		{square(0, 10), square(4, 6)},
+		{square(20, 30)},
+	}}
+
+	assert.True(t, area.Contains(2, 2))
+	assert.False(t, area.Contains(5, 5), "inside the hole")
+	assert.True(t, area.Contains(25, 25))
+	assert.False(t, area.Contains(15, 15))
+	assert.False(t, area.Contains(-1, 5))
+	assert.Equal(t, BBox{MinLon: 0, MinLat: 0, MaxLon: 30, MaxLat: 30}, area.Bounds())
+}
@@ -4,5 +4,8 @@ import "github.com/golang-jwt/jwt/v5"
 
 type Claims struct {
 	Username string `json:"username"`
+	Role     Role   `json:"role"`
+	// APIKeyID is set when the request was authenticated with an API key rather than a token
+	APIKeyID string `json:"-"`
 	jwt.RegisteredClaims
 }
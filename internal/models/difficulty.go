@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// DefaultMediumDifficultyScore and DefaultHardDifficultyScore are the Shenandoah scores
+	// at which a trail stops being rated easy and medium respectively
+	DefaultMediumDifficultyScore = 50.0
+	DefaultHardDifficultyScore   = 100.0
+
+	feetPerMeter = 3.28084
+	milesPerKm   = 0.621371
+)
+
+type DifficultyRating struct {
+	Score      float64
+	Difficulty TrailDifficulty
+}
+
+type DifficultyRater interface {
+	Rate(trail *Trail) (DifficultyRating, bool)
+}
+
+// ShenandoahRater rates trails with the Shenandoah National Park formula, sqrt(gain ft * 2 * length mi)
+type ShenandoahRater struct {
+	MediumScore float64
+	HardScore   float64
+}
+
+func NewShenandoahRater(mediumScore, hardScore float64) (*ShenandoahRater, error) {
+	if mediumScore <= 0 {
+		return nil, errors.New("medium difficulty score must be positive")
+	}
+	if hardScore <= mediumScore {
+		return nil, errors.New("hard difficulty score must be greater than the medium score")
+	}
+	return &ShenandoahRater{MediumScore: mediumScore, HardScore: hardScore}, nil
+}
+
+// Rate does not treat trails without elevation data as flat
+func (r *ShenandoahRater) Rate(trail *Trail) (DifficultyRating, bool) {
+	if trail.LengthKm == nil || trail.Elevation == nil {
+		return DifficultyRating{}, false
+	}
+
+	gainFt := trail.Elevation.AscentM * feetPerMeter
+	lengthMi := *trail.LengthKm * milesPerKm
+	rating := DifficultyRating{Score: math.Sqrt(gainFt * 2 * lengthMi)}
+	switch {
+	case rating.Score < r.MediumScore:
+		rating.Difficulty = TrailDifficultyEasy
+	case rating.Score < r.HardScore:
+		rating.Difficulty = TrailDifficultyMedium
+	default:
+		rating.Difficulty = TrailDifficultyHard
+	}
+	return rating, true
+}
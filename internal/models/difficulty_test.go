@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShenandoahRater_Rate(t *testing.T) {
+	rater, err := NewShenandoahRater(DefaultMediumDifficultyScore, DefaultHardDifficultyScore)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		lengthKm           float64
+		ascentM            *float64
+		expectedScore      float64
+		expectedDifficulty TrailDifficulty
+		expectRated        bool
+	}{
+		{
+			name:               "short and flat",
+			lengthKm:           3,
+			ascentM:            float64Ptr(50),
+			expectedScore:      24.7,
+			expectedDifficulty: TrailDifficultyEasy,
+			expectRated:        true,
+		},
+		{
+			name:               "moderate climb",
+			lengthKm:           5,
+			ascentM:            float64Ptr(200),
+			expectedScore:      63.8,
+			expectedDifficulty: TrailDifficultyMedium,
+			expectRated:        true,
+		},
+		{
+			name:               "long climb",
+			lengthKm:           10,
+			ascentM:            float64Ptr(500),
+			expectedScore:      142.8,
+			expectedDifficulty: TrailDifficultyHard,
+			expectRated:        true,
+		},
+		{
+			name:        "no elevation data",
+			lengthKm:    10,
+			expectRated: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trail := NewTrail("Test Trail", 45.5, -122.6, TrailDifficultyEasy, tt.lengthKm)
+			if tt.ascentM != nil {
+				trail.Elevation = &ElevationStats{AscentM: *tt.ascentM}
+			}
+
+			rating, ok := rater.Rate(trail)
+			assert.Equal(t, tt.expectRated, ok)
+			if !tt.expectRated {
+				return
+			}
+			assert.InDelta(t, tt.expectedScore, rating.Score, 0.1)
+			assert.Equal(t, tt.expectedDifficulty, rating.Difficulty)
+		})
+	}
+}
+
+func TestNewShenandoahRater(t *testing.T) {
+	tests := []struct {
+		name        string
+		medium      float64
+		hard        float64
+		expectError bool
+	}{
+		{name: "valid thresholds", medium: 50, hard: 100},
+		{name: "zero medium threshold", medium: 0, hard: 100, expectError: true},
+		{name: "hard below medium", medium: 100, hard: 50, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewShenandoahRater(tt.medium, tt.hard)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
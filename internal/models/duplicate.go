@@ -0,0 +1,134 @@
+package models
+
+import (
+	"math"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/umahmood/haversine"
+)
+
+// Trails without tracks to compare are scored on name and distance alone
+const (
+	duplicateNameWeight     = 0.5
+	duplicateDistanceWeight = 0.2
+	duplicateOverlapWeight  = 0.3
+
+	// trackOverlapToleranceKm is how far apart two recordings of the same path can stray
+	trackOverlapToleranceKm = 0.1
+	maxOverlapSamples       = 200
+)
+
+// genericNameWords are left out of names, words like "loop" tell trails apart and are kept
+var genericNameWords = []string{"the", "of", "trail", "trails", "trailhead"}
+
+func DuplicateScore(t, candidate *Trail, radiusKm float64) float64 {
+	nameScore := 0.0
+	if t.Name != nil && candidate.Name != nil {
+		nameScore = NameSimilarity(*t.Name, *candidate.Name)
+	}
+
+	distanceScore := 0.0
+	if t.Lat != nil && t.Lon != nil && candidate.Lat != nil && candidate.Lon != nil {
+		_, distance := haversine.Distance(
+			haversine.Coord{Lat: *t.Lat, Lon: *t.Lon},
+			haversine.Coord{Lat: *candidate.Lat, Lon: *candidate.Lon},
+		)
+		if radiusKm > 0 {
+			distanceScore = math.Max(0, 1-distance/radiusKm)
+		} else if distance == 0 {
+			distanceScore = 1
+		}
+	}
+
+	if len(t.Track) < 2 || len(candidate.Track) < 2 {
+		return (duplicateNameWeight*nameScore + duplicateDistanceWeight*distanceScore) / (duplicateNameWeight + duplicateDistanceWeight)
+	}
+	overlap := (trackOverlap(t.Track, candidate.Track) + trackOverlap(candidate.Track, t.Track)) / 2
+	return duplicateNameWeight*nameScore + duplicateDistanceWeight*distanceScore + duplicateOverlapWeight*overlap
+}
+
+// NameSimilarity squares the share of words matched, so a single different word such as "upper" and "lower"
+// costs more than its share
+func NameSimilarity(a, b string) float64 {
+	tokensA, tokensB := nameTokens(a), nameTokens(b)
+	if len(tokensA) == 0 && len(tokensB) == 0 {
+		return 1
+	}
+	matched := 0.0
+	for _, token := range tokensA {
+		matched += bestTokenMatch(token, tokensB)
+	}
+	for _, token := range tokensB {
+		matched += bestTokenMatch(token, tokensA)
+	}
+	share := matched / float64(len(tokensA)+len(tokensB))
+	return share * share
+}
+
+func SameTrailName(a, b string) bool {
+	tokensA, tokensB := nameTokens(a), nameTokens(b)
+	slices.Sort(tokensA)
+	slices.Sort(tokensB)
+	return slices.Equal(slices.Compact(tokensA), slices.Compact(tokensB))
+}
+
+func nameTokens(name string) []string {
+	tokens := Tokenize(name)
+	distinctive := slices.DeleteFunc(slices.Clone(tokens), func(token string) bool {
+		return slices.Contains(genericNameWords, token)
+	})
+	// Names made only of generic words are compared as they are
+	if len(distinctive) > 0 {
+		return distinctive
+	}
+	return tokens
+}
+
+func bestTokenMatch(token string, others []string) float64 {
+	best := 0.0
+	for _, other := range others {
+		distance := EditDistance(token, other)
+		if distance > min(allowedTypos(token), allowedTypos(other)) {
+			continue
+		}
+		longest := max(utf8.RuneCountInString(token), utf8.RuneCountInString(other))
+		best = math.Max(best, 1-float64(distance)/float64(longest))
+	}
+	return best
+}
+
+func trackOverlap(a, b Track) float64 {
+	step := max(1, len(a)/maxOverlapSamples)
+	near, sampled := 0, 0
+	for i := 0; i < len(a); i += step {
+		sampled++
+		if distanceToTrackKm(a[i], b) <= trackOverlapToleranceKm {
+			near++
+		}
+	}
+	return float64(near) / float64(sampled)
+}
+
+// distanceToTrackKm treats the earth as flat over the short distances compared
+func distanceToTrackKm(p Coordinate, track Track) float64 {
+	kmPerDegreeLon := kmPerDegreeLat * math.Cos(p.Lat*math.Pi/180)
+	project := func(c Coordinate) (float64, float64) {
+		lonDelta := math.Remainder(c.Lon-p.Lon, 360)
+		return lonDelta * kmPerDegreeLon, (c.Lat - p.Lat) * kmPerDegreeLat
+	}
+
+	closest := math.Inf(1)
+	for i := 1; i < len(track); i++ {
+		x1, y1 := project(track[i-1])
+		x2, y2 := project(track[i])
+		dx, dy := x2-x1, y2-y1
+		// Find the closest point of the segment to the origin, where p is
+		fraction := 0.0
+		if length := dx*dx + dy*dy; length > 0 {
+			fraction = math.Max(0, math.Min(1, -(x1*dx+y1*dy)/length))
+		}
+		closest = math.Min(closest, math.Hypot(x1+fraction*dx, y1+fraction*dy))
+	}
+	return closest
+}
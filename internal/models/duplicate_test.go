@@ -0,0 +1,116 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected float64
+	}{
+		{name: "same name", a: "Lamar River Trail", b: "Lamar River Trail", expected: 1},
+		{name: "case and accents", a: "Cañón Creek", b: "canon creek", expected: 1},
+		{name: "generic words", a: "Lamar River Trail", b: "The Lamar River", expected: 1},
+		{name: "word order", a: "River Lamar", b: "Lamar River", expected: 1},
+		{name: "typo", a: "Lamar River", b: "Lamarr River", expected: (11.0 / 12) * (11.0 / 12)},
+		{name: "only generic words", a: "The Trail", b: "Trail", expected: (2.0 / 3) * (2.0 / 3)},
+		{name: "different names", a: "Lamar River", b: "Slough Creek", expected: 0},
+		{name: "one different word", a: "Upper Falls Trail", b: "Lower Falls Trail", expected: 0.25},
+		{name: "one different word in a longer name", a: "North Fork Lamar River", b: "South Fork Lamar River", expected: 0.75 * 0.75},
+		{name: "loop is not trail", a: "Lake Loop", b: "Lake Trail", expected: (2.0 / 3) * (2.0 / 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, NameSimilarity(tt.a, tt.b), 0.0001)
+			assert.InDelta(t, tt.expected, NameSimilarity(tt.b, tt.a), 0.0001)
+		})
+	}
+}
+
+func TestSameTrailName(t *testing.T) {
+	assert.True(t, SameTrailName("Lamar River Trail", "the lamar river"))
+	assert.True(t, SameTrailName("Cañón Creek", "Creek Canon"))
+	assert.False(t, SameTrailName("Lamar River", "Lamarr River"))
+	assert.False(t, SameTrailName("Lake Loop", "Lake Trail"))
+}
+
+func TestDuplicateScore(t *testing.T) {
+	track := []Coordinate{
+		{Lat: 44.8976, Lon: -110.2345},
+		{Lat: 44.9010, Lon: -110.2300},
+		{Lat: 44.9050, Lon: -110.2250},
+		{Lat: 44.9100, Lon: -110.2200},
+	}
+	// The same path recorded again, a few metres off
+	rerecorded := []Coordinate{
+		{Lat: 44.8977, Lon: -110.2346},
+		{Lat: 44.9011, Lon: -110.2301},
+		{Lat: 44.9049, Lon: -110.2251},
+		{Lat: 44.9101, Lon: -110.2199},
+	}
+	// A different path from the same trailhead
+	branching := []Coordinate{
+		{Lat: 44.8976, Lon: -110.2345},
+		{Lat: 44.8940, Lon: -110.2400},
+		{Lat: 44.8900, Lon: -110.2450},
+		{Lat: 44.8850, Lon: -110.2500},
+	}
+
+	trail := NewTrailFromTrack("Lamar River Trail", track, nil)
+
+	rerecordedScore := DuplicateScore(trail, NewTrailFromTrack("Lamar River", rerecorded, nil), 25)
+	assert.Greater(t, rerecordedScore, 0.95)
+
+	t.Run("same name on a different path", func(t *testing.T) {
+		// Only the shared trailhead overlaps
+		score := DuplicateScore(trail, NewTrailFromTrack("Lamar River Trail", branching, nil), 25)
+		assert.InDelta(t, 0.5+0.2+0.3*0.25, score, 0.0001)
+		assert.Less(t, score, rerecordedScore)
+	})
+
+	t.Run("different name on the same path", func(t *testing.T) {
+		score := DuplicateScore(trail, NewTrailFromTrack("Slough Creek", rerecorded, nil), 25)
+		assert.Less(t, score, 0.75)
+	})
+
+	t.Run("without tracks", func(t *testing.T) {
+		candidate := NewTrailFromRequest(&CreateTrailRequest{Name: stringPtr("Lamarr River"), Lat: float64Ptr(44.8976), Lon: float64Ptr(-110.2345)})
+		assert.InDelta(t, (0.5*(11.0/12)*(11.0/12)+0.2)/0.7, DuplicateScore(trail, candidate, 25), 0.0001)
+	})
+
+	// Distinct trails close together whose names differ by a single word are not duplicates
+	notDuplicates := []struct {
+		name string
+		a, b *Trail
+	}{
+		{
+			name: "upper and lower",
+			a:    NewTrailFromRequest(&CreateTrailRequest{Name: stringPtr("Upper Falls Trail"), Lat: float64Ptr(44.8976), Lon: float64Ptr(-110.2345)}),
+			b:    NewTrailFromRequest(&CreateTrailRequest{Name: stringPtr("Lower Falls Trail"), Lat: float64Ptr(44.9021), Lon: float64Ptr(-110.2345)}),
+		},
+		{
+			name: "north and south",
+			a:    NewTrailFromRequest(&CreateTrailRequest{Name: stringPtr("North Fork Lamar River"), Lat: float64Ptr(44.8976), Lon: float64Ptr(-110.2345)}),
+			b:    NewTrailFromRequest(&CreateTrailRequest{Name: stringPtr("South Fork Lamar River"), Lat: float64Ptr(44.8976), Lon: float64Ptr(-110.2345)}),
+		},
+		{
+			name: "loop and trail from the same trailhead",
+			a:    NewTrailFromRequest(&CreateTrailRequest{Name: stringPtr("Lake Loop"), Lat: float64Ptr(44.8976), Lon: float64Ptr(-110.2345)}),
+			b:    NewTrailFromRequest(&CreateTrailRequest{Name: stringPtr("Lake Trail"), Lat: float64Ptr(44.8976), Lon: float64Ptr(-110.2345)}),
+		},
+	}
+	for _, tt := range notDuplicates {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Less(t, DuplicateScore(tt.a, tt.b, 25), 0.75)
+		})
+	}
+
+	t.Run("far apart", func(t *testing.T) {
+		candidate := NewTrailFromRequest(&CreateTrailRequest{Name: stringPtr("Lamar River"), Lat: float64Ptr(45.5), Lon: float64Ptr(-110.2345)})
+		assert.InDelta(t, 0.5/0.7, DuplicateScore(trail, candidate, 25), 0.0001)
+	})
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"math"
+	"sort"
+)
+
+// Grades are measured over at least this run, so GPS noise does not turn into wildly steep grades
+const minGradeRunM = 25.0
+
+type ElevationStats struct {
+	AscentM     float64 `json:"ascent_m"`
+	DescentM    float64 `json:"descent_m"`
+	MinM        float64 `json:"min_m"`
+	MaxM        float64 `json:"max_m"`
+	MaxGradePct float64 `json:"max_grade_pct"`
+}
+
+type ElevationSample struct {
+	DistanceKm float64 `json:"distance_km"`
+	ElevationM float64 `json:"elevation_m"`
+}
+
+type elevationPoint struct {
+	distanceM  float64
+	elevationM float64
+}
+
+func (t Track) elevationPoints() []elevationPoint {
+	var points []elevationPoint
+	distanceM := 0.0
+	for i, c := range t {
+		if i > 0 {
+			distanceM += DistanceKm(t[i-1], c) * 1000
+		}
+		if c.Ele != nil {
+			points = append(points, elevationPoint{distanceM: distanceM, elevationM: *c.Ele})
+		}
+	}
+	return points
+}
+
+func (t Track) HasElevation() bool {
+	count := 0
+	for _, c := range t {
+		if c.Ele != nil {
+			count++
+		}
+	}
+	return count >= 2
+}
+
+func (t Track) ElevationStats() *ElevationStats {
+	if !t.HasElevation() {
+		return nil
+	}
+	points := t.elevationPoints()
+
+	stats := &ElevationStats{MinM: points[0].elevationM, MaxM: points[0].elevationM}
+	anchor := points[0]
+	for i := 1; i < len(points); i++ {
+		delta := points[i].elevationM - points[i-1].elevationM
+		if delta > 0 {
+			stats.AscentM += delta
+		} else {
+			stats.DescentM -= delta
+		}
+		stats.MinM = math.Min(stats.MinM, points[i].elevationM)
+		stats.MaxM = math.Max(stats.MaxM, points[i].elevationM)
+
+		if run := points[i].distanceM - anchor.distanceM; run >= minGradeRunM {
+			grade := math.Abs(points[i].elevationM-anchor.elevationM) / run * 100
+			stats.MaxGradePct = math.Max(stats.MaxGradePct, grade)
+			anchor = points[i]
+		}
+	}
+	return stats
+}
+
+const elevationSampleToleranceM = 0.001
+
+// ElevationProfile samples the elevation every resolutionM meters along the track, always including both ends
+func (t Track) ElevationProfile(resolutionM float64) []ElevationSample {
+	if !t.HasElevation() || resolutionM <= 0 {
+		return nil
+	}
+	points := t.elevationPoints()
+	totalM := TrackLengthKm(t) * 1000
+
+	var samples []ElevationSample
+	for i := 0; float64(i)*resolutionM < totalM-elevationSampleToleranceM; i++ {
+		d := float64(i) * resolutionM
+		samples = append(samples, ElevationSample{DistanceKm: d / 1000, ElevationM: interpolateElevation(points, d)})
+	}
+	return append(samples, ElevationSample{DistanceKm: totalM / 1000, ElevationM: interpolateElevation(points, totalM)})
+}
+
+func interpolateElevation(points []elevationPoint, distanceM float64) float64 {
+	i := sort.Search(len(points), func(i int) bool { return points[i].distanceM >= distanceM })
+	if i == 0 {
+		return points[0].elevationM
+	}
+	if i == len(points) {
+		return points[len(points)-1].elevationM
+	}
+	before, after := points[i-1], points[i]
+	span := after.distanceM - before.distanceM
+	if span == 0 {
+		return after.elevationM
+	}
+	ratio := (distanceM - before.distanceM) / span
+	return before.elevationM + ratio*(after.elevationM-before.elevationM)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newElevationTestTrack climbs 20m over the first 100m, drops 5m over the next 100m,
+// then has a point without elevation before climbing again
+func newElevationTestTrack() Track {
+	ele := func(v float64) *float64 { return &v }
+	step := 100 / (kmPerDegreeLat * 1000)
+	return Track{
+		{Lat: 45, Lon: -110, Ele: ele(1000)},
+		{Lat: 45 + step, Lon: -110, Ele: ele(1020)},
+		{Lat: 45 + 2*step, Lon: -110, Ele: ele(1015)},
+		{Lat: 45 + 3*step, Lon: -110},
+		{Lat: 45 + 4*step, Lon: -110, Ele: ele(1035)},
+	}
+}
+
+func TestTrack_ElevationStats(t *testing.T) {
+	stats := newElevationTestTrack().ElevationStats()
+	require.NotNil(t, stats)
+
+	assert.InDelta(t, 40, stats.AscentM, 1e-9)
+	assert.InDelta(t, 5, stats.DescentM, 1e-9)
+	assert.Equal(t, 1000.0, stats.MinM)
+	assert.Equal(t, 1035.0, stats.MaxM)
+	// The steepest section is the 20m climb over the first 100m
+	assert.InDelta(t, 20, stats.MaxGradePct, 0.1)
+
+	flat := Track{{Lat: 45, Lon: -110}, {Lat: 45.001, Lon: -110}}
+	assert.False(t, flat.HasElevation())
+	assert.Nil(t, flat.ElevationStats())
+}
+
+func TestTrack_ElevationProfile(t *testing.T) {
+	track := newElevationTestTrack()
+
+	samples := track.ElevationProfile(50)
+	require.Len(t, samples, 9)
+
+	assert.Equal(t, 0.0, samples[0].DistanceKm)
+	assert.Equal(t, 1000.0, samples[0].ElevationM)
+	assert.InDelta(t, 0.05, samples[1].DistanceKm, 1e-9)
+	assert.InDelta(t, 1010, samples[1].ElevationM, 0.1)
+	assert.InDelta(t, 1020, samples[2].ElevationM, 0.1)
+	// Halfway across the gap left by the point without elevation
+	assert.InDelta(t, 1025, samples[6].ElevationM, 0.1)
+	assert.InDelta(t, TrackLengthKm(track), samples[8].DistanceKm, 1e-9)
+	assert.Equal(t, 1035.0, samples[8].ElevationM)
+
+	assert.Nil(t, Track{{Lat: 45, Lon: -110}, {Lat: 45.001, Lon: -110}}.ElevationProfile(50))
+}
+
+func TestTrack_ElevationProfileEvenSteps(t *testing.T) {
+	track := newElevationTestTrack()
+	totalM := TrackLengthKm(track) * 1000
+
+	// Steps that divide the track evenly must not add a sample just before the end, however the distances round
+	for steps := 2; steps <= 30; steps++ {
+		samples := track.ElevationProfile(totalM / float64(steps))
+		require.Len(t, samples, steps+1, "%d steps", steps)
+		last, beforeLast := samples[steps].DistanceKm, samples[steps-1].DistanceKm
+		assert.InDelta(t, totalM/float64(steps)/1000, last-beforeLast, 1e-9, "%d steps", steps)
+	}
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The kinds of failure callers need to tell apart, storage and services wrap them into specific errors
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrDuplicate  = errors.New("already exists")
+	ErrValidation = errors.New("invalid request")
+	// ErrUnauthenticated is for credentials, tokens and keys that are wrong, expired or revoked
+	ErrUnauthenticated = errors.New("not authenticated")
+	ErrForbidden       = errors.New("forbidden")
+	ErrLocked          = errors.New("locked")
+	// ErrUnavailable is for services this one depends on failing, such as an identity provider
+	ErrUnavailable = errors.New("unavailable")
+	ErrOverloaded  = errors.New("overloaded")
+)
+
+// Errorf keeps both kind and any %w error reachable with errors.Is
+func Errorf(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
+}
+
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+type FieldError struct {
+	// Field is empty for problems with the request as a whole
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found with a request rather than stopping at the first
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func NewValidationError(field string, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+func (e *ValidationError) Add(field string, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+func (e *ValidationError) Addf(field string, format string, args ...any) {
+	e.Add(field, fmt.Sprintf(format, args...))
+}
+
+func (e *ValidationError) Merge(err error) {
+	var other *ValidationError
+	if !errors.As(err, &other) {
+		return
+	}
+	e.Fields = append(e.Fields, other.Fields...)
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ConflictError lists the existing resources a request clashes with, e.g. the trails a new trail duplicates
+type ConflictError struct {
+	Err error
+	IDs []string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// ProblemContentType is the media type of error responses, see RFC 7807
+const ProblemContentType = "application/problem+json"
+
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Conflicts []string     `json:"conflicts,omitempty"`
+}
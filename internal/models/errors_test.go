@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorf(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := Errorf(ErrUnavailable, "failed to fetch keys: %w", cause)
+
+	assert.EqualError(t, err, "failed to fetch keys: connection refused")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.ErrorIs(t, err, cause)
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	// The kind survives further wrapping
+	assert.ErrorIs(t, fmt.Errorf("login: %w", err), ErrUnavailable)
+}
+
+func TestValidationError(t *testing.T) {
+	errs := &ValidationError{}
+	assert.NoError(t, errs.Err())
+
+	errs.Add("name", "trail name is required")
+	errs.Merge(Track{{Lat: 44.8, Lon: -109.6}}.Validate())
+	errs.Merge(errors.New("not a validation error"))
+
+	err := errs.Err()
+	assert.EqualError(t, err, "trail name is required; trail track must have at least 2 points")
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Message: "trail name is required"},
+		{Field: "track", Message: "trail track must have at least 2 points"},
+	}, errs.Fields)
+
+	var validation *ValidationError
+	assert.ErrorAs(t, fmt.Errorf("create trail: %w", err), &validation)
+}
+
+func TestConflictError(t *testing.T) {
+	exists := Errorf(ErrDuplicate, "trail already exists")
+	err := fmt.Errorf("import: %w", &ConflictError{Err: exists, IDs: []string{"a", "b"}})
+
+	assert.EqualError(t, err, "import: trail already exists")
+	assert.ErrorIs(t, err, exists)
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	var conflict *ConflictError
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, []string{"a", "b"}, conflict.IDs)
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExportTestTrail() *Trail {
+	ele := 2010.0
+	trail := NewTrailFromTrack("Lamar River Trail", []Coordinate{
+		{Lat: 44.8472, Lon: -109.6278},
+		{Lat: 44.8500, Lon: -109.6200, Ele: &ele},
+	}, []Waypoint{{Name: "Cache Creek", Lat: 44.8480, Lon: -109.6270}})
+	difficulty := TrailDifficultyHard
+	trail.Difficulty = &difficulty
+	return trail
+}
+
+func TestTrail_WriteGPX(t *testing.T) {
+	trail := newExportTestTrail()
+
+	var buf bytes.Buffer
+	require.NoError(t, trail.WriteGPX(&buf))
+	out := buf.String()
+
+	assert.True(t, strings.HasPrefix(out, xml.Header))
+	assert.Contains(t, out, `<gpx xmlns="http://www.topografix.com/GPX/1/1" version="1.1" creator="trail-data-service">`)
+	assert.Contains(t, out, `<desc>Difficulty: hard, Length: 0.7 km</desc>`)
+
+	// The export must read back through the importer unchanged
+	parsed, err := ParseGPX(&buf)
+	require.NoError(t, err)
+	require.Len(t, parsed, 1)
+	assert.Equal(t, "Lamar River Trail", *parsed[0].Name)
+	assert.Equal(t, trail.Track, parsed[0].Track)
+	require.Len(t, parsed[0].Waypoints, 2)
+	assert.Equal(t, "Start", parsed[0].Waypoints[0].Name)
+	assert.Equal(t, "Cache Creek", parsed[0].Waypoints[1].Name)
+}
+
+func TestTrail_WriteKML(t *testing.T) {
+	trail := newExportTestTrail()
+
+	var buf bytes.Buffer
+	require.NoError(t, trail.WriteKML(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, `<kml xmlns="http://www.opengis.net/kml/2.2">`)
+	assert.Contains(t, out, `<name>Cache Creek</name>`)
+	assert.Contains(t, out, `<coordinates>-109.6278,44.8472</coordinates>`)
+	assert.Contains(t, out, `<coordinates>-109.6278,44.8472 -109.62,44.85,2010</coordinates>`)
+
+	var doc kmlDocument
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Len(t, doc.Document.Placemarks, 3)
+}
+
+func TestTrail_FileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		trail    string
+		expected string
+	}{
+		{name: "spaces and punctuation", trail: "Angel's Rest (via Trail #415)", expected: "angel-s-rest-via-trail-415.gpx"},
+		{name: "diacritics", trail: "Ruta del Cañón", expected: "ruta-del-canon.gpx"},
+		{name: "nothing usable", trail: "!!!", expected: "trail.gpx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trail := NewTrail(tt.trail, 0, 0, TrailDifficultyEasy, 1)
+			assert.Equal(t, tt.expected, trail.FileName("gpx"))
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import "math"
+
+const (
+	// earthRadiusKm is the radius used by haversine.Distance, boxes and flat projections must agree with it
+	earthRadiusKm  = 6371.0
+	kmPerDegreeLat = earthRadiusKm * math.Pi / 180
+	// boundingBoxMarginDeg pads boxes so trails right on the circle are not lost to rounding
+	boundingBoxMarginDeg = 1e-9
+)
+
+// BoundingBox longitudes may fall outside -180..180 when the circle crosses the antimeridian,
+// and span the full range when the circle reaches a pole
+func BoundingBox(lat, lon, radiusKm float64) (minLat, minLon, maxLat, maxLon float64) {
+	latDelta := radiusKm/kmPerDegreeLat + boundingBoxMarginDeg
+	minLat = math.Max(lat-latDelta, -90)
+	maxLat = math.Min(lat+latDelta, 90)
+
+	if minLat == -90 || maxLat == 90 {
+		return minLat, -180, maxLat, 180
+	}
+
+	// The circle is widest in longitude where great circles through the pole touch it, which is poleward
+	// of the center, so its longitude reach comes from the spherical formula rather than the center's latitude
+	angularRadius := radiusKm / earthRadiusKm
+	lonDelta := math.Asin(math.Sin(angularRadius)/math.Cos(lat*math.Pi/180))*180/math.Pi + boundingBoxMarginDeg
+	if math.IsNaN(lonDelta) || lonDelta >= 180 {
+		return minLat, -180, maxLat, 180
+	}
+	return minLat, lon - lonDelta, maxLat, lon + lonDelta
+}
+
+// InitialBearing is 0 to 360 degrees clockwise from north
+func InitialBearing(fromLat, fromLon, toLat, toLon float64) float64 {
+	lat1 := fromLat * math.Pi / 180
+	lat2 := toLat * math.Pi / 180
+	lonDelta := (toLon - fromLon) * math.Pi / 180
+
+	y := math.Sin(lonDelta) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(lonDelta)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
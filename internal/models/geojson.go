@@ -0,0 +1,82 @@
+package models
+
+import "encoding/json"
+
+const GeoJSONContentType = "application/geo+json"
+
+type GeoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+type GeoJSONFeature struct {
+	Type       string           `json:"type"`
+	ID         string           `json:"id"`
+	Geometry   *GeoJSONGeometry `json:"geometry"`
+	Properties map[string]any   `json:"properties"`
+}
+
+// GeoJSONFeatureCollection carries the paging details of a listing as foreign members alongside the features
+type GeoJSONFeatureCollection struct {
+	Type       string            `json:"type"`
+	Features   []*GeoJSONFeature `json:"features"`
+	NextCursor *string           `json:"next_cursor,omitempty"`
+	Total      *int              `json:"total,omitempty"`
+}
+
+// ToGeoJSONFeature falls back to the start point as the geometry when the trail has no track
+func (t *Trail) ToGeoJSONFeature() (*GeoJSONFeature, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	properties := map[string]any{}
+	if err := json.Unmarshal(data, &properties); err != nil {
+		return nil, err
+	}
+	delete(properties, "lat")
+	delete(properties, "lon")
+	delete(properties, "track")
+
+	feature := &GeoJSONFeature{
+		Type:       "Feature",
+		ID:         t.UID.String(),
+		Properties: properties,
+	}
+	if len(t.Track) > 0 {
+		coordinates := make([][]float64, len(t.Track))
+		for i, c := range t.Track {
+			coordinates[i] = []float64{c.Lon, c.Lat}
+			if c.Ele != nil {
+				coordinates[i] = append(coordinates[i], *c.Ele)
+			}
+		}
+		feature.Geometry = &GeoJSONGeometry{
+			Type:        "LineString",
+			Coordinates: coordinates,
+		}
+	} else if t.Lat != nil && t.Lon != nil {
+		feature.Geometry = &GeoJSONGeometry{
+			Type:        "Point",
+			Coordinates: []float64{*t.Lon, *t.Lat},
+		}
+	}
+	return feature, nil
+}
+
+func (p *TrailPage) ToGeoJSON() (*GeoJSONFeatureCollection, error) {
+	features := make([]*GeoJSONFeature, 0, len(p.Trails))
+	for _, trail := range p.Trails {
+		feature, err := trail.ToGeoJSONFeature()
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, feature)
+	}
+	return &GeoJSONFeatureCollection{
+		Type:       "FeatureCollection",
+		Features:   features,
+		NextCursor: p.NextCursor,
+		Total:      &p.Total,
+	}, nil
+}
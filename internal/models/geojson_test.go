@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrail_ToGeoJSONFeature(t *testing.T) {
+	trail := NewTrail("Lamar River Trail", 44.8472, -109.6278, TrailDifficultyHard, 53)
+
+	feature, err := trail.ToGeoJSONFeature()
+	require.NoError(t, err)
+
+	assert.Equal(t, "Feature", feature.Type)
+	assert.Equal(t, trail.UID.String(), feature.ID)
+	assert.Equal(t, "Point", feature.Geometry.Type)
+	assert.Equal(t, []float64{-109.6278, 44.8472}, feature.Geometry.Coordinates)
+	assert.Equal(t, "Lamar River Trail", feature.Properties["name"])
+	assert.Equal(t, "hard", feature.Properties["difficulty"])
+	assert.Equal(t, 53.0, feature.Properties["length_km"])
+	assert.NotContains(t, feature.Properties, "lat")
+	assert.NotContains(t, feature.Properties, "lon")
+}
+
+func TestTrail_ToGeoJSONFeatureWithTrack(t *testing.T) {
+	ele := 2000.0
+	trail := NewTrailFromTrack("Lamar River Trail", []Coordinate{
+		{Lat: 44.8472, Lon: -109.6278, Ele: &ele},
+		{Lat: 44.85, Lon: -109.62},
+	}, nil)
+
+	feature, err := trail.ToGeoJSONFeature()
+	require.NoError(t, err)
+
+	assert.Equal(t, "LineString", feature.Geometry.Type)
+	assert.Equal(t, [][]float64{{-109.6278, 44.8472, 2000}, {-109.62, 44.85}}, feature.Geometry.Coordinates)
+	assert.NotContains(t, feature.Properties, "track")
+}
+
+func TestTrailPage_ToGeoJSON(t *testing.T) {
+	next := "cursor"
+	page := &TrailPage{
+		Trails: []*Trail{
+			NewTrail("Lamar River Trail", 44.8472, -109.6278, TrailDifficultyHard, 53),
+			NewTrail("Angel's Rest", 45.6789, -122.3456, TrailDifficultyMedium, 7.8),
+		},
+		NextCursor: &next,
+		Total:      5,
+	}
+
+	collection, err := page.ToGeoJSON()
+	require.NoError(t, err)
+
+	data, err := json.Marshal(collection)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "FeatureCollection", decoded["type"])
+	assert.Len(t, decoded["features"], 2)
+	assert.Equal(t, "cursor", decoded["next_cursor"])
+	assert.Equal(t, 5.0, decoded["total"])
+}
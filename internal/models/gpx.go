@@ -0,0 +1,157 @@
+package models
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	GPXContentType = "application/gpx+xml"
+
+	gpxNamespace = "http://www.topografix.com/GPX/1/1"
+	gpxCreator   = "trail-data-service"
+)
+
+// Field order follows the GPX 1.1 schema, which requires child elements in sequence
+type gpxDocument struct {
+	XMLName   xml.Name    `xml:"gpx"`
+	Xmlns     string      `xml:"xmlns,attr,omitempty"`
+	Version   string      `xml:"version,attr,omitempty"`
+	Creator   string      `xml:"creator,attr,omitempty"`
+	Metadata  gpxMetadata `xml:"metadata"`
+	Waypoints []gpxPoint  `xml:"wpt"`
+	Routes    []gpxRoute  `xml:"rte"`
+	Tracks    []gpxTrack  `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name,omitempty"`
+	Desc string `xml:"desc,omitempty"`
+}
+
+type gpxPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele"`
+	Name string   `xml:"name,omitempty"`
+}
+
+type gpxRoute struct {
+	Name   string     `xml:"name,omitempty"`
+	Points []gpxPoint `xml:"rtept"`
+}
+
+type gpxTrack struct {
+	Name     string            `xml:"name,omitempty"`
+	Desc     string            `xml:"desc,omitempty"`
+	Segments []gpxTrackSegment `xml:"trkseg"`
+}
+
+type gpxTrackSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+// ParseGPX joins track segments into a single line and attaches each waypoint to the trail passing closest to it
+func ParseGPX(r io.Reader) ([]*Trail, error) {
+	var doc gpxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid gpx: %w", err)
+	}
+
+	var trails []*Trail
+	for _, trk := range doc.Tracks {
+		var track []Coordinate
+		for _, seg := range trk.Segments {
+			for _, pt := range seg.Points {
+				track = append(track, pt.coordinate())
+			}
+		}
+		if len(track) > 0 {
+			trails = append(trails, NewTrailFromTrack(gpxName(trk.Name, doc.Metadata.Name, len(trails)), track, nil))
+		}
+	}
+	for _, rte := range doc.Routes {
+		var track []Coordinate
+		for _, pt := range rte.Points {
+			track = append(track, pt.coordinate())
+		}
+		if len(track) > 0 {
+			trails = append(trails, NewTrailFromTrack(gpxName(rte.Name, doc.Metadata.Name, len(trails)), track, nil))
+		}
+	}
+
+	if len(trails) == 0 {
+		return nil, errors.New("invalid gpx: no tracks or routes found")
+	}
+
+	for _, wpt := range doc.Waypoints {
+		nearest := nearestTrail(trails, wpt.coordinate())
+		nearest.Waypoints = append(nearest.Waypoints, Waypoint{Name: wpt.Name, Lat: wpt.Lat, Lon: wpt.Lon, Ele: wpt.Ele})
+	}
+	return trails, nil
+}
+
+func (p gpxPoint) coordinate() Coordinate {
+	return Coordinate{Lat: p.Lat, Lon: p.Lon, Ele: p.Ele}
+}
+
+func gpxName(name, documentName string, index int) string {
+	if name != "" || documentName == "" {
+		return name
+	}
+	if index == 0 {
+		return documentName
+	}
+	return fmt.Sprintf("%s (%d)", documentName, index+1)
+}
+
+func nearestTrail(trails []*Trail, point Coordinate) *Trail {
+	nearest := trails[0]
+	best := math.Inf(1)
+	for _, trail := range trails {
+		for _, c := range trail.Track {
+			if d := DistanceKm(point, c); d < best {
+				best = d
+				nearest = trail
+			}
+		}
+	}
+	return nearest
+}
+
+// WriteGPX always writes the start point as the first waypoint
+func (t *Trail) WriteGPX(w io.Writer) error {
+	doc := gpxDocument{
+		Xmlns:    gpxNamespace,
+		Version:  "1.1",
+		Creator:  gpxCreator,
+		Metadata: gpxMetadata{Name: t.displayName(), Desc: t.summary()},
+	}
+
+	if t.Lat != nil && t.Lon != nil {
+		doc.Waypoints = append(doc.Waypoints, gpxPoint{Lat: *t.Lat, Lon: *t.Lon, Name: "Start"})
+	}
+	for _, wpt := range t.Waypoints {
+		doc.Waypoints = append(doc.Waypoints, gpxPoint{Lat: wpt.Lat, Lon: wpt.Lon, Ele: wpt.Ele, Name: wpt.Name})
+	}
+	if len(t.Track) > 0 {
+		seg := gpxTrackSegment{Points: make([]gpxPoint, len(t.Track))}
+		for i, c := range t.Track {
+			seg.Points[i] = gpxPoint{Lat: c.Lat, Lon: c.Lon, Ele: c.Ele}
+		}
+		doc.Tracks = append(doc.Tracks, gpxTrack{Name: t.displayName(), Desc: t.summary(), Segments: []gpxTrackSegment{seg}})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <metadata><name>Yellowstone Day Hikes</name></metadata>
+  <wpt lat="44.8480" lon="-109.6270"><name>Trailhead</name></wpt>
+  <wpt lat="45.6795" lon="-122.3450"><name>Viewpoint</name><ele>480</ele></wpt>
+  <trk>
+    <name>Lamar River Trail</name>
+    <trkseg>
+      <trkpt lat="44.8472" lon="-109.6278"><ele>2000</ele></trkpt>
+      <trkpt lat="44.8500" lon="-109.6200"><ele>2010</ele></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="44.8550" lon="-109.6100"><ele>2025</ele></trkpt>
+    </trkseg>
+  </trk>
+  <rte>
+    <rtept lat="45.6789" lon="-122.3456"/>
+    <rtept lat="45.6800" lon="-122.3400"/>
+  </rte>
+</gpx>`
+
+func TestParseGPX(t *testing.T) {
+	trails, err := ParseGPX(strings.NewReader(testGPX))
+	require.NoError(t, err)
+	require.Len(t, trails, 2)
+
+	lamar := trails[0]
+	assert.Equal(t, "Lamar River Trail", *lamar.Name)
+	assert.Len(t, lamar.Track, 3)
+	assert.Equal(t, 44.8472, *lamar.Lat)
+	assert.Equal(t, -109.6278, *lamar.Lon)
+	assert.Equal(t, 2000.0, *lamar.Track[0].Ele)
+	assert.InDelta(t, TrackLengthKm(lamar.Track), *lamar.LengthKm, 1e-9)
+	assert.InDelta(t, 1.6, *lamar.LengthKm, 0.1)
+	assert.Nil(t, lamar.Difficulty)
+	require.Len(t, lamar.Waypoints, 1)
+	assert.Equal(t, "Trailhead", lamar.Waypoints[0].Name)
+
+	// Unnamed routes fall back to the document name
+	route := trails[1]
+	assert.Equal(t, "Yellowstone Day Hikes (2)", *route.Name)
+	assert.Len(t, route.Track, 2)
+	assert.Nil(t, route.Track[0].Ele)
+	require.Len(t, route.Waypoints, 1)
+	assert.Equal(t, "Viewpoint", route.Waypoints[0].Name)
+	assert.Equal(t, 480.0, *route.Waypoints[0].Ele)
+}
+
+func TestParseGPX_Invalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		gpx           string
+		expectedError string
+	}{
+		{
+			name:          "not xml",
+			gpx:           "not a gpx file",
+			expectedError: "invalid gpx: EOF",
+		},
+		{
+			name:          "only waypoints",
+			gpx:           `<gpx><wpt lat="44.8480" lon="-109.6270"/></gpx>`,
+			expectedError: "invalid gpx: no tracks or routes found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseGPX(strings.NewReader(tt.gpx))
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
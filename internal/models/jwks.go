@@ -0,0 +1,19 @@
+package models
+
+// JWK is the public half of a token signing key in JSON Web Key form (RFC 7517). RSA keys
+// set N and E, elliptic curve keys set Crv, X and Y, all base64url encoded without padding.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	KMLContentType = "application/vnd.google-earth.kml+xml"
+
+	kmlNamespace = "http://www.opengis.net/kml/2.2"
+)
+
+type kmlDocument struct {
+	XMLName  xml.Name  `xml:"kml"`
+	Xmlns    string    `xml:"xmlns,attr"`
+	Document kmlFolder `xml:"Document"`
+}
+
+type kmlFolder struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description,omitempty"`
+	Placemarks  []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string         `xml:"name,omitempty"`
+	Description string         `xml:"description,omitempty"`
+	Point       *kmlGeometry   `xml:"Point,omitempty"`
+	LineString  *kmlLineString `xml:"LineString,omitempty"`
+}
+
+type kmlGeometry struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+func (t *Trail) WriteKML(w io.Writer) error {
+	doc := kmlDocument{
+		Xmlns:    kmlNamespace,
+		Document: kmlFolder{Name: t.displayName(), Description: t.summary()},
+	}
+
+	if t.Lat != nil && t.Lon != nil {
+		doc.Document.Placemarks = append(doc.Document.Placemarks, kmlPlacemark{
+			Name:  "Start",
+			Point: &kmlGeometry{Coordinates: kmlCoordinate(*t.Lat, *t.Lon, nil)},
+		})
+	}
+	for _, wpt := range t.Waypoints {
+		doc.Document.Placemarks = append(doc.Document.Placemarks, kmlPlacemark{
+			Name:  wpt.Name,
+			Point: &kmlGeometry{Coordinates: kmlCoordinate(wpt.Lat, wpt.Lon, wpt.Ele)},
+		})
+	}
+	if len(t.Track) > 0 {
+		coords := make([]string, len(t.Track))
+		for i, c := range t.Track {
+			coords[i] = kmlCoordinate(c.Lat, c.Lon, c.Ele)
+		}
+		doc.Document.Placemarks = append(doc.Document.Placemarks, kmlPlacemark{
+			Name:        t.displayName(),
+			Description: t.summary(),
+			LineString:  &kmlLineString{Tessellate: 1, Coordinates: strings.Join(coords, " ")},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func kmlCoordinate(lat, lon float64, ele *float64) string {
+	if ele == nil {
+		return fmt.Sprintf("%g,%g", lon, lat)
+	}
+	return fmt.Sprintf("%g,%g,%g", lon, lat, *ele)
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/umahmood/haversine"
+)
+
+type TrailSortField string
+
+const (
+	TrailSortByName       TrailSortField = "name"
+	TrailSortByCreatedAt  TrailSortField = "created_at"
+	TrailSortByLengthKm   TrailSortField = "length_km"
+	TrailSortByDifficulty TrailSortField = "difficulty"
+	TrailSortByDistance   TrailSortField = "distance"
+	TrailSortByRelevance  TrailSortField = "relevance"
+)
+
+// Fixed width timestamp layout so created_at sort keys compare correctly as strings
+const sortKeyTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// TrailCursor carries the sort it was issued for
+type TrailCursor struct {
+	SortBy TrailSortField `json:"sort"`
+	Desc   bool           `json:"desc,omitempty"`
+	Str    string         `json:"s,omitempty"`
+	Num    float64        `json:"n,omitempty"`
+	UID    string         `json:"id"`
+}
+
+type TrailPage struct {
+	Trails     []*Trail `json:"trails"`
+	NextCursor *string  `json:"next_cursor"`
+	Total      int      `json:"total"`
+}
+
+func IsValidTrailSortField(s string) bool {
+	switch TrailSortField(s) {
+	case TrailSortByName, TrailSortByCreatedAt, TrailSortByLengthKm, TrailSortByDifficulty, TrailSortByDistance, TrailSortByRelevance:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *TrailCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func ParseTrailCursor(s string) (*TrailCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var cursor TrailCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.UID == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// SortField defaults to distance for nearest trails, relevance for text searches and creation otherwise
+func (f *TrailFilter) SortField() TrailSortField {
+	if f != nil && f.SortBy != nil {
+		return *f.SortBy
+	}
+	if f != nil && f.Nearest != nil {
+		return TrailSortByDistance
+	}
+	if f != nil && f.Query != nil {
+		return TrailSortByRelevance
+	}
+	return TrailSortByCreatedAt
+}
+
+func (f *TrailFilter) sortDesc() bool {
+	return f != nil && f.SortDesc
+}
+
+func (f *TrailFilter) CursorFor(t *Trail) *TrailCursor {
+	cursor := f.sortKey(t)
+	return &cursor
+}
+
+func (f *TrailFilter) sortKey(t *Trail) TrailCursor {
+	key := TrailCursor{SortBy: f.SortField(), Desc: f.sortDesc(), UID: t.UID.String()}
+	switch key.SortBy {
+	case TrailSortByName:
+		key.Str = NameSortKey(t)
+	case TrailSortByCreatedAt:
+		key.Str = CreatedAtSortKey(t)
+	case TrailSortByLengthKm:
+		if t.LengthKm != nil {
+			key.Num = *t.LengthKm
+		}
+	case TrailSortByDifficulty:
+		if t.Difficulty != nil {
+			key.Num = float64(t.Difficulty.Rank())
+		}
+	case TrailSortByRelevance:
+		// Negated so the most relevant trails come first in ascending order
+		if f.Query != nil && t.Name != nil {
+			key.Num = -f.Query.Score(*t.Name)
+		}
+	case TrailSortByDistance:
+		if f.Lat != nil && f.Lon != nil && t.Lat != nil && t.Lon != nil {
+			_, key.Num = haversine.Distance(
+				haversine.Coord{Lat: *f.Lat, Lon: *f.Lon},
+				haversine.Coord{Lat: *t.Lat, Lon: *t.Lon},
+			)
+		}
+	}
+	return key
+}
+
+func NameSortKey(t *Trail) string {
+	if t.Name == nil {
+		return ""
+	}
+	return strings.ToLower(*t.Name)
+}
+
+func CreatedAtSortKey(t *Trail) string {
+	if t.CreatedAt == nil {
+		return ""
+	}
+	return TimeSortKey(*t.CreatedAt)
+}
+
+func TimeSortKey(t time.Time) string {
+	return t.UTC().Format(sortKeyTimeLayout)
+}
+
+func (c *TrailCursor) compare(other *TrailCursor) int {
+	result := strings.Compare(c.Str, other.Str)
+	if result == 0 {
+		switch {
+		case c.Num < other.Num:
+			result = -1
+		case c.Num > other.Num:
+			result = 1
+		}
+	}
+	if result == 0 {
+		result = strings.Compare(c.UID, other.UID)
+	}
+	if c.Desc {
+		return -result
+	}
+	return result
+}
+
+func (f *TrailFilter) Paginate(trails []*Trail) []*Trail {
+	keyed := make([]struct {
+		key   TrailCursor
+		trail *Trail
+	}, len(trails))
+	for i, trail := range trails {
+		keyed[i].key = f.sortKey(trail)
+		keyed[i].trail = trail
+	}
+	sort.Slice(keyed, func(i, j int) bool {
+		return keyed[i].key.compare(&keyed[j].key) < 0
+	})
+
+	page := make([]*Trail, 0, len(keyed))
+	for _, k := range keyed {
+		if f != nil && f.Cursor != nil && k.key.compare(f.Cursor) <= 0 {
+			continue
+		}
+		if f != nil && f.Limit != nil && len(page) >= *f.Limit {
+			break
+		}
+		page = append(page, k.trail)
+	}
+	return page
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPaginationTestTrails() []*Trail {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	trails := []*Trail{
+		NewTrail("Lamar River Trail", 44.8472, -109.6278, TrailDifficultyHard, 53),
+		NewTrail("angel's Rest", 45.6789, -122.3456, TrailDifficultyMedium, 7.8),
+		NewTrail("Trail of Ten Falls", 44.8779, -122.6554, TrailDifficultyEasy, 12.5),
+		NewTrail("Multnomah Falls", 45.5762, -122.1158, TrailDifficultyMedium, 3.9),
+	}
+	for i, trail := range trails {
+		createdAt := base.Add(time.Duration(i) * time.Hour)
+		trail.CreatedAt = &createdAt
+	}
+	return trails
+}
+
+func trailNames(trails []*Trail) []string {
+	names := make([]string, len(trails))
+	for i, trail := range trails {
+		names[i] = *trail.Name
+	}
+	return names
+}
+
+func TestTrailFilter_Paginate(t *testing.T) {
+	trails := newPaginationTestTrails()
+
+	sortField := func(f TrailSortField) *TrailSortField { return &f }
+	intPtr := func(i int) *int { return &i }
+
+	tests := []struct {
+		name          string
+		filter        *TrailFilter
+		expectedNames []string
+	}{
+		{
+			name:          "nil filter orders by creation",
+			filter:        nil,
+			expectedNames: []string{"Lamar River Trail", "angel's Rest", "Trail of Ten Falls", "Multnomah Falls"},
+		},
+		{
+			name:          "by name ignores case",
+			filter:        &TrailFilter{SortBy: sortField(TrailSortByName)},
+			expectedNames: []string{"angel's Rest", "Lamar River Trail", "Multnomah Falls", "Trail of Ten Falls"},
+		},
+		{
+			name:          "by length descending",
+			filter:        &TrailFilter{SortBy: sortField(TrailSortByLengthKm), SortDesc: true},
+			expectedNames: []string{"Lamar River Trail", "Trail of Ten Falls", "angel's Rest", "Multnomah Falls"},
+		},
+		{
+			name:          "by difficulty with limit",
+			filter:        &TrailFilter{SortBy: sortField(TrailSortByDifficulty), Limit: intPtr(1)},
+			expectedNames: []string{"Trail of Ten Falls"},
+		},
+		{
+			name: "by distance",
+			filter: &TrailFilter{
+				CreateTrailRequest: CreateTrailRequest{
+					Lat: float64Ptr(45.5762),
+					Lon: float64Ptr(-122.1158),
+				},
+				RadiusKm: float64Ptr(1000),
+				SortBy:   sortField(TrailSortByDistance),
+			},
+			expectedNames: []string{"Multnomah Falls", "angel's Rest", "Trail of Ten Falls", "Lamar River Trail"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := tt.filter.Paginate(trails)
+			assert.Equal(t, tt.expectedNames, trailNames(page))
+		})
+	}
+}
+
+func TestTrailFilter_PaginateWithCursor(t *testing.T) {
+	trails := newPaginationTestTrails()
+	sortBy := TrailSortByName
+	limit := 2
+
+	filter := &TrailFilter{SortBy: &sortBy, Limit: &limit}
+	first := filter.Paginate(trails)
+	require.Equal(t, []string{"angel's Rest", "Lamar River Trail"}, trailNames(first))
+
+	// Round trip the cursor through its opaque form as a client would
+	cursor, err := ParseTrailCursor(filter.CursorFor(first[len(first)-1]).Encode())
+	require.NoError(t, err)
+
+	filter.Cursor = cursor
+	require.NoError(t, filter.Validate())
+	second := filter.Paginate(trails)
+	assert.Equal(t, []string{"Multnomah Falls", "Trail of Ten Falls"}, trailNames(second))
+
+	filter.Cursor = filter.CursorFor(second[len(second)-1])
+	assert.Empty(t, filter.Paginate(trails))
+}
+
+func TestParseTrailCursor(t *testing.T) {
+	tests := []struct {
+		name          string
+		cursor        string
+		expectedError string
+	}{
+		{
+			name:   "valid cursor",
+			cursor: (&TrailCursor{SortBy: TrailSortByName, Str: "lamar river trail", UID: "abc"}).Encode(),
+		},
+		{
+			name:          "not base64",
+			cursor:        "not a cursor!",
+			expectedError: "invalid cursor",
+		},
+		{
+			name:          "missing uid",
+			cursor:        (&TrailCursor{SortBy: TrailSortByName}).Encode(),
+			expectedError: "invalid cursor",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTrailCursor(tt.cursor)
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// Encoded polylines store coordinates to 5 decimal places, roughly 1m
+const polylinePrecision = 1e5
+
+// EncodePolyline drops elevations, which are not part of the format
+func EncodePolyline(track []Coordinate) string {
+	var b strings.Builder
+	var prevLat, prevLon int64
+	for _, c := range track {
+		lat := int64(math.Round(c.Lat * polylinePrecision))
+		lon := int64(math.Round(c.Lon * polylinePrecision))
+		writePolylineValue(&b, lat-prevLat)
+		writePolylineValue(&b, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return b.String()
+}
+
+func writePolylineValue(b *strings.Builder, v int64) {
+	u := v << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	b.WriteByte(byte(u + 63))
+}
+
+func DecodePolyline(s string) ([]Coordinate, error) {
+	var track []Coordinate
+	var lat, lon int64
+	for i := 0; i < len(s); {
+		dLat, next, err := readPolylineValue(s, i)
+		if err != nil {
+			return nil, err
+		}
+		dLon, next, err := readPolylineValue(s, next)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+		lat += dLat
+		lon += dLon
+		track = append(track, Coordinate{Lat: float64(lat) / polylinePrecision, Lon: float64(lon) / polylinePrecision})
+	}
+	return track, nil
+}
+
+func readPolylineValue(s string, i int) (int64, int, error) {
+	var result int64
+	var shift uint
+	for {
+		if i >= len(s) {
+			return 0, i, errors.New("invalid polyline: truncated")
+		}
+		b := int64(s[i]) - 63
+		i++
+		if b < 0 || b > 0x3f || shift > 60 {
+			return 0, i, errors.New("invalid polyline: unexpected character")
+		}
+		result |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			break
+		}
+	}
+	if result&1 != 0 {
+		return ^(result >> 1), i, nil
+	}
+	return result >> 1, i, nil
+}
+
+type PolylineTrail struct {
+	*Trail
+	Track string `json:"track,omitempty"`
+}
+
+type PolylineTrailPage struct {
+	Trails     []*PolylineTrail `json:"trails"`
+	NextCursor *string          `json:"next_cursor"`
+	Total      int              `json:"total"`
+}
+
+func (t *Trail) WithPolylineTrack() *PolylineTrail {
+	return &PolylineTrail{Trail: t, Track: EncodePolyline(t.Track)}
+}
+
+func (p *TrailPage) WithPolylineTracks() *PolylineTrailPage {
+	trails := make([]*PolylineTrail, len(p.Trails))
+	for i, trail := range p.Trails {
+		trails[i] = trail.WithPolylineTrack()
+	}
+	return &PolylineTrailPage{Trails: trails, NextCursor: p.NextCursor, Total: p.Total}
+}
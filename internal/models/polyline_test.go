@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Example from the Google encoded polyline algorithm documentation
+var googleExampleTrack = []Coordinate{
+	{Lat: 38.5, Lon: -120.2},
+	{Lat: 40.7, Lon: -120.95},
+	{Lat: 43.252, Lon: -126.453},
+}
+
+const googleExamplePolyline = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+
+func TestEncodePolyline(t *testing.T) {
+	assert.Equal(t, googleExamplePolyline, EncodePolyline(googleExampleTrack))
+	assert.Equal(t, "", EncodePolyline(nil))
+}
+
+func TestDecodePolyline(t *testing.T) {
+	track, err := DecodePolyline(googleExamplePolyline)
+	require.NoError(t, err)
+	require.Len(t, track, len(googleExampleTrack))
+	for i, c := range googleExampleTrack {
+		assert.InDelta(t, c.Lat, track[i].Lat, 1e-9)
+		assert.InDelta(t, c.Lon, track[i].Lon, 1e-9)
+	}
+
+	_, err = DecodePolyline("_p~iF~ps|U_ulL")
+	assert.EqualError(t, err, "invalid polyline: truncated")
+
+	_, err = DecodePolyline("_p~iF ")
+	assert.EqualError(t, err, "invalid polyline: unexpected character")
+}
+
+func TestTrail_WithPolylineTrack(t *testing.T) {
+	trail := NewTrail("Test Trail", 38.5, -120.2, TrailDifficultyMedium, 10.5)
+	trail.Track = googleExampleTrack
+
+	data, err := json.Marshal(trail.WithPolylineTrack())
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, googleExamplePolyline, decoded["track"])
+	assert.Equal(t, "Test Trail", decoded["name"])
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+	// RetryAfter is how long until the next request would be allowed, zero when this one was
+	RetryAfter time.Duration
+}
+
+type TokenBucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+func NewTokenBucket(limit RateLimit, now time.Time) TokenBucket {
+	return TokenBucket{Tokens: float64(limit.Burst), Updated: now}
+}
+
+func (b *TokenBucket) Take(limit RateLimit, now time.Time) RateLimitResult {
+	if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed.Seconds()*limit.Rate)
+		b.Updated = now
+	}
+
+	result := RateLimitResult{Limit: limit.Burst}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.Tokens) / limit.Rate)
+	}
+	result.Remaining = int(math.Floor(b.Tokens))
+	result.Reset = secondsDuration((float64(limit.Burst) - b.Tokens) / limit.Rate)
+	return result
+}
+
+// IsFull lets backends forget buckets that have refilled, a new one behaves the same
+func (b *TokenBucket) IsFull(limit RateLimit, now time.Time) bool {
+	return b.Tokens+now.Sub(b.Updated).Seconds()*limit.Rate >= float64(limit.Burst)
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	// Three requests at once, then one every ten seconds
+	limit := RateLimit{Rate: 0.1, Burst: 3}
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	bucket := NewTokenBucket(limit, start)
+
+	tests := []struct {
+		name     string
+		at       time.Duration
+		expected RateLimitResult
+	}{
+		{
+			name:     "first request",
+			expected: RateLimitResult{Allowed: true, Limit: 3, Remaining: 2, Reset: 10 * time.Second},
+		},
+		{
+			name:     "second request",
+			expected: RateLimitResult{Allowed: true, Limit: 3, Remaining: 1, Reset: 20 * time.Second},
+		},
+		{
+			name:     "burst used up",
+			expected: RateLimitResult{Allowed: true, Limit: 3, Remaining: 0, Reset: 30 * time.Second},
+		},
+		{
+			name:     "empty bucket",
+			at:       4 * time.Second,
+			expected: RateLimitResult{Limit: 3, Remaining: 0, Reset: 26 * time.Second, RetryAfter: 6 * time.Second},
+		},
+		{
+			name:     "refilled one request",
+			at:       10 * time.Second,
+			expected: RateLimitResult{Allowed: true, Limit: 3, Remaining: 0, Reset: 30 * time.Second},
+		},
+		{
+			name:     "refills no further than the burst",
+			at:       time.Hour,
+			expected: RateLimitResult{Allowed: true, Limit: 3, Remaining: 2, Reset: 10 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := bucket.Take(limit, start.Add(tt.at))
+			assert.Equal(t, tt.expected.Allowed, result.Allowed)
+			assert.Equal(t, tt.expected.Limit, result.Limit)
+			assert.Equal(t, tt.expected.Remaining, result.Remaining)
+			assert.InDelta(t, tt.expected.Reset, result.Reset, float64(time.Millisecond))
+			assert.InDelta(t, tt.expected.RetryAfter, result.RetryAfter, float64(time.Millisecond))
+		})
+	}
+}
+
+func TestTokenBucket_IsFull(t *testing.T) {
+	limit := RateLimit{Rate: 1, Burst: 2}
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	bucket := NewTokenBucket(limit, start)
+	assert.True(t, bucket.IsFull(limit, start))
+
+	bucket.Take(limit, start)
+	assert.False(t, bucket.IsFull(limit, start.Add(500*time.Millisecond)))
+	assert.True(t, bucket.IsFull(limit, start.Add(time.Second)))
+}
@@ -0,0 +1,47 @@
+package models
+
+// Role is what a user is allowed to do, each role can do everything the roles below it can
+type Role string
+
+const (
+	// RoleViewer can read trails
+	RoleViewer Role = "viewer"
+	// RoleContributor can also create trails and change the ones they created
+	RoleContributor Role = "contributor"
+	// RoleModerator can also change and delete any trail
+	RoleModerator Role = "moderator"
+	// RoleAdmin can also manage the roles of other users
+	RoleAdmin Role = "admin"
+)
+
+// Rank ranks unknown roles below every real one
+func (r Role) Rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleContributor:
+		return 2
+	case RoleModerator:
+		return 3
+	case RoleAdmin:
+		return 4
+	default:
+		return 0
+	}
+}
+
+func (r Role) AtLeast(min Role) bool {
+	return r.Rank() > 0 && r.Rank() >= min.Rank()
+}
+
+func IsValidRole(s string) bool {
+	return Role(s).Rank() > 0
+}
+
+// CanChangeTrail leaves trails from before accounts existed to moderators
+func CanChangeTrail(username string, role Role, trail *Trail) bool {
+	if role.AtLeast(RoleModerator) {
+		return true
+	}
+	return role.AtLeast(RoleContributor) && trail.CreatedBy != "" && trail.CreatedBy == username
+}
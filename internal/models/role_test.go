@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRole_AtLeast(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     Role
+		min      Role
+		expected bool
+	}{
+		{name: "same role", role: RoleContributor, min: RoleContributor, expected: true},
+		{name: "higher role", role: RoleAdmin, min: RoleModerator, expected: true},
+		{name: "lower role", role: RoleViewer, min: RoleContributor, expected: false},
+		{name: "unknown role", role: Role("ranger"), min: RoleViewer, expected: false},
+		{name: "missing role", role: Role(""), min: RoleViewer, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.role.AtLeast(tt.min))
+		})
+	}
+}
+
+func TestCanChangeTrail(t *testing.T) {
+	owned := NewTrail("Lamar River Trail", 44.8472, -109.6278, TrailDifficultyHard, 53)
+	owned.CreatedBy = "ranger"
+	unowned := NewTrail("Slough Creek Trail", 44.9446, -110.3066, TrailDifficultyMedium, 17.7)
+
+	tests := []struct {
+		name     string
+		username string
+		role     Role
+		trail    *Trail
+		expected bool
+	}{
+		{name: "creator", username: "ranger", role: RoleContributor, trail: owned, expected: true},
+		{name: "another contributor", username: "hiker", role: RoleContributor, trail: owned, expected: false},
+		{name: "creator demoted to viewer", username: "ranger", role: RoleViewer, trail: owned, expected: false},
+		{name: "moderator", username: "hiker", role: RoleModerator, trail: owned, expected: true},
+		{name: "contributor on a trail without a creator", username: "ranger", role: RoleContributor, trail: unowned, expected: false},
+		{name: "admin on a trail without a creator", username: "hiker", role: RoleAdmin, trail: unowned, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CanChangeTrail(tt.username, tt.role, tt.trail))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// TextQuery terms must each match a token of the name exactly, with a few typos or, for autocomplete,
+// as the start of a longer token
+type TextQuery struct {
+	Terms  []string `json:"terms"`
+	Prefix bool     `json:"prefix,omitempty"`
+}
+
+const (
+	exactTermScore  = 1.0
+	prefixTermScore = 0.8
+	typoTermScore   = 0.6
+	// coverageScore is shared out by how much of the name the query covers, so "Lamar River"
+	// ranks "Lamar River Trail" above "Lamar River Cutoff Trail"
+	coverageScore = 0.25
+)
+
+func NewTextQuery(s string, prefix bool) *TextQuery {
+	return &TextQuery{Terms: Tokenize(s), Prefix: prefix}
+}
+
+// allowedTypos is the edit distance tolerated for a term, short terms must be spelled exactly
+func allowedTypos(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (q *TextQuery) TermMatches(i int, token string) bool {
+	return q.termScore(i, token) > 0
+}
+
+func (q *TextQuery) termScore(i int, token string) float64 {
+	term := q.Terms[i]
+	if term == token {
+		return exactTermScore
+	}
+	prefix := q.Prefix && i == len(q.Terms)-1
+	if prefix && strings.HasPrefix(token, term) {
+		return prefixTermScore
+	}
+
+	typos := allowedTypos(term)
+	if typos == 0 {
+		return 0
+	}
+	if distance := EditDistance(term, token); distance <= typos {
+		return typoTermScore / float64(distance)
+	}
+	// A misspelt start of a longer token, e.g. "lamr" for "lamar"
+	if prefix {
+		termLen := utf8.RuneCountInString(term)
+		if tokenRunes := []rune(token); len(tokenRunes) > termLen {
+			if distance := EditDistance(term, string(tokenRunes[:termLen])); distance <= typos {
+				return typoTermScore / float64(distance+1)
+			}
+		}
+	}
+	return 0
+}
+
+func (q *TextQuery) Score(name string) float64 {
+	tokens := Tokenize(name)
+	if len(q.Terms) == 0 || len(tokens) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for i := range q.Terms {
+		best := 0.0
+		for _, token := range tokens {
+			best = max(best, q.termScore(i, token))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	coverage := min(float64(len(q.Terms))/float64(len(tokens)), 1)
+	return total/float64(len(q.Terms)) + coverageScore*coverage
+}
+
+type TrailSuggestion struct {
+	UID  uuid.UUID `json:"trail_id"`
+	Name string    `json:"name"`
+}
+
+type TrailSuggestions struct {
+	Suggestions []TrailSuggestion `json:"suggestions"`
+}
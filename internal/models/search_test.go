@@ -0,0 +1,105 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"angels", "rest"}, Tokenize("Angel's Rest"))
+	assert.Equal(t, []string{"canon", "del", "rio", "2"}, Tokenize("  Cañón del Río #2 "))
+	assert.Equal(t, []string{"mt", "hood", "timberline"}, Tokenize("Mt. Hood–Timberline"))
+	assert.Empty(t, Tokenize("!!!"))
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "lamar", b: "lamar", expected: 0},
+		{a: "lamar", b: "lamr", expected: 1},
+		{a: "lamar", b: "lamra", expected: 1},
+		{a: "lamar", b: "lemur", expected: 2},
+		{a: "river", b: "", expected: 5},
+		{a: "cañon", b: "canon", expected: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.expected, EditDistance(tt.a, tt.b))
+			assert.Equal(t, tt.expected, EditDistance(tt.b, tt.a))
+		})
+	}
+}
+
+func TestTextQuery_Score(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   *TextQuery
+		matches []string
+		misses  []string
+	}{
+		{
+			name:    "case and accents are ignored",
+			query:   NewTextQuery("lamar RIVER", false),
+			matches: []string{"Lamar River Trail", "Lámar River"},
+			misses:  []string{"Lamar Trail", "River Walk"},
+		},
+		{
+			name:    "typos",
+			query:   NewTextQuery("lamr rivr", false),
+			matches: []string{"Lamar River Trail"},
+			misses:  []string{"Lemur Rover"},
+		},
+		{
+			name:    "short terms must be exact",
+			query:   NewTextQuery("ten", false),
+			matches: []string{"Trail of Ten Falls"},
+			misses:  []string{"Tin Mine Trail", "Tenaya Lake"},
+		},
+		{
+			name:    "prefix of the last term",
+			query:   NewTextQuery("lamar riv", true),
+			matches: []string{"Lamar River Trail"},
+			misses:  []string{"Lamar Loop", "Driver Lamar Trail"},
+		},
+		{
+			name:    "misspelt prefix",
+			query:   NewTextQuery("lmar", true),
+			matches: []string{"Lamar River Trail"},
+			misses:  []string{"Angel's Rest"},
+		},
+		{
+			name:   "prefix only applies to the last term",
+			query:  NewTextQuery("lam river", true),
+			misses: []string{"Lamar River Trail"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range tt.matches {
+				assert.Positive(t, tt.query.Score(name), name)
+			}
+			for _, name := range tt.misses {
+				assert.Zero(t, tt.query.Score(name), name)
+			}
+		})
+	}
+}
+
+func TestTextQuery_ScoreRanking(t *testing.T) {
+	query := NewTextQuery("lamar river", false)
+	exact := query.Score("Lamar River")
+	shortName := query.Score("Lamar River Trail")
+	longName := query.Score("Lamar River Cutoff Trail")
+	typo := query.Score("Lamar Rivers Trail")
+
+	assert.Greater(t, exact, shortName)
+	assert.Greater(t, shortName, longName)
+	assert.Greater(t, shortName, typo)
+
+	prefix := NewTextQuery("lamar rive", true)
+	assert.Positive(t, prefix.Score("Lamar Rover Trail"))
+	assert.Greater(t, prefix.Score("Lamar River Trail"), prefix.Score("Lamar Rover Trail"))
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// TokenPair keeps the original "token" name for the access token
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// RefreshToken shares the family of the login it descends from, so reuse of a rotated token can revoke the whole chain
+type RefreshToken struct {
+	Hash      string
+	FamilyID  string
+	Username  string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	Revoked   bool
+}
+
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/umahmood/haversine"
+)
+
+const (
+	MinTrackPoints = 2
+	// MaxTrackSegmentKm is the longest gap between track points before it is taken for bad data
+	MaxTrackSegmentKm = 25.0
+)
+
+type Coordinate struct {
+	Lat float64  `json:"lat"`
+	Lon float64  `json:"lon"`
+	Ele *float64 `json:"ele,omitempty"`
+}
+
+// Track is a list of coordinates or an encoded polyline string in JSON
+type Track []Coordinate
+
+func (t *Track) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var encoded string
+		if err := json.Unmarshal(data, &encoded); err != nil {
+			return err
+		}
+		decoded, err := DecodePolyline(encoded)
+		if err != nil {
+			return err
+		}
+		*t = decoded
+		return nil
+	}
+
+	var coords []Coordinate
+	if err := json.Unmarshal(data, &coords); err != nil {
+		return err
+	}
+	*t = coords
+	return nil
+}
+
+func (t Track) Validate() error {
+	errs := &ValidationError{}
+	if len(t) < MinTrackPoints {
+		errs.Addf("track", "trail track must have at least %d points", MinTrackPoints)
+	}
+	previousValid := false
+	for i, c := range t {
+		valid := true
+		if c.Lat < -90 || c.Lat > 90 {
+			errs.Addf(fmt.Sprintf("track[%d].lat", i), "trail track point %d latitude must be between -90 and 90", i)
+			valid = false
+		}
+		if c.Lon < -180 || c.Lon > 180 {
+			errs.Addf(fmt.Sprintf("track[%d].lon", i), "trail track point %d longitude must be between -180 and 180", i)
+			valid = false
+		}
+		if valid && previousValid {
+			if d := DistanceKm(t[i-1], c); d > MaxTrackSegmentKm {
+				errs.Addf(fmt.Sprintf("track[%d]", i), "trail track points %d and %d are %.1f km apart, more than %.0f km", i-1, i, d, MaxTrackSegmentKm)
+			}
+		}
+		previousValid = valid
+	}
+	return errs.Err()
+}
+
+type Waypoint struct {
+	Name string   `json:"name,omitempty"`
+	Lat  float64  `json:"lat"`
+	Lon  float64  `json:"lon"`
+	Ele  *float64 `json:"ele,omitempty"`
+}
+
+func DistanceKm(a, b Coordinate) float64 {
+	_, km := haversine.Distance(
+		haversine.Coord{Lat: a.Lat, Lon: a.Lon},
+		haversine.Coord{Lat: b.Lat, Lon: b.Lon},
+	)
+	return km
+}
+
+func TrackLengthKm(track []Coordinate) float64 {
+	total := 0.0
+	for i := 1; i < len(track); i++ {
+		total += DistanceKm(track[i-1], track[i])
+	}
+	return total
+}
+
+// NewTrailFromTrack leaves the difficulty for the caller to provide
+func NewTrailFromTrack(name string, track []Coordinate, waypoints []Waypoint) *Trail {
+	trail := NewTrailFromRequest(&CreateTrailRequest{Name: &name, Track: track})
+	trail.Waypoints = waypoints
+	trail.DeriveFromTrack()
+	return trail
+}
+
+// DeriveFromTrack fills in the start point and length from the track when they are not given
+func (t *CreateTrailRequest) DeriveFromTrack() {
+	if len(t.Track) == 0 {
+		return
+	}
+	if t.Lat == nil && t.Lon == nil {
+		lat, lon := t.Track[0].Lat, t.Track[0].Lon
+		t.Lat = &lat
+		t.Lon = &lon
+	}
+	if t.LengthKm == nil {
+		lengthKm := TrackLengthKm(t.Track)
+		t.LengthKm = &lengthKm
+	}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrack_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name          string
+		json          string
+		expectedLen   int
+		expectedError bool
+	}{
+		{name: "list of points", json: `{"track":[{"lat":44.8472,"lon":-109.6278,"ele":2000},{"lat":44.85,"lon":-109.62}]}`, expectedLen: 2},
+		{name: "encoded polyline", json: `{"track":"_p~iF~ps|U_ulLnnqC_mqNvxq` + "`" + `@"}`, expectedLen: 3},
+		{name: "absent", json: `{}`, expectedLen: 0},
+		{name: "invalid polyline", json: `{"track":"_p~iF~ps|U_ulL"}`, expectedError: true},
+		{name: "wrong type", json: `{"track":42}`, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req CreateTrailRequest
+			err := json.Unmarshal([]byte(tt.json), &req)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, req.Track, tt.expectedLen)
+		})
+	}
+}
+
+func TestTrack_Validate(t *testing.T) {
+	tests := []struct {
+		name          string
+		track         Track
+		expectedError string
+	}{
+		{
+			name:  "valid track",
+			track: Track{{Lat: 44.8472, Lon: -109.6278}, {Lat: 44.85, Lon: -109.62}},
+		},
+		{
+			name:          "single point",
+			track:         Track{{Lat: 44.8472, Lon: -109.6278}},
+			expectedError: "trail track must have at least 2 points",
+		},
+		{
+			name:          "latitude out of bounds",
+			track:         Track{{Lat: 44.8472, Lon: -109.6278}, {Lat: 94.85, Lon: -109.62}},
+			expectedError: "trail track point 1 latitude must be between -90 and 90",
+		},
+		{
+			name:          "longitude out of bounds",
+			track:         Track{{Lat: 44.8472, Lon: -189.6278}, {Lat: 44.85, Lon: -109.62}},
+			expectedError: "trail track point 0 longitude must be between -180 and 180",
+		},
+		{
+			name:          "absurd jump",
+			track:         Track{{Lat: 44.8472, Lon: -109.6278}, {Lat: 44.85, Lon: -109.62}, {Lat: 45.6789, Lon: -122.3456}},
+			expectedError: "trail track points 1 and 2 are 999.1 km apart, more than 25 km",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.track.Validate()
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestCreateTrailRequest_DeriveFromTrack(t *testing.T) {
+	track := Track{{Lat: 44.8472, Lon: -109.6278}, {Lat: 44.85, Lon: -109.62}}
+
+	req := &CreateTrailRequest{Track: track}
+	req.DeriveFromTrack()
+	assert.Equal(t, 44.8472, *req.Lat)
+	assert.Equal(t, -109.6278, *req.Lon)
+	assert.InDelta(t, TrackLengthKm(track), *req.LengthKm, 1e-9)
+
+	// Values given by the client are kept
+	req = &CreateTrailRequest{Track: track, LengthKm: float64Ptr(5), Lat: float64Ptr(44.9), Lon: float64Ptr(-109.7)}
+	req.DeriveFromTrack()
+	assert.Equal(t, 5.0, *req.LengthKm)
+	assert.Equal(t, 44.9, *req.Lat)
+}
+
+func TestCreateTrailRequest_ApplyPatchTrack(t *testing.T) {
+	trail := NewTrail("Test Trail", 45.5231, -122.6765, TrailDifficultyMedium, 10.5)
+
+	track := Track{{Lat: 44.8472, Lon: -109.6278}, {Lat: 44.85, Lon: -109.62}}
+	trail.ApplyPatch(&TrailPatch{CreateTrailRequest: CreateTrailRequest{Track: track}})
+
+	// Replacing the track moves the start and re-measures the length
+	assert.Equal(t, 44.8472, *trail.Lat)
+	assert.Equal(t, -109.6278, *trail.Lon)
+	assert.InDelta(t, TrackLengthKm(track), *trail.LengthKm, 1e-9)
+
+	trail.ApplyPatch(&TrailPatch{CreateTrailRequest: CreateTrailRequest{Track: Track{}}})
+	assert.Nil(t, trail.Track)
+	assert.NoError(t, trail.Validate())
+}
@@ -1,7 +1,11 @@
 package models
 
 import (
-	"errors"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,48 +26,78 @@ type CreateTrailRequest struct {
 	Lon        *float64         `json:"lon"`
 	Difficulty *TrailDifficulty `json:"difficulty"`
 	LengthKm   *float64         `json:"length_km"`
+	Track      Track            `json:"track,omitempty"`
 }
 
 type Trail struct {
 	CreateTrailRequest
-	UID       uuid.UUID  `json:"trail_id"`
-	CreatedAt *time.Time `json:"created_at"`
+	UID             uuid.UUID       `json:"trail_id"`
+	CreatedAt       *time.Time      `json:"created_at"`
+	Waypoints       []Waypoint      `json:"waypoints,omitempty"`
+	Elevation       *ElevationStats `json:"elevation,omitempty"`
+	DifficultyScore *float64        `json:"difficulty_score,omitempty"`
+	// DifficultyRated difficulties are rated again whenever the trail changes
+	DifficultyRated bool `json:"difficulty_rated,omitempty"`
+	// CreatedBy is empty for trails from before accounts existed
+	CreatedBy string `json:"created_by,omitempty"`
+	UpdatedBy string `json:"updated_by,omitempty"`
+	// DistanceKm and BearingDeg are from the point a listing was searched around and are not stored
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+	BearingDeg *float64 `json:"bearing_deg,omitempty"`
 }
 
 type TrailFilter struct {
 	CreateTrailRequest
-	RadiusKm *float64 `json:"radius_km"`
+	RadiusKm     *float64          `json:"radius_km"`
+	SortBy       *TrailSortField   `json:"sort_by"`
+	SortDesc     bool              `json:"sort_desc"`
+	Limit        *int              `json:"limit"`
+	Cursor       *TrailCursor      `json:"cursor"`
+	Owner        *string           `json:"owner"`
+	BBox         *BBox             `json:"bbox"`
+	Area         *Area             `json:"area"`
+	Difficulties []TrailDifficulty `json:"difficulties"`
+	MinLengthKm  *float64          `json:"min_length_km"`
+	MaxLengthKm  *float64          `json:"max_length_km"`
+	// CreatedBefore is exclusive
+	CreatedAfter  *time.Time `json:"created_after"`
+	CreatedBefore *time.Time `json:"created_before"`
+	// Query and Nearest order trails most relevant or closest first unless another sort is given
+	Query   *TextQuery `json:"q"`
+	Nearest *int       `json:"nearest"`
 }
 
 func (t *Trail) Validate() error {
+	errs := &ValidationError{}
 	if t.Name == nil || *t.Name == "" {
-		return errors.New("trail name is required")
+		errs.Add("name", "trail name is required")
 	}
 	if t.Lat == nil {
-		return errors.New("trail start latitude is required")
-	}
-	if *t.Lat < -90 || *t.Lat > 90 {
-		return errors.New("trail start latitude must be between -90 and 90")
+		errs.Add("lat", "trail start latitude is required")
+	} else if *t.Lat < -90 || *t.Lat > 90 {
+		errs.Add("lat", "trail start latitude must be between -90 and 90")
 	}
 	if t.Lon == nil {
-		return errors.New("trail start longitude is required")
-	}
-	if *t.Lon < -180 || *t.Lon > 180 {
-		return errors.New("trail start longitude must be between -180 and 180")
+		errs.Add("lon", "trail start longitude is required")
+	} else if *t.Lon < -180 || *t.Lon > 180 {
+		errs.Add("lon", "trail start longitude must be between -180 and 180")
 	}
-	if t.Difficulty == nil {
-		return errors.New("trail difficulty is required")
+	// Trails with elevation data along their track can be left for the service to rate
+	if t.Difficulty == nil && !t.Track.HasElevation() {
+		errs.Add("difficulty", "trail difficulty is required")
 	}
-	if *t.Difficulty != TrailDifficultyEasy && *t.Difficulty != TrailDifficultyMedium && *t.Difficulty != TrailDifficultyHard {
-		return errors.New("trail difficulty must be easy, medium, or hard")
+	if t.Difficulty != nil && !IsValidTrailDifficulty(string(*t.Difficulty)) {
+		errs.Add("difficulty", "trail difficulty must be easy, medium, or hard")
 	}
 	if t.LengthKm == nil {
-		return errors.New("trail length is required")
+		errs.Add("length_km", "trail length is required")
+	} else if *t.LengthKm < 0 {
+		errs.Add("length_km", "trail length must be positive")
 	}
-	if *t.LengthKm < 0 {
-		return errors.New("trail length must be positive")
+	if len(t.Track) > 0 {
+		errs.Merge(t.Track.Validate())
 	}
-	return nil
+	return errs.Err()
 }
 
 func (t *CreateTrailRequest) Validate() error {
@@ -73,25 +107,156 @@ func (t *CreateTrailRequest) Validate() error {
 	return trail.Validate()
 }
 
+// TrailPatch is a JSON merge patch of a trail
+type TrailPatch struct {
+	CreateTrailRequest
+	// cleared holds the JSON names of the fields given as null
+	cleared map[string]bool
+}
+
+func (p *TrailPatch) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &p.CreateTrailRequest); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	p.cleared = make(map[string]bool)
+	for name, value := range fields {
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			p.cleared[name] = true
+		}
+	}
+	return nil
+}
+
+func (p *TrailPatch) Clears(field string) bool {
+	return p.cleared[field]
+}
+
+// ApplyPatch derives the start point and length again from a replaced track unless the patch gives them.
+// Cleared fields that can be derived from the track are derived again, the rest are left for Validate to report.
+func (t *CreateTrailRequest) ApplyPatch(patch *TrailPatch) {
+	if patch.Clears("track") {
+		t.Track = nil
+	}
+	if patch.Track != nil {
+		t.Track = patch.Track
+		if len(patch.Track) == 0 {
+			t.Track = nil
+		}
+		if patch.Lat == nil && patch.Lon == nil && len(t.Track) > 0 {
+			t.Lat, t.Lon = nil, nil
+		}
+		if patch.LengthKm == nil && len(t.Track) > 0 {
+			t.LengthKm = nil
+		}
+	}
+	if patch.Clears("name") {
+		t.Name = nil
+	}
+	if patch.Clears("lat") {
+		t.Lat = nil
+	}
+	if patch.Clears("lon") {
+		t.Lon = nil
+	}
+	if patch.Clears("difficulty") {
+		t.Difficulty = nil
+	}
+	if patch.Clears("length_km") {
+		t.LengthKm = nil
+	}
+	if patch.Name != nil {
+		t.Name = patch.Name
+	}
+	if patch.Lat != nil {
+		t.Lat = patch.Lat
+	}
+	if patch.Lon != nil {
+		t.Lon = patch.Lon
+	}
+	if patch.Difficulty != nil {
+		t.Difficulty = patch.Difficulty
+	}
+	if patch.LengthKm != nil {
+		t.LengthKm = patch.LengthKm
+	}
+	t.DeriveFromTrack()
+}
+
+// ApplyPatch takes the difficulty out of the rater's hands when the patch gives or clears it
+func (t *Trail) ApplyPatch(patch *TrailPatch) {
+	t.CreateTrailRequest.ApplyPatch(patch)
+	if patch.Difficulty != nil || patch.Clears("difficulty") {
+		t.DifficultyRated = false
+	}
+}
+
+// Validate names fields after the query parameters they are read from
 func (t *TrailFilter) Validate() error {
-	if t.Lat != nil && t.Lon != nil && t.RadiusKm != nil {
+	errs := &ValidationError{}
+	if t.Lat != nil && t.Lon != nil && (t.RadiusKm != nil || t.Nearest != nil) {
 		if *t.Lat < -90 || *t.Lat > 90 {
-			return errors.New("invalid lat filter outside of bounds -90 to 90")
-		} else if *t.Lon < -180 || *t.Lon > 180 {
-			return errors.New("invalid lon filter outside of bounds -180 to 180")
-		} else if *t.RadiusKm < 0 {
-			return errors.New("invalid radius filter must be positive")
+			errs.Add("lat", "invalid lat filter outside of bounds -90 to 90")
+		}
+		if *t.Lon < -180 || *t.Lon > 180 {
+			errs.Add("lon", "invalid lon filter outside of bounds -180 to 180")
+		}
+		if t.RadiusKm != nil && *t.RadiusKm < 0 {
+			errs.Add("radius-km", "invalid radius filter must be positive")
 		}
 	} else if t.Lat != nil && t.Lon != nil {
-		return errors.New("invalid missing radius filter")
+		errs.Add("radius-km", "invalid missing radius filter")
 	} else if t.Lat != nil {
-		return errors.New("invalid missing lon filter")
+		errs.Add("lon", "invalid missing lon filter")
 	} else if t.Lon != nil {
-		return errors.New("invalid missing lat filter")
-	} else if t.RadiusKm != nil {
-		return errors.New("invalid missing lat and lon filter")
+		errs.Add("lat", "invalid missing lat filter")
+	} else if t.RadiusKm != nil || t.Nearest != nil {
+		errs.Add("lat", "invalid missing lat and lon filter")
 	}
-	return nil
+	if t.Nearest != nil && *t.Nearest < 1 {
+		errs.Add("nearest", "invalid nearest must be positive")
+	}
+	if t.SortBy != nil && *t.SortBy == TrailSortByDistance && t.RadiusKm == nil && t.Nearest == nil {
+		errs.Add("sort", "invalid distance sort requires lat, lon and radius or nearest filter")
+	}
+	if t.Query != nil && len(t.Query.Terms) == 0 {
+		errs.Add("q", "invalid q filter must contain a letter or digit")
+	}
+	if t.SortBy != nil && *t.SortBy == TrailSortByRelevance && t.Query == nil {
+		errs.Add("sort", "invalid relevance sort requires q filter")
+	}
+	for _, difficulty := range t.Difficulties {
+		if !IsValidTrailDifficulty(string(difficulty)) {
+			errs.Addf("difficulty", "invalid difficulty filter %s", difficulty)
+		}
+	}
+	if t.MinLengthKm != nil && *t.MinLengthKm < 0 {
+		errs.Add("min-length-km", "invalid min length filter must be positive")
+	}
+	if t.MaxLengthKm != nil && *t.MaxLengthKm < 0 {
+		errs.Add("max-length-km", "invalid max length filter must be positive")
+	}
+	if t.MinLengthKm != nil && t.MaxLengthKm != nil && *t.MinLengthKm > *t.MaxLengthKm {
+		errs.Add("min-length-km", "invalid min length filter must not be greater than max length")
+	}
+	if t.CreatedAfter != nil && t.CreatedBefore != nil && t.CreatedAfter.After(*t.CreatedBefore) {
+		errs.Add("created-after", "invalid created after filter must not be later than created before")
+	}
+	if t.Limit != nil && *t.Limit < 1 {
+		errs.Add("limit", "invalid limit must be positive")
+	}
+	if t.BBox != nil {
+		if err := t.BBox.validate(); err != nil {
+			errs.Add("bbox", err.Error())
+		}
+	}
+	if t.Cursor != nil && (t.Cursor.SortBy != t.SortField() || t.Cursor.Desc != t.SortDesc) {
+		errs.Add("cursor", "invalid cursor does not match sort")
+	}
+	return errs.Err()
 }
 
 func (t *Trail) MatchesFilter(filter *TrailFilter) bool {
@@ -101,6 +266,9 @@ func (t *Trail) MatchesFilter(filter *TrailFilter) bool {
 	if filter.Name != nil && *filter.Name != "" && *filter.Name != *t.Name {
 		return false
 	}
+	if filter.Query != nil && (t.Name == nil || filter.Query.Score(*t.Name) == 0) {
+		return false
+	}
 	if filter.Lat != nil && filter.Lon != nil && filter.RadiusKm != nil {
 		_, distance := haversine.Distance(
 			haversine.Coord{Lat: *filter.Lat, Lon: *filter.Lon},
@@ -116,9 +284,61 @@ func (t *Trail) MatchesFilter(filter *TrailFilter) bool {
 	if filter.LengthKm != nil && *filter.LengthKm != *t.LengthKm {
 		return false
 	}
+	if len(filter.Difficulties) > 0 && (t.Difficulty == nil || !slices.Contains(filter.Difficulties, *t.Difficulty)) {
+		return false
+	}
+	if filter.MinLengthKm != nil && (t.LengthKm == nil || *t.LengthKm < *filter.MinLengthKm) {
+		return false
+	}
+	if filter.MaxLengthKm != nil && (t.LengthKm == nil || *t.LengthKm > *filter.MaxLengthKm) {
+		return false
+	}
+	if filter.CreatedAfter != nil && (t.CreatedAt == nil || t.CreatedAt.Before(*filter.CreatedAfter)) {
+		return false
+	}
+	if filter.CreatedBefore != nil && (t.CreatedAt == nil || !t.CreatedAt.Before(*filter.CreatedBefore)) {
+		return false
+	}
+	if filter.Owner != nil && *filter.Owner != t.CreatedBy {
+		return false
+	}
+	if filter.BBox != nil && (t.Lat == nil || t.Lon == nil || !filter.BBox.Contains(*t.Lat, *t.Lon)) {
+		return false
+	}
+	if filter.Area != nil && (t.Lat == nil || t.Lon == nil || !filter.Area.Contains(*t.Lat, *t.Lon)) {
+		return false
+	}
 	return true
 }
 
+func (t *Trail) WithDistanceFrom(lat, lon float64) *Trail {
+	located := *t
+	located.DistanceKm, located.BearingDeg = nil, nil
+	if t.Lat == nil || t.Lon == nil {
+		return &located
+	}
+	_, distance := haversine.Distance(
+		haversine.Coord{Lat: lat, Lon: lon},
+		haversine.Coord{Lat: *t.Lat, Lon: *t.Lon},
+	)
+	bearing := InitialBearing(lat, lon, *t.Lat, *t.Lon)
+	located.DistanceKm, located.BearingDeg = &distance, &bearing
+	return &located
+}
+
+func (d TrailDifficulty) Rank() int {
+	switch d {
+	case TrailDifficultyEasy:
+		return 1
+	case TrailDifficultyMedium:
+		return 2
+	case TrailDifficultyHard:
+		return 3
+	default:
+		return 0
+	}
+}
+
 func IsValidTrailDifficulty(s string) bool {
 	switch TrailDifficulty(s) {
 	case TrailDifficultyEasy, TrailDifficultyMedium, TrailDifficultyHard:
@@ -128,6 +348,32 @@ func IsValidTrailDifficulty(s string) bool {
 	}
 }
 
+func (t *Trail) displayName() string {
+	if t.Name == nil {
+		return ""
+	}
+	return *t.Name
+}
+
+func (t *Trail) summary() string {
+	var parts []string
+	if t.Difficulty != nil {
+		parts = append(parts, fmt.Sprintf("Difficulty: %s", *t.Difficulty))
+	}
+	if t.LengthKm != nil {
+		parts = append(parts, fmt.Sprintf("Length: %.1f km", *t.LengthKm))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (t *Trail) FileName(ext string) string {
+	slug := Slugify(t.displayName())
+	if slug == "" {
+		slug = "trail"
+	}
+	return slug + "." + ext
+}
+
 // NewTrailFromRequest creates a Trail from a CreateTrailRequest
 func NewTrailFromRequest(req *CreateTrailRequest) *Trail {
 	return &Trail{
@@ -1,10 +1,12 @@
 package models
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewTrail(t *testing.T) {
@@ -191,6 +193,22 @@ func TestTrail_Validate(t *testing.T) {
 			},
 			expectedError: "trail difficulty is required",
 		},
+		{
+			name: "missing difficulty with elevation to rate it from",
+			trail: &Trail{
+				CreateTrailRequest: CreateTrailRequest{
+					Name:     &validName,
+					Lat:      &validLat,
+					Lon:      &validLon,
+					LengthKm: &validLength,
+					Track: Track{
+						{Lat: validLat, Lon: validLon, Ele: float64Ptr(1200)},
+						{Lat: validLat + 0.01, Lon: validLon, Ele: float64Ptr(1300)},
+					},
+				},
+			},
+			expectedError: "",
+		},
 		{
 			name: "invalid difficulty",
 			trail: &Trail{
@@ -247,6 +265,10 @@ func TestTrailFilter_Validate(t *testing.T) {
 	validLat := 45.5231
 	validLon := -122.6765
 	validRadius := 10.0
+	distanceSort := TrailSortByDistance
+	nameSort := TrailSortByName
+	zeroLimit := 0
+	nearest := 5
 
 	tests := []struct {
 		name          string
@@ -332,6 +354,77 @@ func TestTrailFilter_Validate(t *testing.T) {
 			},
 			expectedError: "invalid radius filter must be positive",
 		},
+		{
+			name: "valid nearest filter",
+			filter: &TrailFilter{
+				CreateTrailRequest: CreateTrailRequest{
+					Lat: &validLat,
+					Lon: &validLon,
+				},
+				Nearest: &nearest,
+				SortBy:  &distanceSort,
+			},
+			expectedError: "",
+		},
+		{
+			name:          "nearest without a point",
+			filter:        &TrailFilter{Nearest: &nearest},
+			expectedError: "invalid missing lat and lon filter",
+		},
+		{
+			name: "zero nearest",
+			filter: &TrailFilter{
+				CreateTrailRequest: CreateTrailRequest{
+					Lat: &validLat,
+					Lon: &validLon,
+				},
+				Nearest: &zeroLimit,
+			},
+			expectedError: "invalid nearest must be positive",
+		},
+		{
+			name:          "distance sort without radius",
+			filter:        &TrailFilter{SortBy: &distanceSort},
+			expectedError: "invalid distance sort requires lat, lon and radius or nearest filter",
+		},
+		{
+			name:          "valid ranges",
+			filter:        &TrailFilter{MinLengthKm: float64Ptr(5), MaxLengthKm: float64Ptr(5), CreatedAfter: timePtr(time.Unix(0, 0)), CreatedBefore: timePtr(time.Unix(0, 0))},
+			expectedError: "",
+		},
+		{
+			name:          "invalid difficulty in list",
+			filter:        &TrailFilter{Difficulties: []TrailDifficulty{TrailDifficultyEasy, "extreme"}},
+			expectedError: "invalid difficulty filter extreme",
+		},
+		{
+			name:          "negative min length",
+			filter:        &TrailFilter{MinLengthKm: float64Ptr(-1)},
+			expectedError: "invalid min length filter must be positive",
+		},
+		{
+			name:          "min length greater than max",
+			filter:        &TrailFilter{MinLengthKm: float64Ptr(10), MaxLengthKm: float64Ptr(5)},
+			expectedError: "invalid min length filter must not be greater than max length",
+		},
+		{
+			name:          "created after later than created before",
+			filter:        &TrailFilter{CreatedAfter: timePtr(time.Unix(10, 0)), CreatedBefore: timePtr(time.Unix(0, 0))},
+			expectedError: "invalid created after filter must not be later than created before",
+		},
+		{
+			name:          "zero limit",
+			filter:        &TrailFilter{Limit: &zeroLimit},
+			expectedError: "invalid limit must be positive",
+		},
+		{
+			name: "cursor for another sort",
+			filter: &TrailFilter{
+				SortBy: &nameSort,
+				Cursor: &TrailCursor{SortBy: TrailSortByLengthKm, UID: "abc"},
+			},
+			expectedError: "invalid cursor does not match sort",
+		},
 	}
 
 	for _, tt := range tests {
@@ -348,6 +441,9 @@ func TestTrailFilter_Validate(t *testing.T) {
 
 func TestTrail_MatchesFilter(t *testing.T) {
 	trail := NewTrail("Test Trail", 45.5231, -122.6765, TrailDifficultyMedium, 10.5)
+	trail.CreatedBy = "ranger"
+	createdAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	trail.CreatedAt = &createdAt
 
 	mediumDifficulty := TrailDifficultyMedium
 	hardDifficulty := TrailDifficultyHard
@@ -398,6 +494,49 @@ func TestTrail_MatchesFilter(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			name:     "one of several difficulties",
+			filter:   &TrailFilter{Difficulties: []TrailDifficulty{TrailDifficultyEasy, TrailDifficultyMedium}},
+			expected: true,
+		},
+		{
+			name:     "none of several difficulties",
+			filter:   &TrailFilter{Difficulties: []TrailDifficulty{TrailDifficultyEasy, TrailDifficultyHard}},
+			expected: false,
+		},
+		{
+			name:     "within length range",
+			filter:   &TrailFilter{MinLengthKm: float64Ptr(10.5), MaxLengthKm: float64Ptr(10.5)},
+			expected: true,
+		},
+		{
+			name:     "shorter than min length",
+			filter:   &TrailFilter{MinLengthKm: float64Ptr(11)},
+			expected: false,
+		},
+		{
+			name:     "longer than max length",
+			filter:   &TrailFilter{MaxLengthKm: float64Ptr(10)},
+			expected: false,
+		},
+		{
+			name: "created within range",
+			filter: &TrailFilter{
+				CreatedAfter:  &createdAt,
+				CreatedBefore: timePtr(createdAt.Add(time.Second)),
+			},
+			expected: true,
+		},
+		{
+			name:     "created before range",
+			filter:   &TrailFilter{CreatedAfter: timePtr(createdAt.Add(time.Second))},
+			expected: false,
+		},
+		{
+			name:     "created at end of range",
+			filter:   &TrailFilter{CreatedBefore: &createdAt},
+			expected: false,
+		},
 		{
 			name: "within radius",
 			filter: &TrailFilter{
@@ -420,6 +559,16 @@ func TestTrail_MatchesFilter(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			name:     "matching owner",
+			filter:   &TrailFilter{Owner: stringPtr("ranger")},
+			expected: true,
+		},
+		{
+			name:     "non-matching owner",
+			filter:   &TrailFilter{Owner: stringPtr("hiker")},
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -430,6 +579,126 @@ func TestTrail_MatchesFilter(t *testing.T) {
 	}
 }
 
+func TestCreateTrailRequest_ApplyPatch(t *testing.T) {
+	trail := NewTrail("Test Trail", 45.5231, -122.6765, TrailDifficultyMedium, 10.5)
+
+	patch := &TrailPatch{CreateTrailRequest: CreateTrailRequest{
+		Name:     stringPtr("Renamed Trail"),
+		LengthKm: float64Ptr(12.0),
+	}}
+	trail.ApplyPatch(patch)
+
+	assert.Equal(t, "Renamed Trail", *trail.Name)
+	assert.Equal(t, 12.0, *trail.LengthKm)
+	assert.Equal(t, 45.5231, *trail.Lat)
+	assert.Equal(t, -122.6765, *trail.Lon)
+	assert.Equal(t, TrailDifficultyMedium, *trail.Difficulty)
+}
+
+func TestTrail_ApplyPatchDifficultyRated(t *testing.T) {
+	hard := TrailDifficultyHard
+	tests := []struct {
+		name          string
+		patch         string
+		expectedRated bool
+	}{
+		{name: "other fields leave the difficulty rated", patch: `{"name":"Renamed Trail"}`, expectedRated: true},
+		{name: "given difficulty", patch: `{"difficulty":"hard"}`, expectedRated: false},
+		{name: "cleared difficulty", patch: `{"difficulty":null}`, expectedRated: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trail := NewTrail("Test Trail", 45.5231, -122.6765, hard, 10.5)
+			trail.DifficultyRated = true
+
+			var patch TrailPatch
+			require.NoError(t, json.Unmarshal([]byte(tt.patch), &patch))
+			trail.ApplyPatch(&patch)
+			assert.Equal(t, tt.expectedRated, trail.DifficultyRated)
+		})
+	}
+}
+
+func TestCreateTrailRequest_ApplyPatchNulls(t *testing.T) {
+	track := Track{{Lat: 44.8472, Lon: -109.6278}, {Lat: 44.85, Lon: -109.62}}
+	newTrail := func() *Trail {
+		trail := NewTrailFromTrack("Lamar River Trail", track, nil)
+		hard := TrailDifficultyHard
+		trail.Difficulty = &hard
+		trail.Lat, trail.Lon = float64Ptr(44.9), float64Ptr(-109.7)
+		trail.LengthKm = float64Ptr(53)
+		return trail
+	}
+
+	t.Run("missing fields are kept", func(t *testing.T) {
+		var patch TrailPatch
+		require.NoError(t, json.Unmarshal([]byte(`{"name":"Lamar River"}`), &patch))
+		trail := newTrail()
+		trail.ApplyPatch(&patch)
+		assert.Equal(t, "Lamar River", *trail.Name)
+		assert.Equal(t, TrailDifficultyHard, *trail.Difficulty)
+		assert.Equal(t, 53.0, *trail.LengthKm)
+	})
+
+	t.Run("null fields are cleared", func(t *testing.T) {
+		var patch TrailPatch
+		require.NoError(t, json.Unmarshal([]byte(`{"difficulty":null,"name":null}`), &patch))
+		trail := newTrail()
+		trail.ApplyPatch(&patch)
+		assert.Nil(t, trail.Difficulty)
+		assert.Nil(t, trail.Name)
+		assert.Error(t, trail.Validate())
+	})
+
+	t.Run("null derived fields are derived from the track again", func(t *testing.T) {
+		var patch TrailPatch
+		require.NoError(t, json.Unmarshal([]byte(`{"lat":null,"lon":null,"length_km":null}`), &patch))
+		trail := newTrail()
+		trail.ApplyPatch(&patch)
+		assert.Equal(t, 44.8472, *trail.Lat)
+		assert.Equal(t, -109.6278, *trail.Lon)
+		assert.InDelta(t, TrackLengthKm(track), *trail.LengthKm, 1e-9)
+	})
+
+	t.Run("null track is removed", func(t *testing.T) {
+		var patch TrailPatch
+		require.NoError(t, json.Unmarshal([]byte(`{"track":null}`), &patch))
+		trail := newTrail()
+		trail.ApplyPatch(&patch)
+		assert.Nil(t, trail.Track)
+		assert.Equal(t, 44.9, *trail.Lat)
+	})
+}
+
+func TestTrail_WithDistanceFrom(t *testing.T) {
+	trail := NewTrail("Angel's Rest", 45.5, -122, TrailDifficultyMedium, 7.8)
+
+	north := trail.WithDistanceFrom(45, -122)
+	require.NotNil(t, north.DistanceKm)
+	assert.InDelta(t, 55.6, *north.DistanceKm, 0.1)
+	assert.InDelta(t, 0, *north.BearingDeg, 0.001)
+	assert.Nil(t, trail.DistanceKm, "the stored trail is left untouched")
+
+	tests := []struct {
+		name            string
+		fromLon         float64
+		toLat           float64
+		toLon           float64
+		expectedBearing float64
+	}{
+		{name: "due east", toLon: 1, expectedBearing: 90},
+		{name: "due south", toLat: -1, expectedBearing: 180},
+		{name: "due west", toLon: -1, expectedBearing: 270},
+		{name: "east across the antimeridian", fromLon: 179, toLon: -179, expectedBearing: 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expectedBearing, InitialBearing(0, tt.fromLon, tt.toLat, tt.toLon), 0.001)
+		})
+	}
+}
+
 func TestIsValidTrailDifficulty(t *testing.T) {
 	tests := []struct {
 		difficulty string
@@ -457,6 +726,10 @@ func stringPtr(s string) *string {
 	return &s
 }
 
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
 func float64Ptr(f float64) *float64 {
 	return &f
 }
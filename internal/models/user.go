@@ -0,0 +1,87 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MinPasswordLength = 8
+	// MaxPasswordLength is the most bcrypt will hash, anything longer is silently truncated
+	MaxPasswordLength = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{2,63}$`)
+
+type RegisterUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type User struct {
+	UID          uuid.UUID  `json:"user_id"`
+	Username     string     `json:"username"`
+	Role         Role       `json:"role"`
+	PasswordHash string     `json:"-"`
+	CreatedAt    *time.Time `json:"created_at"`
+	// FailedLogins counts consecutive bad passwords since the last successful login or lockout
+	FailedLogins    int        `json:"-"`
+	LockedUntil     *time.Time `json:"-"`
+	ExternalSubject string     `json:"-"`
+}
+
+// ExternalSubject includes the issuer as subjects are only unique within it
+func ExternalSubject(issuer string, subject string) string {
+	return issuer + " " + subject
+}
+
+// NormalizeUsername lower cases and trims the username so lookups are case insensitive
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func ValidUsername(username string) bool {
+	return usernamePattern.MatchString(NormalizeUsername(username))
+}
+
+func (r *RegisterUserRequest) Validate() error {
+	errs := &ValidationError{}
+	if !ValidUsername(r.Username) {
+		errs.Add("username", "username must be 3 to 64 letters, digits, '.', '_' or '-'")
+	}
+	if len(r.Password) < MinPasswordLength {
+		errs.Add("password", "password must be at least 8 characters")
+	}
+	if len(r.Password) > MaxPasswordLength {
+		errs.Add("password", "password must be at most 72 bytes")
+	}
+	return errs.Err()
+}
+
+// NewUser expects the request to be validated already
+func NewUser(req *RegisterUserRequest, role Role) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &User{
+		UID:          uuid.New(),
+		Username:     NormalizeUsername(req.Username),
+		Role:         role,
+		PasswordHash: string(hash),
+		CreatedAt:    &now,
+	}, nil
+}
+
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
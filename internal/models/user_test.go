@@ -0,0 +1,74 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterUserRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           RegisterUserRequest
+		expectedError string
+	}{
+		{
+			name: "valid request",
+			req:  RegisterUserRequest{Username: "Ranger.Rick", Password: "correct horse"},
+		},
+		{
+			name:          "username too short",
+			req:           RegisterUserRequest{Username: "ab", Password: "correct horse"},
+			expectedError: "username must be 3 to 64 letters, digits, '.', '_' or '-'",
+		},
+		{
+			name:          "username with spaces",
+			req:           RegisterUserRequest{Username: "ranger rick", Password: "correct horse"},
+			expectedError: "username must be 3 to 64 letters, digits, '.', '_' or '-'",
+		},
+		{
+			name:          "password too short",
+			req:           RegisterUserRequest{Username: "ranger", Password: "short"},
+			expectedError: "password must be at least 8 characters",
+		},
+		{
+			name:          "password too long",
+			req:           RegisterUserRequest{Username: "ranger", Password: strings.Repeat("a", 73)},
+			expectedError: "password must be at most 72 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestNewUser(t *testing.T) {
+	user, err := NewUser(&RegisterUserRequest{Username: " Ranger ", Password: "correct horse"}, RoleContributor)
+	require.NoError(t, err)
+
+	assert.Equal(t, "ranger", user.Username)
+	assert.NotEqual(t, "correct horse", user.PasswordHash)
+	assert.True(t, user.CheckPassword("correct horse"))
+	assert.False(t, user.CheckPassword("wrong horse"))
+}
+
+func TestUser_IsLocked(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Minute)
+	past := now.Add(-time.Minute)
+
+	assert.False(t, (&User{}).IsLocked(now))
+	assert.True(t, (&User{LockedUntil: &future}).IsLocked(now))
+	assert.False(t, (&User{LockedUntil: &past}).IsLocked(now))
+}
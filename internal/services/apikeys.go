@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyBytes = 32
+	// apiKeyPrefix marks API keys so they are easy to recognise, e.g. by secret scanners
+	apiKeyPrefix     = "tds_"
+	apiKeyShownChars = 8
+)
+
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*models.Claims, error)
+}
+
+type APIKeyService interface {
+	APIKeyAuthenticator
+	CreateAPIKey(ctx context.Context, username string, req *models.CreateAPIKeyRequest) (*models.IssuedAPIKey, error)
+	ListAPIKeys(ctx context.Context, username string) ([]*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, username string, id string) error
+}
+
+type apiKeyService struct {
+	keys  storage.APIKeyStorage
+	users storage.UserStorage
+}
+
+func NewAPIKeyService(keys storage.APIKeyStorage, users storage.UserStorage) *apiKeyService {
+	return &apiKeyService{keys: keys, users: users}
+}
+
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, username string, req *models.CreateAPIKeyRequest) (*models.IssuedAPIKey, error) {
+	user, err := s.users.FindByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range req.Scopes {
+		if !user.Role.AtLeast(scope.Role()) {
+			return nil, ErrScopesNotAllowed
+		}
+	}
+
+	raw := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	key := &models.APIKey{
+		ID:        uuid.New(),
+		Name:      strings.TrimSpace(req.Name),
+		Username:  user.Username,
+		Prefix:    secret[:len(apiKeyPrefix)+apiKeyShownChars],
+		Hash:      hashSecret(secret),
+		Scopes:    req.Scopes,
+		CreatedAt: &now,
+	}
+	if err := s.keys.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	return &models.IssuedAPIKey{APIKey: key, Key: secret}, nil
+}
+
+func (s *apiKeyService) ListAPIKeys(ctx context.Context, username string) ([]*models.APIKey, error) {
+	return s.keys.ListByUsername(ctx, username)
+}
+
+// RevokeAPIKey reports keys belonging to others as not found
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, username string, id string) error {
+	key, err := s.keys.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.Username != username {
+		return storage.ErrAPIKeyNotFound
+	}
+	return s.keys.Revoke(ctx, id, time.Now())
+}
+
+func (s *apiKeyService) AuthenticateAPIKey(ctx context.Context, secret string) (*models.Claims, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.keys.FindByHash(ctx, hashSecret(secret))
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if key.IsRevoked() {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// The owner's role is read on every request so demoting or removing them takes effect at once
+	user, err := s.users.FindByUsername(ctx, key.Username)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	return &models.Claims{
+		Username: user.Username,
+		Role:     key.RoleFor(user.Role),
+		APIKeyID: key.ID.String(),
+	}, nil
+}
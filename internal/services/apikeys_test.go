@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAPIKeyService(t *testing.T) (*apiKeyService, *loginService) {
+	logins := newTestLoginService(t, 5)
+	return NewAPIKeyService(storage.NewAPIKeyStorage(), logins.users), logins
+}
+
+func TestCreateAndAuthenticateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestAPIKeyService(t)
+
+	issued, err := service.CreateAPIKey(ctx, "ranger", &models.CreateAPIKeyRequest{Name: " ingest ", Scopes: []models.APIKeyScope{models.ScopeWriteTrails}})
+	require.NoError(t, err)
+	assert.Equal(t, "ingest", issued.Name)
+	assert.True(t, strings.HasPrefix(issued.Key, issued.Prefix))
+	assert.NotContains(t, issued.Hash, issued.Key)
+
+	claims, err := service.AuthenticateAPIKey(ctx, issued.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "ranger", claims.Username)
+	assert.Equal(t, models.RoleContributor, claims.Role)
+	assert.Equal(t, issued.ID.String(), claims.APIKeyID)
+
+	_, err = service.AuthenticateAPIKey(ctx, issued.Key+"x")
+	assert.EqualError(t, err, "invalid api key")
+	_, err = service.AuthenticateAPIKey(ctx, "not-a-key")
+	assert.EqualError(t, err, "invalid api key")
+}
+
+func TestCreateAPIKey_ScopesLimitedByRole(t *testing.T) {
+	ctx := context.Background()
+	service, logins := newTestAPIKeyService(t)
+
+	issued, err := service.CreateAPIKey(ctx, "ranger", &models.CreateAPIKeyRequest{Name: "ingest", Scopes: []models.APIKeyScope{models.ScopeWriteTrails}})
+	require.NoError(t, err)
+
+	// Demoting the owner limits keys already issued
+	_, err = logins.SetRole(ctx, "ranger", models.RoleViewer)
+	require.NoError(t, err)
+	claims, err := service.AuthenticateAPIKey(ctx, issued.Key)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleViewer, claims.Role)
+
+	// and stops new keys being given scopes the owner no longer has
+	_, err = service.CreateAPIKey(ctx, "ranger", &models.CreateAPIKeyRequest{Name: "ingest", Scopes: []models.APIKeyScope{models.ScopeWriteTrails}})
+	assert.EqualError(t, err, "role does not allow the requested scopes")
+	_, err = service.CreateAPIKey(ctx, "ranger", &models.CreateAPIKeyRequest{Name: "reader", Scopes: []models.APIKeyScope{models.ScopeReadTrails}})
+	assert.NoError(t, err)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	ctx := context.Background()
+	service, logins := newTestAPIKeyService(t)
+	_, err := logins.Register(ctx, &models.RegisterUserRequest{Username: "hiker", Password: "correct horse"})
+	require.NoError(t, err)
+
+	issued, err := service.CreateAPIKey(ctx, "ranger", &models.CreateAPIKeyRequest{Name: "ingest", Scopes: []models.APIKeyScope{models.ScopeReadTrails}})
+	require.NoError(t, err)
+
+	// Other users cannot see or revoke the key
+	assert.EqualError(t, service.RevokeAPIKey(ctx, "hiker", issued.ID.String()), "api key not found")
+	keys, err := service.ListAPIKeys(ctx, "hiker")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, service.RevokeAPIKey(ctx, "ranger", issued.ID.String()))
+	_, err = service.AuthenticateAPIKey(ctx, issued.Key)
+	assert.EqualError(t, err, "invalid api key")
+
+	keys, err = service.ListAPIKeys(ctx, "ranger")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.True(t, keys[0].IsRevoked())
+}
@@ -0,0 +1,16 @@
+package services
+
+import "github.com/dnakolan/trail-data-service/internal/models"
+
+// Errors returned by the services on top of the storage errors, each matches one of the kinds in models with errors.Is
+var (
+	ErrTrailExists             = models.Errorf(models.ErrDuplicate, "trail already exists")
+	ErrInvalidCredentials      = models.Errorf(models.ErrUnauthenticated, "invalid username or password")
+	ErrUsernameTaken           = models.Errorf(models.ErrDuplicate, "username belongs to another account")
+	ErrInvalidExternalUsername = models.Errorf(models.ErrUnauthenticated, "username from the provider must be 3 to 64 letters, digits, '.', '_' or '-'")
+	ErrInvalidRefreshToken     = models.Errorf(models.ErrUnauthenticated, "invalid refresh token")
+	ErrInvalidAPIKey           = models.Errorf(models.ErrUnauthenticated, "invalid api key")
+	ErrScopesNotAllowed        = models.Errorf(models.ErrForbidden, "role does not allow the requested scopes")
+	ErrInvalidLoginState       = models.Errorf(models.ErrValidation, "invalid or expired login state")
+	ErrTooManyLogins           = models.Errorf(models.ErrOverloaded, "too many logins in progress, try again later")
+)
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwtKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeySet verifies against every configured key so tokens signed before a rotation stay valid until they expire
+type KeySet struct {
+	secret  []byte
+	signing *jwtKey
+	keys    map[string]*jwtKey
+	ids     []string
+}
+
+// NewKeySet signs and verifies with the HS256 secret when no keys are configured
+func NewKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*jwtKey)}
+	if cfg.Secret != "" {
+		set.secret = []byte(cfg.Secret)
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		key, err := loadKey(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", keyCfg.ID, err)
+		}
+		set.keys[key.id] = key
+		set.ids = append(set.ids, key.id)
+		if key.id == cfg.SigningKeyID {
+			set.signing = key
+		}
+	}
+
+	if set.signing == nil && set.secret == nil {
+		return nil, errors.New("no jwt signing key or secret configured")
+	}
+	return set, nil
+}
+
+func loadKey(cfg config.JWTKeyConfig) (*jwtKey, error) {
+	key := &jwtKey{id: cfg.ID}
+	if cfg.PrivateKeyFile != "" {
+		block, err := readPEM(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.private, err = parsePrivateKey(block); err != nil {
+			return nil, err
+		}
+		key.public = key.private.Public()
+	} else {
+		block, err := readPEM(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.public, err = parsePublicKey(block); err != nil {
+			return nil, err
+		}
+	}
+
+	method, err := signingMethodFor(key.public)
+	if err != nil {
+		return nil, err
+	}
+	key.method = method
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q for a private key", block.Type)
+	}
+}
+
+func parsePublicKey(block *pem.Block) (crypto.PublicKey, error) {
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q for a public key", block.Type)
+	}
+}
+
+func signingMethodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported elliptic curve %s", key.Curve.Params().Name)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only RSA and ECDSA keys can sign tokens", public)
+	}
+}
+
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if s.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	}
+	token := jwt.NewWithClaims(s.signing.method, claims)
+	token.Header["kid"] = s.signing.id
+	return token.SignedString(s.signing.private)
+}
+
+// Keyfunc checks tokens without a kid header against the secret, when one is configured
+func (s *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, hasKid := token.Header["kid"].(string)
+	if !hasKid {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || s.secret == nil {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return s.secret, nil
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	// Only accept the algorithm the key was made for, never one the token header picks
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Header["alg"], kid)
+	}
+	return key.public, nil
+}
+
+func (s *KeySet) JWKS() models.JWKS {
+	jwks := models.JWKS{Keys: make([]models.JWK, 0, len(s.ids))}
+	for _, id := range s.ids {
+		key := s.keys[id]
+		jwk := models.JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			point, err := public.ECDH()
+			if err != nil {
+				continue
+			}
+			// The uncompressed point is 0x04 followed by X and Y at the curve's byte size
+			xy := point.Bytes()[1:]
+			jwk.Kty = "EC"
+			jwk.Crv = public.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(xy[:len(xy)/2])
+			jwk.Y = base64.RawURLEncoding.EncodeToString(xy[len(xy)/2:])
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestKeyPair writes the key as a PKCS8 private key and a PKIX public key, returning both paths
+func writeTestKeyPair(t *testing.T, name string, key any) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	privatePath := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600))
+
+	var public any
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		public = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		public = &k.PublicKey
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+	publicPath := filepath.Join(dir, name+".pub.pem")
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644))
+
+	return privatePath, publicPath
+}
+
+func testClaims() jwt.Claims {
+	return jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		key         any
+		expectedAlg string
+	}{
+		{name: "rsa", key: rsaKey, expectedAlg: "RS256"},
+		{name: "ecdsa", key: ecKey, expectedAlg: "ES256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privatePath, _ := writeTestKeyPair(t, tt.name, tt.key)
+			keys, err := NewKeySet(config.JWTConfig{
+				Keys:         []config.JWTKeyConfig{{ID: "key-1", PrivateKeyFile: privatePath}},
+				SigningKeyID: "key-1",
+			})
+			require.NoError(t, err)
+
+			signed, err := keys.Sign(testClaims())
+			require.NoError(t, err)
+
+			token, err := jwt.Parse(signed, keys.Keyfunc)
+			require.NoError(t, err)
+			assert.True(t, token.Valid)
+			assert.Equal(t, "key-1", token.Header["kid"])
+			assert.Equal(t, tt.expectedAlg, token.Header["alg"])
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	oldPrivate, oldPublic := writeTestKeyPair(t, "old", oldKey)
+	newPrivate, _ := writeTestKeyPair(t, "new", newKey)
+
+	before, err := NewKeySet(config.JWTConfig{
+		Keys:         []config.JWTKeyConfig{{ID: "old", PrivateKeyFile: oldPrivate}},
+		SigningKeyID: "old",
+	})
+	require.NoError(t, err)
+	oldToken, err := before.Sign(testClaims())
+	require.NoError(t, err)
+
+	// After rotating only the public half of the old key is kept
+	after, err := NewKeySet(config.JWTConfig{
+		Keys: []config.JWTKeyConfig{
+			{ID: "new", PrivateKeyFile: newPrivate},
+			{ID: "old", PublicKeyFile: oldPublic},
+		},
+		SigningKeyID: "new",
+	})
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(oldToken, after.Keyfunc)
+	assert.NoError(t, err, "tokens signed with the old key keep verifying")
+
+	newToken, err := after.Sign(testClaims())
+	require.NoError(t, err)
+	parsed, err := jwt.Parse(newToken, after.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "new", parsed.Header["kid"])
+
+	_, err = jwt.Parse(newToken, before.Keyfunc)
+	assert.Error(t, err, "tokens signed with a key the verifier does not know are rejected")
+}
+
+func TestKeySet_RejectsForgedTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privatePath, publicPath := writeTestKeyPair(t, "rsa", rsaKey)
+
+	keys, err := NewKeySet(config.JWTConfig{
+		Keys:         []config.JWTKeyConfig{{ID: "key-1", PrivateKeyFile: privatePath}},
+		SigningKeyID: "key-1",
+	})
+	require.NoError(t, err)
+	publicPEM, err := os.ReadFile(publicPath)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{
+			name: "hmac signed with the public key",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+				token.Header["kid"] = "key-1"
+				signed, _ := token.SignedString(publicPEM)
+				return signed
+			},
+		},
+		{
+			name: "hmac without a kid when no secret is configured",
+			token: func() string {
+				signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte(""))
+				return signed
+			},
+		},
+		{
+			name: "unknown kid",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims())
+				token.Header["kid"] = "key-2"
+				signed, _ := token.SignedString(rsaKey)
+				return signed
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jwt.Parse(tt.token(), keys.Keyfunc)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKeySet_SecretOnly(t *testing.T) {
+	keys, err := NewKeySet(config.JWTConfig{Secret: "test-secret"})
+	require.NoError(t, err)
+
+	signed, err := keys.Sign(testClaims())
+	require.NoError(t, err)
+	token, err := jwt.Parse(signed, keys.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "HS256", token.Header["alg"])
+	assert.NotContains(t, token.Header, "kid")
+	assert.Empty(t, keys.JWKS().Keys, "the secret is never published")
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaPrivate, _ := writeTestKeyPair(t, "rsa", rsaKey)
+	_, ecPublic := writeTestKeyPair(t, "ec", ecKey)
+
+	keys, err := NewKeySet(config.JWTConfig{
+		Secret: "test-secret",
+		Keys: []config.JWTKeyConfig{
+			{ID: "rsa-1", PrivateKeyFile: rsaPrivate},
+			{ID: "ec-1", PublicKeyFile: ecPublic},
+		},
+		SigningKeyID: "rsa-1",
+	})
+	require.NoError(t, err)
+
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 2)
+
+	rsaJWK := jwks.Keys[0]
+	assert.Equal(t, "RSA", rsaJWK.Kty)
+	assert.Equal(t, "rsa-1", rsaJWK.Kid)
+	assert.Equal(t, "RS256", rsaJWK.Alg)
+	assert.Equal(t, "sig", rsaJWK.Use)
+	assert.Equal(t, "AQAB", rsaJWK.E)
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	require.NoError(t, err)
+	assert.Equal(t, 0, new(big.Int).SetBytes(n).Cmp(rsaKey.N))
+
+	ecJWK := jwks.Keys[1]
+	assert.Equal(t, "EC", ecJWK.Kty)
+	assert.Equal(t, "ES256", ecJWK.Alg)
+	assert.Equal(t, "P-256", ecJWK.Crv)
+	x, err := base64.RawURLEncoding.DecodeString(ecJWK.X)
+	require.NoError(t, err)
+	y, err := base64.RawURLEncoding.DecodeString(ecJWK.Y)
+	require.NoError(t, err)
+	assert.Len(t, x, 32)
+	assert.Equal(t, ecKey.PublicKey.X.FillBytes(make([]byte, 32)), x)
+	assert.Equal(t, ecKey.PublicKey.Y.FillBytes(make([]byte, 32)), y)
+}
+
+func TestNewKeySet_Errors(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a key"), 0o600))
+
+	tests := []struct {
+		name string
+		cfg  config.JWTConfig
+	}{
+		{
+			name: "missing file",
+			cfg: config.JWTConfig{
+				Keys:         []config.JWTKeyConfig{{ID: "key-1", PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")}},
+				SigningKeyID: "key-1",
+			},
+		},
+		{
+			name: "not a pem file",
+			cfg: config.JWTConfig{
+				Keys:         []config.JWTKeyConfig{{ID: "key-1", PrivateKeyFile: notPEM}},
+				SigningKeyID: "key-1",
+			},
+		},
+		{
+			name: "nothing to sign with",
+			cfg:  config.JWTConfig{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeySet(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -3,58 +3,222 @@ package services
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/dnakolan/trail-data-service/internal/config"
 	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/storage"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 type LoginService interface {
-	GetSecretKey() []byte
-	generateToken(username string) (string, error)
-	Login(ctx context.Context, username string, password string) (string, error)
+	RevocationChecker
+	generateToken(user *models.User) (string, error)
+	Login(ctx context.Context, username string, password string) (*models.TokenPair, error)
+	Register(ctx context.Context, req *models.RegisterUserRequest) (*models.User, error)
+	SetRole(ctx context.Context, username string, role models.Role) (*models.User, error)
+	EnsureAdmin(ctx context.Context, username string, password string) error
+	LoginExternal(ctx context.Context, subject string, username string, role models.Role) (*models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+	Logout(ctx context.Context, claims *models.Claims, refreshToken string) error
 }
 
-type loginService struct{}
-
-func NewLoginService() *loginService {
-	return &loginService{}
+type loginService struct {
+	users           storage.UserStorage
+	tokens          storage.TokenStorage
+	jwt             config.JWTConfig
+	keys            *KeySet
+	defaultRole     models.Role
+	maxFailedLogins int
+	lockoutDuration time.Duration
+	// attempts serialises updates to the failed login counts so concurrent wrong passwords are all counted
+	attempts sync.Mutex
 }
 
-func (s *loginService) GetSecretKey() []byte {
-	return []byte(config.SECRET_KEY)
+func NewLoginService(users storage.UserStorage, tokens storage.TokenStorage, auth config.AuthConfig, jwt config.JWTConfig, keys *KeySet) *loginService {
+	if auth.DefaultRole == "" {
+		auth.DefaultRole = config.DEFAULT_ROLE
+	}
+	return &loginService{
+		users:           users,
+		tokens:          tokens,
+		jwt:             jwt,
+		keys:            keys,
+		defaultRole:     models.Role(auth.DefaultRole),
+		maxFailedLogins: auth.MaxFailedLogins,
+		lockoutDuration: auth.LockoutDuration,
+	}
 }
 
-func (s *loginService) generateToken(username string) (string, error) {
-	expirationTime := time.Now().Add(config.TOKEN_EXPIRATION_TIME)
+// unknownUser stands in for users that do not exist so a password is still hashed and
+// response times do not reveal which usernames are registered
+var unknownUser = sync.OnceValue(func() *models.User {
+	user, err := models.NewUser(&models.RegisterUserRequest{Username: "unknown", Password: "unknown-password"}, models.RoleViewer)
+	if err != nil {
+		panic(err)
+	}
+	return user
+})
+
+func (s *loginService) generateToken(user *models.User) (string, error) {
+	expirationTime := time.Now().Add(s.jwt.Expiration)
 	claims := &models.Claims{
-		Username: username,
+		Username: user.Username,
+		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    config.TOKEN_ISSUER,
+			Issuer:    s.jwt.Issuer,
 			Subject:   config.TOKEN_SUBJECT,
 			ID:        uuid.New().String(),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.GetSecretKey())
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
 
-func (s *loginService) Login(ctx context.Context, username string, password string) (string, error) {
+func (s *loginService) Login(ctx context.Context, username string, password string) (*models.TokenPair, error) {
 	if username == "" || password == "" {
-		return "", errors.New("username and password are required")
+		return nil, models.NewValidationError("", "username and password are required")
 	}
-	token, err := s.generateToken(username)
+
+	user, err := s.users.FindByUsername(ctx, username)
 	if err != nil {
-		return "", err
+		if !errors.Is(err, storage.ErrUserNotFound) {
+			return nil, err
+		}
+		unknownUser().CheckPassword(password)
+		return nil, ErrInvalidCredentials
+	}
+
+	// A locked account answers like a wrong password and only after the same hash check, so neither the
+	// status nor the timing shows that the username exists
+	now := time.Now()
+	if user.IsLocked(now) {
+		user.CheckPassword(password)
+		return nil, ErrInvalidCredentials
+	}
+
+	if !user.CheckPassword(password) {
+		if err := s.recordLoginAttempt(ctx, user.Username, false, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
-	return token, nil
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := s.recordLoginAttempt(ctx, user.Username, true, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.issueTokens(ctx, user, uuid.New().String())
+}
+
+// recordLoginAttempt reads the user again under the lock so concurrent attempts see each other's updates
+func (s *loginService) recordLoginAttempt(ctx context.Context, username string, succeeded bool, now time.Time) error {
+	s.attempts.Lock()
+	defer s.attempts.Unlock()
+
+	user, err := s.users.FindByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if succeeded {
+		user.FailedLogins = 0
+		user.LockedUntil = nil
+		return s.users.Save(ctx, user)
+	}
+
+	user.FailedLogins++
+	if s.maxFailedLogins > 0 && user.FailedLogins >= s.maxFailedLogins {
+		lockedUntil := now.Add(s.lockoutDuration)
+		user.LockedUntil = &lockedUntil
+		user.FailedLogins = 0
+	}
+	return s.users.Save(ctx, user)
+}
+
+func (s *loginService) Register(ctx context.Context, req *models.RegisterUserRequest) (*models.User, error) {
+	user, err := models.NewUser(req, s.defaultRole)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetRole changes the role of a user. Tokens already issued keep the old role until they are refreshed.
+func (s *loginService) SetRole(ctx context.Context, username string, role models.Role) (*models.User, error) {
+	user, err := s.users.FindByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	user.Role = role
+	if err := s.users.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// EnsureAdmin leaves the password of an existing account untouched
+func (s *loginService) EnsureAdmin(ctx context.Context, username string, password string) error {
+	req := &models.RegisterUserRequest{Username: username, Password: password}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	user, err := models.NewUser(req, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	err = s.users.Create(ctx, user)
+	if errors.Is(err, storage.ErrUserExists) {
+		_, err = s.SetRole(ctx, username, models.RoleAdmin)
+	}
+	return err
+}
+
+// LoginExternal finds accounts by external subject, the username is only taken when the account is created.
+// The role follows the provider's when it names one.
+func (s *loginService) LoginExternal(ctx context.Context, subject string, username string, role models.Role) (*models.TokenPair, error) {
+	if subject == "" {
+		return nil, models.NewValidationError("subject", "subject is required")
+	}
+
+	user, err := s.users.FindByExternalSubject(ctx, subject)
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound):
+		if !models.ValidUsername(username) {
+			return nil, ErrInvalidExternalUsername
+		}
+		if role == "" {
+			role = s.defaultRole
+		}
+		now := time.Now()
+		user = &models.User{UID: uuid.New(), Username: models.NormalizeUsername(username), Role: role, CreatedAt: &now, ExternalSubject: subject}
+		err := s.users.Create(ctx, user)
+		if errors.Is(err, storage.ErrUserExists) {
+			return nil, ErrUsernameTaken
+		}
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case role != "" && user.Role != role:
+		user.Role = role
+		if err := s.users.Save(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.issueTokens(ctx, user, uuid.New().String())
 }
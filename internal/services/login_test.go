@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testJWTConfig = config.JWTConfig{
+	Secret:            "test-secret",
+	Expiration:        time.Hour,
+	RefreshExpiration: 24 * time.Hour,
+	Issuer:            "trail-data-service",
+}
+
+func testKeySet(t *testing.T) *KeySet {
+	keys, err := NewKeySet(testJWTConfig)
+	require.NoError(t, err)
+	return keys
+}
+
+func newTestLoginService(t *testing.T, maxFailedLogins int) *loginService {
+	service := NewLoginService(storage.NewUserStorage(), storage.NewTokenStorage(), config.AuthConfig{MaxFailedLogins: maxFailedLogins, LockoutDuration: time.Minute, DefaultRole: "contributor"}, testJWTConfig, testKeySet(t))
+	_, err := service.Register(context.Background(), &models.RegisterUserRequest{Username: "ranger", Password: "correct horse"})
+	require.NoError(t, err)
+	return service
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name          string
+		username      string
+		password      string
+		expectedError string
+	}{
+		{
+			name:     "valid credentials",
+			username: "ranger",
+			password: "correct horse",
+		},
+		{
+			name:     "username is case insensitive",
+			username: "Ranger",
+			password: "correct horse",
+		},
+		{
+			name:          "wrong password",
+			username:      "ranger",
+			password:      "wrong horse",
+			expectedError: "invalid username or password",
+		},
+		{
+			name:          "unknown user",
+			username:      "nobody",
+			password:      "correct horse",
+			expectedError: "invalid username or password",
+		},
+		{
+			name:          "missing password",
+			username:      "ranger",
+			expectedError: "username and password are required",
+		},
+	}
+
+	service := newTestLoginService(t, 5)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := service.Login(context.Background(), tt.username, tt.password)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Empty(t, token)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, token)
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	service := newTestLoginService(t, 3)
+
+	for i := 0; i < 2; i++ {
+		_, err := service.Login(ctx, "ranger", "wrong horse")
+		assert.EqualError(t, err, "invalid username or password")
+	}
+
+	// A successful login resets the count
+	_, err := service.Login(ctx, "ranger", "correct horse")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := service.Login(ctx, "ranger", "wrong horse")
+		assert.EqualError(t, err, "invalid username or password")
+	}
+
+	// Even the right password is refused, with the same error as a wrong one
+	_, err = service.Login(ctx, "ranger", "correct horse")
+	assert.EqualError(t, err, "invalid username or password")
+
+	// Once the lockout has passed the right password works again
+	user, err := service.users.FindByUsername(ctx, "ranger")
+	require.NoError(t, err)
+	expired := time.Now().Add(-time.Second)
+	user.LockedUntil = &expired
+	require.NoError(t, service.users.Save(ctx, user))
+
+	_, err = service.Login(ctx, "ranger", "correct horse")
+	assert.NoError(t, err)
+}
+
+func TestRegister(t *testing.T) {
+	service := newTestLoginService(t, 5)
+
+	user, err := service.Register(context.Background(), &models.RegisterUserRequest{Username: "hiker", Password: "correct horse"})
+	require.NoError(t, err)
+	assert.Equal(t, "hiker", user.Username)
+	assert.Equal(t, models.RoleContributor, user.Role)
+
+	_, err = service.Register(context.Background(), &models.RegisterUserRequest{Username: "Ranger", Password: "correct horse"})
+	assert.EqualError(t, err, "user already exists")
+
+	// Without a default role configured new accounts can only read
+	readers := NewLoginService(storage.NewUserStorage(), storage.NewTokenStorage(), config.AuthConfig{}, testJWTConfig, testKeySet(t))
+	user, err = readers.Register(context.Background(), &models.RegisterUserRequest{Username: "hiker", Password: "correct horse"})
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleViewer, user.Role)
+}
+
+func TestSetRole(t *testing.T) {
+	ctx := context.Background()
+	service := newTestLoginService(t, 5)
+
+	tokens, err := service.Login(ctx, "ranger", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleContributor, parseTestClaims(t, tokens.AccessToken).Role)
+
+	user, err := service.SetRole(ctx, "Ranger", models.RoleModerator)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleModerator, user.Role)
+
+	// Refreshed tokens pick up the new role
+	refreshed, err := service.Refresh(ctx, tokens.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleModerator, parseTestClaims(t, refreshed.AccessToken).Role)
+
+	_, err = service.SetRole(ctx, "nobody", models.RoleAdmin)
+	assert.EqualError(t, err, "user not found")
+}
+
+func TestEnsureAdmin(t *testing.T) {
+	ctx := context.Background()
+	service := newTestLoginService(t, 5)
+
+	// A new account is created as an admin
+	require.NoError(t, service.EnsureAdmin(ctx, "warden", "warden password"))
+	tokens, err := service.Login(ctx, "warden", "warden password")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, parseTestClaims(t, tokens.AccessToken).Role)
+
+	// An existing account is promoted and keeps its password
+	require.NoError(t, service.EnsureAdmin(ctx, "ranger", "another password"))
+	tokens, err = service.Login(ctx, "ranger", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, parseTestClaims(t, tokens.AccessToken).Role)
+
+	assert.Error(t, service.EnsureAdmin(ctx, "warden", "short"))
+}
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	OIDCLoginTimeout = 10 * time.Minute
+	// oidcMaxPendingLogins bounds the memory held by logins that are started and never finished
+	oidcMaxPendingLogins = 10000
+	// oidcJWKSRefreshInterval stops unknown key ids from fetching the provider's keys on every login
+	oidcJWKSRefreshInterval = time.Minute
+	oidcMaxResponseBytes    = 1 << 20
+)
+
+// OIDCService logs users in through an OpenID Connect provider with the authorization code flow and PKCE
+type OIDCService interface {
+	// AuthCodeURL returns the state the login comes back with, which the caller should tie to the browser
+	AuthCodeURL(ctx context.Context) (authURL string, state string, err error)
+	Callback(ctx context.Context, code string, state string) (*models.TokenPair, error)
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcLogin struct {
+	verifier  string
+	nonce     string
+	expiresAt time.Time
+}
+
+type oidcService struct {
+	cfg    config.OIDCConfig
+	logins LoginService
+	client *http.Client
+
+	sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+	// pending is keyed by the state sent to the provider
+	pending map[string]oidcLogin
+}
+
+// NewOIDCService fetches the provider's discovery document and keys with client when first needed
+func NewOIDCService(cfg config.OIDCConfig, logins LoginService, client *http.Client) *oidcService {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &oidcService{
+		cfg:     cfg,
+		logins:  logins,
+		client:  client,
+		pending: make(map[string]oidcLogin),
+	}
+}
+
+func (s *oidcService) AuthCodeURL(ctx context.Context) (string, string, error) {
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	s.Lock()
+	for key, login := range s.pending {
+		if now.After(login.expiresAt) {
+			delete(s.pending, key)
+		}
+	}
+	if len(s.pending) >= oidcMaxPendingLogins {
+		s.Unlock()
+		return "", "", ErrTooManyLogins
+	}
+	s.pending[state] = oidcLogin{verifier: verifier, nonce: nonce, expiresAt: now.Add(OIDCLoginTimeout)}
+	s.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {s.cfg.ClientID},
+		"redirect_uri":          {s.cfg.RedirectURL},
+		"scope":                 {strings.Join(s.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+func (s *oidcService) Callback(ctx context.Context, code string, state string) (*models.TokenPair, error) {
+	if code == "" || state == "" {
+		return nil, models.NewValidationError("", "code and state are required")
+	}
+
+	// Each state can only be used once
+	s.Lock()
+	login, ok := s.pending[state]
+	delete(s.pending, state)
+	s.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		return nil, ErrInvalidLoginState
+	}
+
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, err := s.exchange(ctx, discovery, code, login.verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, s.keyfunc(ctx, discovery),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(s.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+	)
+	if err != nil {
+		return nil, models.Errorf(models.ErrUnauthenticated, "invalid id token: %w", err)
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != login.nonce {
+		return nil, models.Errorf(models.ErrUnauthenticated, "invalid id token: nonce does not match")
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, models.Errorf(models.ErrUnauthenticated, "invalid id token: no sub claim")
+	}
+	username, _ := claims[s.cfg.UsernameClaim].(string)
+	if username == "" {
+		return nil, models.Errorf(models.ErrUnauthenticated, "invalid id token: no %s claim", s.cfg.UsernameClaim)
+	}
+	return s.logins.LoginExternal(ctx, models.ExternalSubject(discovery.Issuer, subject), username, s.roleFrom(claims))
+}
+
+// roleFrom picks the most privileged role named, empty when none are recognised
+func (s *oidcService) roleFrom(claims jwt.MapClaims) models.Role {
+	var names []string
+	switch value := claims[s.cfg.RolesClaim].(type) {
+	case string:
+		names = strings.Fields(value)
+	case []any:
+		for _, item := range value {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var role models.Role
+	for _, name := range names {
+		candidate := models.Role(name)
+		if mapped, ok := s.cfg.RoleMapping[name]; ok {
+			candidate = models.Role(mapped)
+		}
+		if candidate.Rank() > role.Rank() {
+			role = candidate
+		}
+	}
+	return role
+}
+
+func (s *oidcService) discover(ctx context.Context) (*oidcDiscovery, error) {
+	s.Lock()
+	discovery := s.discovery
+	s.Unlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	issuer := strings.TrimSuffix(s.cfg.IssuerURL, "/")
+	discovery = &oidcDiscovery{}
+	if err := s.getJSON(ctx, issuer+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, models.Errorf(models.ErrUnavailable, "failed to fetch oidc discovery document: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, models.Errorf(models.ErrUnavailable, "oidc discovery document is for issuer %q, not %q", discovery.Issuer, s.cfg.IssuerURL)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, models.Errorf(models.ErrUnavailable, "oidc discovery document is missing endpoints")
+	}
+
+	s.Lock()
+	s.discovery = discovery
+	s.Unlock()
+	return discovery, nil
+}
+
+func (s *oidcService) exchange(ctx context.Context, discovery *oidcDiscovery, code string, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {s.cfg.RedirectURL},
+		"client_id":     {s.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	if s.cfg.ClientSecret != "" {
+		form.Set("client_secret", s.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := s.doJSON(req, &tokens); err != nil {
+		return "", models.Errorf(models.ErrUnavailable, "failed to exchange authorization code: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return "", models.Errorf(models.ErrUnavailable, "failed to exchange authorization code: no id_token in response")
+	}
+	return tokens.IDToken, nil
+}
+
+// keyfunc fetches the provider's keys again when the key id is unknown in case they have been rotated
+func (s *oidcService) keyfunc(ctx context.Context, discovery *oidcDiscovery) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		s.Lock()
+		key, ok := s.keys[kid]
+		stale := time.Since(s.keysFetched) > oidcJWKSRefreshInterval
+		s.Unlock()
+		if ok {
+			return key, nil
+		}
+		if !stale && s.keys != nil {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		var jwks models.JWKS
+		if err := s.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+			return nil, fmt.Errorf("failed to fetch oidc keys: %w", err)
+		}
+		keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+		for _, jwk := range jwks.Keys {
+			if jwk.Use != "" && jwk.Use != "sig" {
+				continue
+			}
+			// Keys we cannot use are skipped rather than failing every login
+			if public, err := publicKeyFromJWK(jwk); err == nil {
+				keys[jwk.Kid] = public
+			}
+		}
+
+		s.Lock()
+		s.keys = keys
+		s.keysFetched = time.Now()
+		s.Unlock()
+
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+}
+
+func (s *oidcService) getJSON(ctx context.Context, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return s.doJSON(req, v)
+}
+
+func (s *oidcService) doJSON(req *http.Request, v any) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %s", req.URL.Redacted(), resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseBytes)).Decode(v)
+}
+
+func publicKeyFromJWK(jwk models.JWK) (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		// Reject points that are not on the curve
+		if _, err := key.ECDH(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func randomString() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/config"
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdP is an in-process OpenID Connect provider. Users "log in" by calling approve with the
+// authorization URL and the claims to put in their ID token.
+type fakeIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	sync.Mutex
+	codes map[string]fakeGrant
+}
+
+type fakeGrant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	claims      jwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	idp := &fakeIdP{t: t, key: key, codes: make(map[string]fakeGrant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		point, _ := key.PublicKey.ECDH()
+		xy := point.Bytes()[1:]
+		json.NewEncoder(w).Encode(models.JWKS{Keys: []models.JWK{{
+			Kty: "EC", Kid: "idp-key", Use: "sig", Alg: "ES256", Crv: "P-256",
+			X: base64.RawURLEncoding.EncodeToString(xy[:32]),
+			Y: base64.RawURLEncoding.EncodeToString(xy[32:]),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// approve plays the user logging in at the provider, returning the code and state it would redirect back with.
+// The nonce from the authorization URL is added to claims unless they already set one.
+func (idp *fakeIdP) approve(authURL string, claims jwt.MapClaims) (string, string) {
+	parsed, err := url.Parse(authURL)
+	require.NoError(idp.t, err)
+	query := parsed.Query()
+	require.Equal(idp.t, "code", query.Get("response_type"))
+	require.Equal(idp.t, "S256", query.Get("code_challenge_method"))
+
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = query.Get("nonce")
+	}
+	code, err := randomString()
+	require.NoError(idp.t, err)
+
+	idp.Lock()
+	idp.codes[code] = fakeGrant{
+		clientID:    query.Get("client_id"),
+		redirectURI: query.Get("redirect_uri"),
+		challenge:   query.Get("code_challenge"),
+		claims:      claims,
+	}
+	idp.Unlock()
+	return code, query.Get("state")
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	require.NoError(idp.t, r.ParseForm())
+
+	idp.Lock()
+	grant, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("client_id") != grant.clientID ||
+		r.PostForm.Get("redirect_uri") != grant.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss": idp.server.URL,
+		"aud": grant.clientID,
+		"sub": "user-1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+	for name, value := range grant.claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = "idp-key"
+	idToken, err := token.SignedString(idp.key)
+	require.NoError(idp.t, err)
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": idToken})
+}
+
+func newTestOIDCService(t *testing.T) (*oidcService, *fakeIdP, *loginService) {
+	idp := newFakeIdP(t)
+	logins := newTestLoginService(t, 5)
+	cfg := config.OIDCConfig{
+		IssuerURL:     idp.server.URL,
+		ClientID:      "trail-data-service",
+		RedirectURL:   "http://localhost:8080/oidc/callback",
+		Scopes:        []string{"openid", "profile"},
+		UsernameClaim: config.OIDC_USERNAME_CLAIM,
+		RolesClaim:    config.OIDC_ROLES_CLAIM,
+		RoleMapping:   map[string]string{"trail-editors": "moderator"},
+	}
+	return NewOIDCService(cfg, logins, idp.server.Client()), idp, logins
+}
+
+func TestOIDCLogin(t *testing.T) {
+	ctx := context.Background()
+	service, idp, logins := newTestOIDCService(t)
+
+	authURL, started, err := service.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	code, state := idp.approve(authURL, jwt.MapClaims{"preferred_username": "Explorer", "roles": []string{"staff", "trail-editors"}})
+	assert.Equal(t, started, state)
+
+	tokens, err := service.Callback(ctx, code, state)
+	require.NoError(t, err)
+	claims := parseTestClaims(t, tokens.AccessToken)
+	assert.Equal(t, "explorer", claims.Username)
+	assert.Equal(t, models.RoleModerator, claims.Role)
+
+	// The account is created without a password, so it cannot log in locally
+	user, err := logins.users.FindByUsername(ctx, "explorer")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleModerator, user.Role)
+	assert.Empty(t, user.PasswordHash)
+
+	// Tokens from the provider login refresh like any other
+	_, err = logins.Refresh(ctx, tokens.RefreshToken)
+	assert.NoError(t, err)
+
+	// Logging in again keeps the role in step with the provider when it names one
+	authURL, _, err = service.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	code, state = idp.approve(authURL, jwt.MapClaims{"preferred_username": "explorer", "roles": "contributor"})
+	tokens, err = service.Callback(ctx, code, state)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleContributor, parseTestClaims(t, tokens.AccessToken).Role)
+
+	// A role given here is kept when the provider names none
+	_, err = logins.SetRole(ctx, "explorer", models.RoleAdmin)
+	require.NoError(t, err)
+	authURL, _, err = service.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	code, state = idp.approve(authURL, jwt.MapClaims{"preferred_username": "explorer", "roles": "staff"})
+	tokens, err = service.Callback(ctx, code, state)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, parseTestClaims(t, tokens.AccessToken).Role)
+
+	// The account follows the subject, not the username the provider sends
+	authURL, _, err = service.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	code, state = idp.approve(authURL, jwt.MapClaims{"preferred_username": "renamed"})
+	tokens, err = service.Callback(ctx, code, state)
+	require.NoError(t, err)
+	assert.Equal(t, "explorer", parseTestClaims(t, tokens.AccessToken).Username)
+
+	// Another provider user sending the same username cannot take the account over
+	authURL, _, err = service.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	code, state = idp.approve(authURL, jwt.MapClaims{"preferred_username": "explorer", "sub": "user-2"})
+	_, err = service.Callback(ctx, code, state)
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+}
+
+func TestOIDCLogin_Rejected(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		claims        jwt.MapClaims
+		callback      func(service *oidcService, code string, state string) (*models.TokenPair, error)
+		expectedError string
+	}{
+		{
+			name:   "unknown state",
+			claims: jwt.MapClaims{"preferred_username": "explorer"},
+			callback: func(service *oidcService, code string, state string) (*models.TokenPair, error) {
+				return service.Callback(ctx, code, "forged")
+			},
+			expectedError: "invalid or expired login state",
+		},
+		{
+			name:   "state used twice",
+			claims: jwt.MapClaims{"preferred_username": "explorer"},
+			callback: func(service *oidcService, code string, state string) (*models.TokenPair, error) {
+				if _, err := service.Callback(ctx, code, state); err != nil {
+					return nil, err
+				}
+				return service.Callback(ctx, code, state)
+			},
+			expectedError: "invalid or expired login state",
+		},
+		{
+			name:          "nonce from another login",
+			claims:        jwt.MapClaims{"preferred_username": "explorer", "nonce": "replayed"},
+			expectedError: "invalid id token: nonce does not match",
+		},
+		{
+			name:          "token for another client",
+			claims:        jwt.MapClaims{"preferred_username": "explorer", "aud": "another-client"},
+			expectedError: "invalid id token: token has invalid claims: token has invalid audience",
+		},
+		{
+			name:          "token from another issuer",
+			claims:        jwt.MapClaims{"preferred_username": "explorer", "iss": "https://evil.example.com"},
+			expectedError: "invalid id token: token has invalid claims: token has invalid issuer",
+		},
+		{
+			name:          "expired token",
+			claims:        jwt.MapClaims{"preferred_username": "explorer", "exp": time.Now().Add(-time.Minute).Unix()},
+			expectedError: "invalid id token: token has invalid claims: token is expired",
+		},
+		{
+			name:          "no username",
+			claims:        jwt.MapClaims{"email": "explorer@example.com"},
+			expectedError: "invalid id token: no preferred_username claim",
+		},
+		{
+			name:          "no subject",
+			claims:        jwt.MapClaims{"preferred_username": "explorer", "sub": ""},
+			expectedError: "invalid id token: no sub claim",
+		},
+		{
+			name:          "invalid username",
+			claims:        jwt.MapClaims{"preferred_username": "explorer@example.com"},
+			expectedError: "username from the provider must be 3 to 64 letters",
+		},
+		{
+			name:          "username of a local account",
+			claims:        jwt.MapClaims{"preferred_username": "ranger"},
+			expectedError: "username belongs to another account",
+		},
+		{
+			name:   "code exchanged without the verifier",
+			claims: jwt.MapClaims{"preferred_username": "explorer"},
+			callback: func(service *oidcService, code string, state string) (*models.TokenPair, error) {
+				// Replace the stored verifier as if an attacker had injected a stolen code into their own login
+				service.Lock()
+				login := service.pending[state]
+				login.verifier = "attacker-verifier"
+				service.pending[state] = login
+				service.Unlock()
+				return service.Callback(ctx, code, state)
+			},
+			expectedError: "failed to exchange authorization code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, idp, _ := newTestOIDCService(t)
+			authURL, _, err := service.AuthCodeURL(ctx)
+			require.NoError(t, err)
+			code, state := idp.approve(authURL, tt.claims)
+
+			callback := tt.callback
+			if callback == nil {
+				callback = func(service *oidcService, code string, state string) (*models.TokenPair, error) {
+					return service.Callback(ctx, code, state)
+				}
+			}
+			_, err = callback(service, code, state)
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestOIDCDiscovery_WrongIssuer(t *testing.T) {
+	idp := newFakeIdP(t)
+	cfg := config.OIDCConfig{IssuerURL: idp.server.URL + "/tenant", ClientID: "trail-data-service", RedirectURL: "http://localhost/cb"}
+	service := NewOIDCService(cfg, newTestLoginService(t, 5), idp.server.Client())
+
+	_, _, err := service.AuthCodeURL(context.Background())
+	assert.Error(t, err)
+}
+
+func TestOIDCLogin_TooManyPending(t *testing.T) {
+	ctx := context.Background()
+	service, _, _ := newTestOIDCService(t)
+
+	service.Lock()
+	for i := 0; i < oidcMaxPendingLogins; i++ {
+		service.pending[strconv.Itoa(i)] = oidcLogin{expiresAt: time.Now().Add(time.Minute)}
+	}
+	service.Unlock()
+	_, _, err := service.AuthCodeURL(ctx)
+	assert.ErrorIs(t, err, ErrTooManyLogins)
+
+	// Expired logins make room again
+	service.Lock()
+	service.pending["0"] = oidcLogin{expiresAt: time.Now().Add(-time.Second)}
+	service.Unlock()
+	_, state, err := service.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, state)
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/dnakolan/trail-data-service/internal/storage"
+)
+
+const refreshTokenBytes = 32
+
+type RevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+func (s *loginService) issueTokens(ctx context.Context, user *models.User, familyID string) (*models.TokenPair, error) {
+	accessToken, err := s.generateToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	err = s.tokens.SaveRefreshToken(ctx, &models.RefreshToken{
+		Hash:      hashSecret(refreshToken),
+		FamilyID:  familyID,
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(s.jwt.RefreshExpiration),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.jwt.Expiration.Seconds()),
+	}, nil
+}
+
+// Refresh tokens work once, a token presented again after being swapped has leaked, so every token
+// descended from the same login is revoked
+func (s *loginService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	if refreshToken == "" {
+		return nil, models.NewValidationError("refresh_token", "refresh token is required")
+	}
+
+	now := time.Now()
+	stored, err := s.tokens.UseRefreshToken(ctx, hashSecret(refreshToken), now)
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if stored.UsedAt != nil {
+		if err := s.tokens.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if stored.Revoked || stored.IsExpired(now) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Read the account again as it may have been removed or had its role changed since the token was issued
+	user, err := s.users.FindByUsername(ctx, stored.Username)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, stored.FamilyID)
+}
+
+func (s *loginService) Logout(ctx context.Context, claims *models.Claims, refreshToken string) error {
+	if claims.ExpiresAt != nil {
+		if err := s.tokens.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	stored, err := s.tokens.FindRefreshToken(ctx, hashSecret(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			return nil
+		}
+		return err
+	}
+	// Only the owner of the refresh token can revoke it
+	if stored.Username != claims.Username {
+		return nil
+	}
+	return s.tokens.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+}
+
+func (s *loginService) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.tokens.IsAccessTokenRevoked(ctx, jti)
+}
+
+// hashSecret needs no salt or stretching, refresh tokens and API keys are too random to brute force
+func hashSecret(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
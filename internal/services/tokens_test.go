@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestClaims(t *testing.T, token string) *models.Claims {
+	claims := &models.Claims{}
+	_, _, err := jwt.NewParser().ParseUnverified(token, claims)
+	require.NoError(t, err)
+	return claims
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	service := newTestLoginService(t, 5)
+
+	first, err := service.Login(ctx, "ranger", "correct horse")
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.RefreshToken)
+	assert.Equal(t, 3600, first.ExpiresIn)
+
+	second, err := service.Refresh(ctx, first.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.NotEqual(t, first.AccessToken, second.AccessToken)
+	assert.Equal(t, "ranger", parseTestClaims(t, second.AccessToken).Username)
+
+	third, err := service.Refresh(ctx, second.RefreshToken)
+	require.NoError(t, err)
+
+	// Presenting an already rotated token revokes the whole family, including the newest token
+	_, err = service.Refresh(ctx, first.RefreshToken)
+	assert.EqualError(t, err, "invalid refresh token")
+	_, err = service.Refresh(ctx, third.RefreshToken)
+	assert.EqualError(t, err, "invalid refresh token")
+
+	// Other logins are unaffected
+	other, err := service.Login(ctx, "ranger", "correct horse")
+	require.NoError(t, err)
+	_, err = service.Refresh(ctx, other.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestRefresh_Invalid(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		token         func(t *testing.T, service *loginService) string
+		expectedError string
+	}{
+		{
+			name:          "missing",
+			token:         func(t *testing.T, service *loginService) string { return "" },
+			expectedError: "refresh token is required",
+		},
+		{
+			name:          "unknown",
+			token:         func(t *testing.T, service *loginService) string { return "not-a-refresh-token" },
+			expectedError: "invalid refresh token",
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T, service *loginService) string {
+				service.jwt.RefreshExpiration = -time.Minute
+				tokens, err := service.Login(ctx, "ranger", "correct horse")
+				require.NoError(t, err)
+				return tokens.RefreshToken
+			},
+			expectedError: "invalid refresh token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestLoginService(t, 5)
+			_, err := service.Refresh(ctx, tt.token(t, service))
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ctx := context.Background()
+	service := newTestLoginService(t, 5)
+
+	tokens, err := service.Login(ctx, "ranger", "correct horse")
+	require.NoError(t, err)
+	claims := parseTestClaims(t, tokens.AccessToken)
+
+	revoked, err := service.IsTokenRevoked(ctx, claims.ID)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, service.Logout(ctx, claims, tokens.RefreshToken))
+
+	revoked, err = service.IsTokenRevoked(ctx, claims.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	_, err = service.Refresh(ctx, tokens.RefreshToken)
+	assert.EqualError(t, err, "invalid refresh token")
+}
+
+func TestLogout_OtherUsersRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	service := newTestLoginService(t, 5)
+	_, err := service.Register(ctx, &models.RegisterUserRequest{Username: "hiker", Password: "correct horse"})
+	require.NoError(t, err)
+
+	ranger, err := service.Login(ctx, "ranger", "correct horse")
+	require.NoError(t, err)
+	hiker, err := service.Login(ctx, "hiker", "correct horse")
+	require.NoError(t, err)
+
+	require.NoError(t, service.Logout(ctx, parseTestClaims(t, hiker.AccessToken), ranger.RefreshToken))
+
+	_, err = service.Refresh(ctx, ranger.RefreshToken)
+	assert.NoError(t, err, "a refresh token can only be revoked by its owner")
+}
@@ -2,7 +2,8 @@ package services
 
 import (
 	"context"
-	"errors"
+	"slices"
+	"sort"
 
 	"github.com/dnakolan/trail-data-service/internal/config"
 	"github.com/dnakolan/trail-data-service/internal/models"
@@ -10,51 +11,145 @@ import (
 )
 
 type TrailsService interface {
-	CreateTrail(ctx context.Context, trail *models.Trail) error
+	CreateTrail(ctx context.Context, trail *models.Trail, force bool) error
 	GetTrail(ctx context.Context, uid string) (*models.Trail, error)
-	UpdateTrail(ctx context.Context, trail *models.Trail) error
+	UpdateTrail(ctx context.Context, trail *models.Trail, force bool) error
 	DeleteTrail(ctx context.Context, uid string) error
 	GetAllTrails(ctx context.Context, filter *models.TrailFilter) ([]*models.Trail, error)
+	ListTrails(ctx context.Context, filter *models.TrailFilter) (*models.TrailPage, error)
+	SuggestTrails(ctx context.Context, prefix string, limit int) ([]models.TrailSuggestion, error)
 }
 
 type trailsService struct {
-	storage storage.TrailStorage
+	storage    storage.TrailStorage
+	rater      models.DifficultyRater
+	duplicates config.DuplicateConfig
 }
 
-func NewTrailsService(storage storage.TrailStorage) *trailsService {
-	return &trailsService{storage: storage}
+func NewTrailsService(storage storage.TrailStorage, rater models.DifficultyRater, duplicates config.DuplicateConfig) *trailsService {
+	return &trailsService{storage: storage, rater: rater, duplicates: duplicates}
 }
 
-func (s *trailsService) CreateTrail(ctx context.Context, trail *models.Trail) error {
-	radiusKm := config.DUPLICATE_TRAIL_RADIUS_KM
+func (s *trailsService) CreateTrail(ctx context.Context, trail *models.Trail, force bool) error {
+	if !force {
+		duplicates, err := s.findDuplicates(ctx, trail)
+		if err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			return &models.ConflictError{Err: ErrTrailExists, IDs: duplicates}
+		}
+	}
+
+	if err := s.rate(trail); err != nil {
+		return err
+	}
+	return s.storage.Save(ctx, trail)
+}
+
+// Trails with the same name are duplicates anywhere within the radius, whatever they score
+func (s *trailsService) findDuplicates(ctx context.Context, trail *models.Trail) ([]string, error) {
+	if trail.Lat == nil || trail.Lon == nil {
+		return nil, nil
+	}
+	radiusKm := s.duplicates.RadiusKm
 	filter := &models.TrailFilter{
 		CreateTrailRequest: models.CreateTrailRequest{
-			Name: trail.Name,
-			Lat:  trail.Lat,
-			Lon:  trail.Lon,
+			Lat: trail.Lat,
+			Lon: trail.Lon,
 		},
 		RadiusKm: &radiusKm,
 	}
-
-	duplicates, err := s.GetAllTrails(ctx, filter)
+	nearby, err := s.GetAllTrails(ctx, filter)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if len(duplicates) > 0 {
-		return errors.New("trail already exists")
+
+	type scored struct {
+		id    string
+		score float64
 	}
+	var duplicates []scored
+	for _, candidate := range nearby {
+		if candidate.UID == trail.UID {
+			continue
+		}
+		score := models.DuplicateScore(trail, candidate, radiusKm)
+		sameName := trail.Name != nil && candidate.Name != nil && models.SameTrailName(*trail.Name, *candidate.Name)
+		if sameName || score >= s.duplicates.Threshold {
+			duplicates = append(duplicates, scored{id: candidate.UID.String(), score: score})
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].score > duplicates[j].score
+	})
 
-	return s.storage.Save(ctx, trail)
+	ids := make([]string, len(duplicates))
+	for i, duplicate := range duplicates {
+		ids[i] = duplicate.id
+	}
+	return ids, nil
 }
 
 func (s *trailsService) GetTrail(ctx context.Context, uid string) (*models.Trail, error) {
 	return s.storage.FindById(ctx, uid)
 }
 
-func (s *trailsService) UpdateTrail(ctx context.Context, trail *models.Trail) error {
+func (s *trailsService) UpdateTrail(ctx context.Context, trail *models.Trail, force bool) error {
+	existing, err := s.storage.FindById(ctx, trail.UID.String())
+	if err != nil {
+		return err
+	}
+
+	// Other changes are let through, so trails forced in alongside duplicates can still be edited
+	if !force && duplicateFieldsChanged(existing, trail) {
+		duplicates, err := s.findDuplicates(ctx, trail)
+		if err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			return &models.ConflictError{Err: ErrTrailExists, IDs: duplicates}
+		}
+	}
+
+	if err := s.rate(trail); err != nil {
+		return err
+	}
 	return s.storage.Save(ctx, trail)
 }
 
+// rate keeps a difficulty the client gave, and a rated one when the trail can no longer be rated
+func (s *trailsService) rate(trail *models.Trail) error {
+	trail.Elevation = trail.Track.ElevationStats()
+	trail.DifficultyScore = nil
+
+	if rating, ok := s.rater.Rate(trail); ok {
+		trail.DifficultyScore = &rating.Score
+		if trail.Difficulty == nil || trail.DifficultyRated {
+			trail.Difficulty = &rating.Difficulty
+			trail.DifficultyRated = true
+		}
+	}
+	if trail.Difficulty == nil {
+		return models.NewValidationError("difficulty", "trail difficulty is required")
+	}
+	return nil
+}
+
+func duplicateFieldsChanged(before, after *models.Trail) bool {
+	return !equalPtr(before.Name, after.Name) || !equalPtr(before.Lat, after.Lat) || !equalPtr(before.Lon, after.Lon) ||
+		!slices.EqualFunc(before.Track, after.Track, func(a, b models.Coordinate) bool {
+			return a.Lat == b.Lat && a.Lon == b.Lon
+		})
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 func (s *trailsService) DeleteTrail(ctx context.Context, uid string) error {
 	return s.storage.Delete(ctx, uid)
 }
@@ -62,3 +157,59 @@ func (s *trailsService) DeleteTrail(ctx context.Context, uid string) error {
 func (s *trailsService) GetAllTrails(ctx context.Context, filter *models.TrailFilter) ([]*models.Trail, error) {
 	return s.storage.FindAll(ctx, filter)
 }
+
+func (s *trailsService) ListTrails(ctx context.Context, filter *models.TrailFilter) (*models.TrailPage, error) {
+	if filter == nil {
+		filter = &models.TrailFilter{}
+	}
+
+	// Ask for one extra trail to find out whether another page follows
+	pageFilter := *filter
+	if filter.Limit != nil {
+		limit := *filter.Limit + 1
+		pageFilter.Limit = &limit
+	}
+
+	trails, err := s.storage.FindAll(ctx, &pageFilter)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.storage.Count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.TrailPage{Trails: trails, Total: total}
+	if filter.Limit != nil && len(trails) > *filter.Limit {
+		page.Trails = trails[:*filter.Limit]
+		next := filter.CursorFor(page.Trails[len(page.Trails)-1]).Encode()
+		page.NextCursor = &next
+	}
+
+	if filter.Lat != nil && filter.Lon != nil {
+		for i, trail := range page.Trails {
+			page.Trails[i] = trail.WithDistanceFrom(*filter.Lat, *filter.Lon)
+		}
+	}
+	return page, nil
+}
+
+func (s *trailsService) SuggestTrails(ctx context.Context, prefix string, limit int) ([]models.TrailSuggestion, error) {
+	filter := &models.TrailFilter{
+		Query: models.NewTextQuery(prefix, true),
+		Limit: &limit,
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	trails, err := s.storage.FindAll(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]models.TrailSuggestion, 0, len(trails))
+	for _, trail := range trails {
+		suggestions = append(suggestions, models.TrailSuggestion{UID: trail.UID, Name: *trail.Name})
+	}
+	return suggestions, nil
+}
@@ -5,12 +5,24 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/dnakolan/trail-data-service/internal/config"
 	"github.com/dnakolan/trail-data-service/internal/models"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
+var testRater = &models.ShenandoahRater{
+	MediumScore: models.DefaultMediumDifficultyScore,
+	HardScore:   models.DefaultHardDifficultyScore,
+}
+
+var testDuplicates = config.DuplicateConfig{
+	RadiusKm:  config.DUPLICATE_TRAIL_RADIUS_KM,
+	Threshold: config.DUPLICATE_TRAIL_THRESHOLD,
+}
+
 // MockTrailStorage is a mock implementation of storage.TrailStorage
 type MockTrailStorage struct {
 	mock.Mock
@@ -29,6 +41,11 @@ func (m *MockTrailStorage) FindAll(ctx context.Context, filter *models.TrailFilt
 	return args.Get(0).([]*models.Trail), nil
 }
 
+func (m *MockTrailStorage) Count(ctx context.Context, filter *models.TrailFilter) (int, error) {
+	args := m.Called(ctx, filter)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *MockTrailStorage) FindById(ctx context.Context, uid string) (*models.Trail, error) {
 	args := m.Called(ctx, uid)
 	if args.Error(1) != nil {
@@ -49,10 +66,11 @@ func (m *MockTrailStorage) Clear(ctx context.Context) error {
 
 func TestCreateTrail(t *testing.T) {
 	mockStorage := new(MockTrailStorage)
-	service := NewTrailsService(mockStorage)
+	service := NewTrailsService(mockStorage, testRater, testDuplicates)
 	ctx := context.Background()
 
 	trail := models.NewTrail("Test Trail", 45.5231, -122.6765, models.TrailDifficultyMedium, 10.5)
+	existing := models.NewTrail("Test Trail", 45.5231, -122.6765, models.TrailDifficultyMedium, 10.5)
 
 	tests := []struct {
 		name        string
@@ -67,10 +85,10 @@ func TestCreateTrail(t *testing.T) {
 			setupMock: func() {
 				// Mock the duplicate check
 				mockStorage.On("FindAll", ctx, mock.MatchedBy(func(f *models.TrailFilter) bool {
-					return f.Name != nil && *f.Name == "Test Trail" &&
+					return f.Name == nil &&
 						f.Lat != nil && *f.Lat == 45.5231 &&
 						f.Lon != nil && *f.Lon == -122.6765 &&
-						f.RadiusKm != nil
+						f.RadiusKm != nil && *f.RadiusKm == config.DUPLICATE_TRAIL_RADIUS_KM
 				})).Return([]*models.Trail{}, nil).Once()
 
 				// Mock the save
@@ -83,7 +101,7 @@ func TestCreateTrail(t *testing.T) {
 			trail: trail,
 			setupMock: func() {
 				// Mock finding a duplicate
-				mockStorage.On("FindAll", ctx, mock.Anything).Return([]*models.Trail{trail}, nil).Once()
+				mockStorage.On("FindAll", ctx, mock.Anything).Return([]*models.Trail{existing}, nil).Once()
 			},
 			expectError: true,
 			errorMsg:    "trail already exists",
@@ -113,7 +131,7 @@ func TestCreateTrail(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setupMock()
 
-			err := service.CreateTrail(ctx, tt.trail)
+			err := service.CreateTrail(ctx, tt.trail, false)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -129,9 +147,168 @@ func TestCreateTrail(t *testing.T) {
 	}
 }
 
+func TestCreateTrailDuplicates(t *testing.T) {
+	ctx := context.Background()
+	existing := models.NewTrail("Lamar River Trail", 44.8976, -110.2345, models.TrailDifficultyMedium, 5)
+	respelled := models.NewTrail("Lamar River Trail", 44.9, -110.24, models.TrailDifficultyMedium, 5)
+	nearby := []*models.Trail{
+		models.NewTrail("Slough Creek Trail", 44.8976, -110.2345, models.TrailDifficultyEasy, 3),
+		models.NewTrail("Upper Falls Trail", 44.8976, -110.2345, models.TrailDifficultyEasy, 2),
+		models.NewTrail("Lake Trail", 44.8976, -110.2345, models.TrailDifficultyEasy, 4),
+		respelled,
+		existing,
+	}
+
+	tests := []struct {
+		name        string
+		trail       *models.Trail
+		force       bool
+		expectedIDs []string
+	}{
+		{
+			name:        "slightly different spelling",
+			trail:       models.NewTrail("The Lamarr River Trail", 44.8976, -110.2345, models.TrailDifficultyMedium, 5),
+			expectedIDs: []string{existing.UID.String(), respelled.UID.String()},
+		},
+		{
+			// About 23km from both, where the distance alone would not score them as duplicates
+			name:        "same name anywhere in the radius",
+			trail:       models.NewTrail("Lamar River", 45.105, -110.2345, models.TrailDifficultyMedium, 5),
+			expectedIDs: []string{respelled.UID.String(), existing.UID.String()},
+		},
+		{
+			name:  "one different word nearby",
+			trail: models.NewTrail("Lower Falls Trail", 44.9021, -110.2345, models.TrailDifficultyEasy, 2),
+		},
+		{
+			name:  "different kind of trail at the same trailhead",
+			trail: models.NewTrail("Lake Loop", 44.8976, -110.2345, models.TrailDifficultyEasy, 4),
+		},
+		{
+			name:  "different name at the same trailhead",
+			trail: models.NewTrail("Specimen Ridge Trail", 44.8976, -110.2345, models.TrailDifficultyHard, 12),
+		},
+		{
+			name:  "forced",
+			trail: models.NewTrail("Lamar River Trail", 44.8976, -110.2345, models.TrailDifficultyMedium, 5),
+			force: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockTrailStorage)
+			service := NewTrailsService(mockStorage, testRater, testDuplicates)
+			if !tt.force {
+				mockStorage.On("FindAll", ctx, mock.Anything).Return(nearby, nil).Once()
+			}
+			if tt.expectedIDs == nil {
+				mockStorage.On("Save", ctx, tt.trail).Return(nil).Once()
+			}
+
+			err := service.CreateTrail(ctx, tt.trail, tt.force)
+
+			if tt.expectedIDs != nil {
+				var conflict *models.ConflictError
+				require.ErrorAs(t, err, &conflict)
+				assert.ErrorIs(t, err, ErrTrailExists)
+				assert.Equal(t, tt.expectedIDs, conflict.IDs)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateTrailRatesDifficulty(t *testing.T) {
+	ctx := context.Background()
+	low, high := 1900.0, 2100.0
+	hard := models.TrailDifficultyHard
+
+	tests := []struct {
+		name               string
+		trail              func() *models.Trail
+		expectedDifficulty models.TrailDifficulty
+		expectScore        bool
+	}{
+		{
+			name: "difficulty omitted is rated from elevation",
+			trail: func() *models.Trail {
+				return models.NewTrailFromTrack("Slough Creek Trail", []models.Coordinate{
+					{Lat: 44.9446, Lon: -110.3066, Ele: &low},
+					{Lat: 45.0000, Lon: -110.2500, Ele: &high},
+				}, nil)
+			},
+			expectedDifficulty: models.TrailDifficultyMedium,
+			expectScore:        true,
+		},
+		{
+			name: "given difficulty is kept alongside the score",
+			trail: func() *models.Trail {
+				trail := models.NewTrailFromTrack("Slough Creek Trail", []models.Coordinate{
+					{Lat: 44.9446, Lon: -110.3066, Ele: &low},
+					{Lat: 45.0000, Lon: -110.2500, Ele: &high},
+				}, nil)
+				trail.Difficulty = &hard
+				return trail
+			},
+			expectedDifficulty: models.TrailDifficultyHard,
+			expectScore:        true,
+		},
+		{
+			name: "trail without elevation is not scored",
+			trail: func() *models.Trail {
+				return models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53)
+			},
+			expectedDifficulty: models.TrailDifficultyHard,
+			expectScore:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockTrailStorage)
+			service := NewTrailsService(mockStorage, testRater, testDuplicates)
+			trail := tt.trail()
+
+			mockStorage.On("FindAll", ctx, mock.Anything).Return([]*models.Trail{}, nil).Once()
+			mockStorage.On("Save", ctx, trail).Return(nil).Once()
+
+			require.NoError(t, service.CreateTrail(ctx, trail, false))
+			require.NotNil(t, trail.Difficulty)
+			assert.Equal(t, tt.expectedDifficulty, *trail.Difficulty)
+			if tt.expectScore {
+				require.NotNil(t, trail.Elevation)
+				assert.Equal(t, 200.0, trail.Elevation.AscentM)
+				assert.NotNil(t, trail.DifficultyScore)
+			} else {
+				assert.Nil(t, trail.DifficultyScore)
+			}
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateTrailWithoutDifficultyOrElevation(t *testing.T) {
+	mockStorage := new(MockTrailStorage)
+	service := NewTrailsService(mockStorage, testRater, testDuplicates)
+	ctx := context.Background()
+
+	trail := models.NewTrailFromTrack("Slough Creek Trail", []models.Coordinate{
+		{Lat: 44.9446, Lon: -110.3066},
+		{Lat: 44.9500, Lon: -110.3000},
+	}, nil)
+	mockStorage.On("FindAll", ctx, mock.Anything).Return([]*models.Trail{}, nil).Once()
+
+	err := service.CreateTrail(ctx, trail, false)
+	assert.EqualError(t, err, "trail difficulty is required")
+	mockStorage.AssertExpectations(t)
+}
+
 func TestGetTrail(t *testing.T) {
 	mockStorage := new(MockTrailStorage)
-	service := NewTrailsService(mockStorage)
+	service := NewTrailsService(mockStorage, testRater, testDuplicates)
 	ctx := context.Background()
 
 	uid := uuid.New()
@@ -197,7 +374,7 @@ func TestGetTrail(t *testing.T) {
 
 func TestUpdateTrail(t *testing.T) {
 	mockStorage := new(MockTrailStorage)
-	service := NewTrailsService(mockStorage)
+	service := NewTrailsService(mockStorage, testRater, testDuplicates)
 	ctx := context.Background()
 
 	trail := models.NewTrail("Test Trail", 45.5231, -122.6765, models.TrailDifficultyMedium, 10.5)
@@ -213,14 +390,25 @@ func TestUpdateTrail(t *testing.T) {
 			name:  "successful update",
 			trail: trail,
 			setupMock: func() {
+				mockStorage.On("FindById", ctx, trail.UID.String()).Return(trail, nil).Once()
 				mockStorage.On("Save", ctx, trail).Return(nil).Once()
 			},
 			expectError: false,
 		},
+		{
+			name:  "trail not found",
+			trail: trail,
+			setupMock: func() {
+				mockStorage.On("FindById", ctx, trail.UID.String()).Return(nil, errors.New("trail not found")).Once()
+			},
+			expectError: true,
+			errorMsg:    "trail not found",
+		},
 		{
 			name:  "storage error",
 			trail: trail,
 			setupMock: func() {
+				mockStorage.On("FindById", ctx, trail.UID.String()).Return(trail, nil).Once()
 				mockStorage.On("Save", ctx, trail).Return(errors.New("database error")).Once()
 			},
 			expectError: true,
@@ -232,7 +420,7 @@ func TestUpdateTrail(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setupMock()
 
-			err := service.UpdateTrail(ctx, tt.trail)
+			err := service.UpdateTrail(ctx, tt.trail, false)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -250,7 +438,7 @@ func TestUpdateTrail(t *testing.T) {
 
 func TestDeleteTrail(t *testing.T) {
 	mockStorage := new(MockTrailStorage)
-	service := NewTrailsService(mockStorage)
+	service := NewTrailsService(mockStorage, testRater, testDuplicates)
 	ctx := context.Background()
 
 	uid := uuid.New()
@@ -312,7 +500,7 @@ func TestDeleteTrail(t *testing.T) {
 
 func TestGetAllTrails(t *testing.T) {
 	mockStorage := new(MockTrailStorage)
-	service := NewTrailsService(mockStorage)
+	service := NewTrailsService(mockStorage, testRater, testDuplicates)
 	ctx := context.Background()
 
 	trail1 := models.NewTrail("Trail 1", 45.5231, -122.6765, models.TrailDifficultyMedium, 10.5)
@@ -381,3 +569,83 @@ func TestGetAllTrails(t *testing.T) {
 		})
 	}
 }
+
+func TestListTrails(t *testing.T) {
+	mockStorage := new(MockTrailStorage)
+	service := NewTrailsService(mockStorage, testRater, testDuplicates)
+	ctx := context.Background()
+
+	trail1 := models.NewTrail("Trail 1", 45.5231, -122.6765, models.TrailDifficultyMedium, 10.5)
+	trail2 := models.NewTrail("Trail 2", 45.5232, -122.6766, models.TrailDifficultyHard, 15.5)
+	trail3 := models.NewTrail("Trail 3", 45.5233, -122.6767, models.TrailDifficultyEasy, 5.5)
+
+	limit := 2
+	filter := &models.TrailFilter{Limit: &limit}
+
+	// The service asks storage for one more trail than the page size
+	withExtra := mock.MatchedBy(func(f *models.TrailFilter) bool {
+		return f.Limit != nil && *f.Limit == 3
+	})
+
+	tests := []struct {
+		name           string
+		setupMock      func()
+		expectError    bool
+		errorMsg       string
+		expectedCount  int
+		expectedTotal  int
+		expectedCursor bool
+	}{
+		{
+			name: "more trails than the limit",
+			setupMock: func() {
+				mockStorage.On("FindAll", ctx, withExtra).Return([]*models.Trail{trail1, trail2, trail3}, nil).Once()
+				mockStorage.On("Count", ctx, filter).Return(3, nil).Once()
+			},
+			expectedCount:  2,
+			expectedTotal:  3,
+			expectedCursor: true,
+		},
+		{
+			name: "last page",
+			setupMock: func() {
+				mockStorage.On("FindAll", ctx, withExtra).Return([]*models.Trail{trail3}, nil).Once()
+				mockStorage.On("Count", ctx, filter).Return(3, nil).Once()
+			},
+			expectedCount:  1,
+			expectedTotal:  3,
+			expectedCursor: false,
+		},
+		{
+			name: "storage error",
+			setupMock: func() {
+				mockStorage.On("FindAll", ctx, withExtra).Return(nil, errors.New("database error")).Once()
+			},
+			expectError: true,
+			errorMsg:    "database error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			page, err := service.ListTrails(ctx, filter)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Nil(t, page)
+				if tt.errorMsg != "" {
+					assert.Equal(t, tt.errorMsg, err.Error())
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, page.Trails, tt.expectedCount)
+				assert.Equal(t, tt.expectedTotal, page.Total)
+				assert.Equal(t, tt.expectedCursor, page.NextCursor != nil)
+			}
+
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
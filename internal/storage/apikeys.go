@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+)
+
+type APIKeyStorage interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	FindByID(ctx context.Context, id string) (*models.APIKey, error)
+	FindByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	// ListByUsername returns the user's keys, including revoked ones, oldest first
+	ListByUsername(ctx context.Context, username string) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, id string, at time.Time) error
+}
+
+type apiKeyStorage struct {
+	sync.RWMutex
+	keys   map[string]*models.APIKey
+	byHash map[string]string
+}
+
+func NewAPIKeyStorage() *apiKeyStorage {
+	return &apiKeyStorage{
+		keys:   make(map[string]*models.APIKey),
+		byHash: make(map[string]string),
+	}
+}
+
+func (s *apiKeyStorage) Create(ctx context.Context, key *models.APIKey) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.byHash[key.Hash]; ok {
+		return ErrAPIKeyExists
+	}
+	s.keys[key.ID.String()] = copyAPIKey(key)
+	s.byHash[key.Hash] = key.ID.String()
+	return nil
+}
+
+func (s *apiKeyStorage) FindByID(ctx context.Context, id string) (*models.APIKey, error) {
+	s.RLock()
+	defer s.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return copyAPIKey(key), nil
+}
+
+func (s *apiKeyStorage) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	s.RLock()
+	defer s.RUnlock()
+	id, ok := s.byHash[hash]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return copyAPIKey(s.keys[id]), nil
+}
+
+func (s *apiKeyStorage) ListByUsername(ctx context.Context, username string) ([]*models.APIKey, error) {
+	s.RLock()
+	defer s.RUnlock()
+	keys := make([]*models.APIKey, 0)
+	for _, key := range s.keys {
+		if key.Username == username {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(*keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (s *apiKeyStorage) Revoke(ctx context.Context, id string, at time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+	}
+	return nil
+}
+
+func copyAPIKey(key *models.APIKey) *models.APIKey {
+	copied := *key
+	copied.Scopes = append([]models.APIKeyScope(nil), key.Scopes...)
+	return &copied
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyStorage(t *testing.T) {
+	backends := []struct {
+		name    string
+		storage func(t *testing.T) APIKeyStorage
+	}{
+		{
+			name:    "memory",
+			storage: func(t *testing.T) APIKeyStorage { return NewAPIKeyStorage() },
+		},
+		{
+			name: "sqlite",
+			storage: func(t *testing.T) APIKeyStorage {
+				db, _ := newTestSQLiteDB(t)
+				return NewSQLiteAPIKeyStorage(db)
+			},
+		},
+	}
+
+	newKey := func(username string, hash string, createdAt time.Time) *models.APIKey {
+		return &models.APIKey{
+			ID:        uuid.New(),
+			Name:      "ingest",
+			Username:  username,
+			Prefix:    "tds_abcdefgh",
+			Hash:      hash,
+			Scopes:    []models.APIKeyScope{models.ScopeReadTrails, models.ScopeWriteTrails},
+			CreatedAt: &createdAt,
+		}
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			storage := backend.storage(t)
+			ctx := context.Background()
+			now := time.Now().UTC()
+
+			first := newKey("ranger", "hash-1", now)
+			second := newKey("ranger", "hash-2", now.Add(time.Second))
+			other := newKey("hiker", "hash-3", now)
+			for _, key := range []*models.APIKey{first, second, other} {
+				require.NoError(t, storage.Create(ctx, key))
+			}
+			assert.EqualError(t, storage.Create(ctx, newKey("ranger", "hash-1", now)), "api key already exists")
+
+			found, err := storage.FindByHash(ctx, "hash-1")
+			require.NoError(t, err)
+			assert.Equal(t, first.ID, found.ID)
+			assert.Equal(t, first.Scopes, found.Scopes)
+			assert.True(t, now.Equal(*found.CreatedAt))
+			assert.False(t, found.IsRevoked())
+
+			keys, err := storage.ListByUsername(ctx, "ranger")
+			require.NoError(t, err)
+			require.Len(t, keys, 2)
+			assert.Equal(t, first.ID, keys[0].ID)
+			assert.Equal(t, second.ID, keys[1].ID)
+
+			require.NoError(t, storage.Revoke(ctx, first.ID.String(), now))
+			// Revoking again keeps the original time
+			require.NoError(t, storage.Revoke(ctx, first.ID.String(), now.Add(time.Hour)))
+			found, err = storage.FindByID(ctx, first.ID.String())
+			require.NoError(t, err)
+			require.True(t, found.IsRevoked())
+			assert.True(t, now.Equal(*found.RevokedAt))
+
+			_, err = storage.FindByHash(ctx, "unknown")
+			assert.EqualError(t, err, "api key not found")
+			_, err = storage.FindByID(ctx, uuid.New().String())
+			assert.EqualError(t, err, "api key not found")
+			assert.EqualError(t, storage.Revoke(ctx, uuid.New().String(), now), "api key not found")
+		})
+	}
+}
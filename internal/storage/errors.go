@@ -0,0 +1,13 @@
+package storage
+
+import "github.com/dnakolan/trail-data-service/internal/models"
+
+// Errors returned by every storage backend, they match models.ErrNotFound or models.ErrDuplicate with errors.Is
+var (
+	ErrTrailNotFound        = models.Errorf(models.ErrNotFound, "trail not found")
+	ErrUserNotFound         = models.Errorf(models.ErrNotFound, "user not found")
+	ErrUserExists           = models.Errorf(models.ErrDuplicate, "user already exists")
+	ErrRefreshTokenNotFound = models.Errorf(models.ErrNotFound, "refresh token not found")
+	ErrAPIKeyNotFound       = models.Errorf(models.ErrNotFound, "api key not found")
+	ErrAPIKeyExists         = models.Errorf(models.ErrDuplicate, "api key already exists")
+)
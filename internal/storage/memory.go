@@ -2,7 +2,6 @@ package storage
 
 import (
 	"context"
-	"errors"
 	"sync"
 
 	"github.com/dnakolan/trail-data-service/internal/models"
@@ -11,6 +10,8 @@ import (
 type TrailStorage interface {
 	Save(ctx context.Context, trail *models.Trail) error
 	FindAll(ctx context.Context, filter *models.TrailFilter) ([]*models.Trail, error)
+	// Count ignores the filter's cursor and limit
+	Count(ctx context.Context, filter *models.TrailFilter) (int, error)
 	FindById(ctx context.Context, uid string) (*models.Trail, error)
 	Delete(ctx context.Context, uid string) error
 	Clear(ctx context.Context) error
@@ -18,12 +19,16 @@ type TrailStorage interface {
 
 type trailStorage struct {
 	sync.RWMutex
-	data map[string]*models.Trail
+	data    map[string]*models.Trail
+	spatial *spatialIndex
+	text    *textIndex
 }
 
 func NewTrailStorage() *trailStorage {
 	return &trailStorage{
-		data: make(map[string]*models.Trail),
+		data:    make(map[string]*models.Trail),
+		spatial: newSpatialIndex(defaultCellSizeDeg),
+		text:    newTextIndex(),
 	}
 }
 
@@ -31,19 +36,72 @@ func (s *trailStorage) Save(ctx context.Context, trail *models.Trail) error {
 	s.Lock()
 	defer s.Unlock()
 	s.data[trail.UID.String()] = trail
+	s.spatial.insert(trail)
+	s.text.insert(trail)
 	return nil
 }
 
 func (s *trailStorage) FindAll(ctx context.Context, filter *models.TrailFilter) ([]*models.Trail, error) {
 	s.RLock()
 	defer s.RUnlock()
+	return filter.Paginate(s.matching(filter)), nil
+}
+
+func (s *trailStorage) Count(ctx context.Context, filter *models.TrailFilter) (int, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.matching(filter)), nil
+}
+
+// matching must be called with the lock held
+func (s *trailStorage) matching(filter *models.TrailFilter) []*models.Trail {
+	if filter != nil && filter.Nearest != nil {
+		trails, _ := findNearest(filter, func(circle *models.TrailFilter) ([]*models.Trail, error) {
+			return s.matching(circle), nil
+		})
+		return trails
+	}
+
+	if filter != nil && filter.Lat != nil && filter.Lon != nil && filter.RadiusKm != nil {
+		trails := make([]*models.Trail, 0)
+		s.spatial.candidatesWithin(*filter.Lat, *filter.Lon, *filter.RadiusKm, func(trail *models.Trail) {
+			if trail.MatchesFilter(filter) {
+				trails = append(trails, trail)
+			}
+		})
+		return trails
+	}
+	if filter != nil && (filter.BBox != nil || filter.Area != nil) {
+		box := filter.BBox
+		if box == nil {
+			bounds := filter.Area.Bounds()
+			box = &bounds
+		}
+		trails := make([]*models.Trail, 0)
+		s.spatial.candidatesInBBox(*box, func(trail *models.Trail) {
+			if trail.MatchesFilter(filter) {
+				trails = append(trails, trail)
+			}
+		})
+		return trails
+	}
+	if filter != nil && filter.Query != nil {
+		trails := make([]*models.Trail, 0)
+		s.text.candidates(filter.Query, func(trail *models.Trail) {
+			if trail.MatchesFilter(filter) {
+				trails = append(trails, trail)
+			}
+		})
+		return trails
+	}
+
 	trails := make([]*models.Trail, 0, len(s.data))
 	for _, trail := range s.data {
 		if filter == nil || trail.MatchesFilter(filter) {
 			trails = append(trails, trail)
 		}
 	}
-	return trails, nil
+	return trails
 }
 
 func (s *trailStorage) FindById(ctx context.Context, uid string) (*models.Trail, error) {
@@ -51,7 +109,7 @@ func (s *trailStorage) FindById(ctx context.Context, uid string) (*models.Trail,
 	defer s.RUnlock()
 	trail, ok := s.data[uid]
 	if !ok {
-		return nil, errors.New("trail not found")
+		return nil, ErrTrailNotFound
 	}
 	return trail, nil
 }
@@ -59,7 +117,12 @@ func (s *trailStorage) FindById(ctx context.Context, uid string) (*models.Trail,
 func (s *trailStorage) Delete(ctx context.Context, uid string) error {
 	s.Lock()
 	defer s.Unlock()
+	if _, ok := s.data[uid]; !ok {
+		return ErrTrailNotFound
+	}
 	delete(s.data, uid)
+	s.spatial.remove(uid)
+	s.text.remove(uid)
 	return nil
 }
 
@@ -67,5 +130,7 @@ func (s *trailStorage) Clear(ctx context.Context) error {
 	s.Lock()
 	defer s.Unlock()
 	s.data = make(map[string]*models.Trail)
+	s.spatial = newSpatialIndex(defaultCellSizeDeg)
+	s.text = newTextIndex()
 	return nil
 }
@@ -2,6 +2,9 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"math/rand"
 	"testing"
 	"time"
 
@@ -178,6 +181,687 @@ func TestTrailStorage_FindAll(t *testing.T) {
 	}
 }
 
+func TestTrailStorage_Delete(t *testing.T) {
+	storage := NewTrailStorage()
+	ctx := context.Background()
+	now := time.Now()
+
+	trail := models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53)
+	trail.CreatedAt = &now
+	require.NoError(t, storage.Save(ctx, trail))
+
+	tests := []struct {
+		name          string
+		uid           string
+		expectError   bool
+		expectedError string
+	}{
+		{
+			name:        "successful deletion",
+			uid:         trail.UID.String(),
+			expectError: false,
+		},
+		{
+			name:          "already deleted",
+			uid:           trail.UID.String(),
+			expectError:   true,
+			expectedError: "trail not found",
+		},
+		{
+			name:          "trail not found",
+			uid:           uuid.New().String(),
+			expectError:   true,
+			expectedError: "trail not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storage.Delete(ctx, tt.uid)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Equal(t, tt.expectedError, err.Error())
+			} else {
+				assert.NoError(t, err)
+				_, err := storage.FindById(ctx, tt.uid)
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestTrailStorage_FindAllSpatialIndex(t *testing.T) {
+	storage := NewTrailStorage()
+	ctx := context.Background()
+
+	fiji := models.NewTrail("Lavena Coastal Walk", -16.8700, 179.9900, models.TrailDifficultyEasy, 5)
+	taveuni := models.NewTrail("Des Voeux Peak", -16.8500, -179.9800, models.TrailDifficultyHard, 8)
+	moved := models.NewTrail("Angel's Rest", 44.8472, -109.6278, models.TrailDifficultyMedium, 10)
+	for _, trail := range []*models.Trail{fiji, taveuni, moved} {
+		require.NoError(t, storage.Save(ctx, trail))
+	}
+
+	// Saving with new coordinates must move the trail to its new cell
+	moved.Lat = float64Ptr(45.6789)
+	moved.Lon = float64Ptr(-122.3456)
+	require.NoError(t, storage.Save(ctx, moved))
+
+	tests := []struct {
+		name          string
+		lat           float64
+		lon           float64
+		radiusKm      float64
+		expectedNames []string
+	}{
+		{
+			name:          "across the antimeridian from the east",
+			lat:           -16.86,
+			lon:           179.99,
+			radiusKm:      10,
+			expectedNames: []string{"Lavena Coastal Walk", "Des Voeux Peak"},
+		},
+		{
+			name:          "across the antimeridian from the west",
+			lat:           -16.86,
+			lon:           -179.99,
+			radiusKm:      10,
+			expectedNames: []string{"Lavena Coastal Walk", "Des Voeux Peak"},
+		},
+		{
+			name:          "moved trail found at new location",
+			lat:           45.6789,
+			lon:           -122.3456,
+			radiusKm:      1,
+			expectedNames: []string{"Angel's Rest"},
+		},
+		{
+			name:          "moved trail not found at old location",
+			lat:           44.8472,
+			lon:           -109.6278,
+			radiusKm:      1,
+			expectedNames: []string{},
+		},
+		{
+			name:          "radius covering the whole globe",
+			lat:           0,
+			lon:           0,
+			radiusKm:      25000,
+			expectedNames: []string{"Lavena Coastal Walk", "Des Voeux Peak", "Angel's Rest"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := storage.FindAll(ctx, &models.TrailFilter{
+				CreateTrailRequest: models.CreateTrailRequest{
+					Lat: float64Ptr(tt.lat),
+					Lon: float64Ptr(tt.lon),
+				},
+				RadiusKm: float64Ptr(tt.radiusKm),
+			})
+			require.NoError(t, err)
+
+			names := make([]string, len(found))
+			for i, trail := range found {
+				names[i] = *trail.Name
+			}
+			assert.ElementsMatch(t, tt.expectedNames, names)
+		})
+	}
+
+	// Deleted trails must no longer be returned from the index
+	require.NoError(t, storage.Delete(ctx, fiji.UID.String()))
+	found, err := storage.FindAll(ctx, &models.TrailFilter{
+		CreateTrailRequest: models.CreateTrailRequest{
+			Lat: float64Ptr(-16.86),
+			Lon: float64Ptr(179.99),
+		},
+		RadiusKm: float64Ptr(10),
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "Des Voeux Peak", *found[0].Name)
+}
+
+func TestTrailStorage_FindAllSpatialIndexCellEdge(t *testing.T) {
+	storage := NewTrailStorage()
+	ctx := context.Background()
+	const lat, radiusKm = 70.0, 100.0
+
+	// Far north the circle reaches further east than its radius at the center's latitude suggests
+	naiveReach := radiusKm / (111.32 * math.Cos(lat*math.Pi/180))
+	var trailLat, trailReach float64
+	for bearing := 80.0; bearing <= 100; bearing += 0.01 {
+		if pointLat, pointLon := destination(lat, 0, radiusKm*0.9999, bearing); pointLon > trailReach {
+			trailLat, trailReach = pointLat, pointLon
+		}
+	}
+	require.Greater(t, trailReach, naiveReach)
+
+	// Center the circle so the naive box stops just short of a cell boundary the trail lies just past
+	boundary := 11 * defaultCellSizeDeg
+	lon := boundary - (naiveReach+trailReach)/2
+	trail := models.NewTrail("Kapp Linné", trailLat, lon+trailReach, models.TrailDifficultyEasy, 3)
+	require.NoError(t, storage.Save(ctx, trail))
+
+	found, err := storage.FindAll(ctx, &models.TrailFilter{
+		CreateTrailRequest: models.CreateTrailRequest{
+			Lat: float64Ptr(lat),
+			Lon: float64Ptr(lon),
+		},
+		RadiusKm: float64Ptr(radiusKm),
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, trail.UID, found[0].UID)
+}
+
+// destination returns the point distanceKm from lat/lon setting off on the bearing along a great circle
+func destination(lat, lon, distanceKm, bearingDeg float64) (float64, float64) {
+	lat1, lon1 := lat*math.Pi/180, lon*math.Pi/180
+	bearing := bearingDeg * math.Pi / 180
+	angle := distanceKm / 6371
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(angle) + math.Cos(lat1)*math.Sin(angle)*math.Cos(bearing))
+	lon2 := lon1 + math.Atan2(math.Sin(bearing)*math.Sin(angle)*math.Cos(lat1), math.Cos(angle)-math.Sin(lat1)*math.Sin(lat2))
+	return lat2 * 180 / math.Pi, math.Remainder(lon2*180/math.Pi, 360)
+}
+
+// trailStorageBackends are the TrailStorage implementations that tests run against alike
+var trailStorageBackends = []struct {
+	name    string
+	storage func(t *testing.T) TrailStorage
+}{
+	{
+		name:    "memory",
+		storage: func(t *testing.T) TrailStorage { return NewTrailStorage() },
+	},
+	{
+		name: "sqlite",
+		storage: func(t *testing.T) TrailStorage {
+			storage, _ := newTestSQLiteStorage(t)
+			return storage
+		},
+	},
+}
+
+func TestTrailStorage_FindAllRadiusEdge(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat      float64
+		lon      float64
+		radiusKm float64
+	}{
+		{name: "near the equator", lat: 0.1012, lon: 0, radiusKm: 100},
+		{name: "at the equator", lat: 0, lon: 0, radiusKm: 25},
+		{name: "mid latitude", lat: 44.8472, lon: -109.6278, radiusKm: 50},
+		{name: "high latitude", lat: 78.2232, lon: 15.6267, radiusKm: 300},
+		{name: "high southern latitude across the antimeridian", lat: -77.8419, lon: 179.9, radiusKm: 100},
+	}
+
+	for _, backend := range trailStorageBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					storage := backend.storage(t)
+
+					// Trails all round the circle, just inside and just outside it
+					var expectedNames []string
+					for bearing := 0.0; bearing < 360; bearing += 10 {
+						lat, lon := destination(tt.lat, tt.lon, tt.radiusKm*0.9999, bearing)
+						inside := models.NewTrail(fmt.Sprintf("inside %v", bearing), lat, lon, models.TrailDifficultyEasy, 1)
+						lat, lon = destination(tt.lat, tt.lon, tt.radiusKm*1.0001, bearing)
+						outside := models.NewTrail(fmt.Sprintf("outside %v", bearing), lat, lon, models.TrailDifficultyEasy, 1)
+						require.NoError(t, storage.Save(ctx, inside))
+						require.NoError(t, storage.Save(ctx, outside))
+						expectedNames = append(expectedNames, *inside.Name)
+					}
+
+					found, err := storage.FindAll(ctx, &models.TrailFilter{
+						CreateTrailRequest: models.CreateTrailRequest{
+							Lat: float64Ptr(tt.lat),
+							Lon: float64Ptr(tt.lon),
+						},
+						RadiusKm: float64Ptr(tt.radiusKm),
+					})
+					require.NoError(t, err)
+
+					names := make([]string, len(found))
+					for i, trail := range found {
+						names[i] = *trail.Name
+					}
+					assert.ElementsMatch(t, expectedNames, names)
+				})
+			}
+		})
+	}
+}
+
+func TestTrailStorage_FindAllInArea(t *testing.T) {
+	ring := func(minLon, minLat, maxLon, maxLat float64) models.Ring {
+		return models.Ring{{minLon, minLat}, {maxLon, minLat}, {maxLon, maxLat}, {minLon, maxLat}, {minLon, minLat}}
+	}
+
+	tests := []struct {
+		name          string
+		filter        *models.TrailFilter
+		expectedNames []string
+	}{
+		{
+			name:          "bbox",
+			filter:        &models.TrailFilter{BBox: &models.BBox{MinLon: -123, MinLat: 45, MaxLon: -122, MaxLat: 46}},
+			expectedNames: []string{"Angel's Rest"},
+		},
+		{
+			name:          "bbox across the antimeridian",
+			filter:        &models.TrailFilter{BBox: &models.BBox{MinLon: 179, MinLat: -17, MaxLon: -179, MaxLat: -16}},
+			expectedNames: []string{"Lavena Coastal Walk", "Des Voeux Peak"},
+		},
+		{
+			name: "polygon with a hole",
+			filter: &models.TrailFilter{Area: &models.Area{Polygons: []models.Polygon{
+				{ring(-123, 43, -121, 46), ring(-121.7, 43.7, -121.4, 43.9)},
+			}}},
+			expectedNames: []string{"Angel's Rest"},
+		},
+		{
+			name: "multipolygon split at the antimeridian",
+			filter: &models.TrailFilter{Area: &models.Area{Polygons: []models.Polygon{
+				{ring(179, -17, 180, -16)},
+				{ring(-180, -17, -179, -16)},
+			}}},
+			expectedNames: []string{"Lavena Coastal Walk", "Des Voeux Peak"},
+		},
+		{
+			name: "bbox and polygon together",
+			filter: &models.TrailFilter{
+				BBox: &models.BBox{MinLon: -122, MinLat: 43, MaxLon: -121, MaxLat: 44},
+				Area: &models.Area{Polygons: []models.Polygon{{ring(-123, 43, -121, 46)}}},
+			},
+			expectedNames: []string{"Trail of Ten Falls"},
+		},
+	}
+
+	for _, backend := range trailStorageBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := backend.storage(t)
+			for _, trail := range []*models.Trail{
+				models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53),
+				models.NewTrail("Trail of Ten Falls", 43.8242, -121.5654, models.TrailDifficultyMedium, 10),
+				models.NewTrail("Angel's Rest", 45.6789, -122.3456, models.TrailDifficultyMedium, 10),
+				models.NewTrail("Lavena Coastal Walk", -16.8700, 179.9900, models.TrailDifficultyEasy, 5),
+				models.NewTrail("Des Voeux Peak", -16.8500, -179.9800, models.TrailDifficultyHard, 8),
+			} {
+				require.NoError(t, storage.Save(ctx, trail))
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					found, err := storage.FindAll(ctx, tt.filter)
+					require.NoError(t, err)
+
+					names := make([]string, len(found))
+					for i, trail := range found {
+						names[i] = *trail.Name
+					}
+					assert.ElementsMatch(t, tt.expectedNames, names)
+				})
+			}
+		})
+	}
+}
+
+func TestTrailStorage_FindAllNearest(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	hard := models.TrailDifficultyHard
+	nameSort := models.TrailSortByName
+	tests := []struct {
+		name          string
+		filter        *models.TrailFilter
+		expectedNames []string
+	}{
+		{
+			name:          "closest first",
+			filter:        &models.TrailFilter{Nearest: intPtr(3)},
+			expectedNames: []string{"Next Door", "Down the Road", "Over the Hill"},
+		},
+		{
+			name:          "more than are stored",
+			filter:        &models.TrailFilter{Nearest: intPtr(10)},
+			expectedNames: []string{"Next Door", "Down the Road", "Over the Hill", "Far Away", "Lavena Coastal Walk"},
+		},
+		{
+			name:          "within a radius",
+			filter:        &models.TrailFilter{Nearest: intPtr(10), RadiusKm: float64Ptr(100)},
+			expectedNames: []string{"Next Door", "Down the Road"},
+		},
+		{
+			name: "with other filters",
+			filter: &models.TrailFilter{
+				CreateTrailRequest: models.CreateTrailRequest{Difficulty: &hard},
+				Nearest:            intPtr(1),
+			},
+			expectedNames: []string{"Far Away"},
+		},
+		{
+			name: "sorted by another field",
+			filter: &models.TrailFilter{
+				Nearest: intPtr(2),
+				SortBy:  &nameSort,
+			},
+			expectedNames: []string{"Down the Road", "Next Door"},
+		},
+	}
+
+	for _, backend := range trailStorageBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := backend.storage(t)
+			for _, trail := range []*models.Trail{
+				models.NewTrail("Lavena Coastal Walk", -16.87, 179.99, models.TrailDifficultyEasy, 5),
+				models.NewTrail("Far Away", 55, -122, models.TrailDifficultyHard, 10),
+				models.NewTrail("Over the Hill", 47, -122, models.TrailDifficultyMedium, 10),
+				models.NewTrail("Down the Road", 45.5, -122, models.TrailDifficultyEasy, 10),
+				models.NewTrail("Next Door", 45.1, -122, models.TrailDifficultyEasy, 10),
+			} {
+				require.NoError(t, storage.Save(ctx, trail))
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.filter.Lat = float64Ptr(45)
+					tt.filter.Lon = float64Ptr(-122)
+					require.NoError(t, tt.filter.Validate())
+
+					found, err := storage.FindAll(ctx, tt.filter)
+					require.NoError(t, err)
+					names := make([]string, len(found))
+					for i, trail := range found {
+						names[i] = *trail.Name
+					}
+					assert.Equal(t, tt.expectedNames, names)
+
+					count, err := storage.Count(ctx, tt.filter)
+					require.NoError(t, err)
+					assert.Equal(t, len(tt.expectedNames), count)
+				})
+			}
+
+			// The closest trail to a point in Fiji is across the antimeridian
+			found, err := storage.FindAll(ctx, &models.TrailFilter{
+				CreateTrailRequest: models.CreateTrailRequest{Lat: float64Ptr(-16.8), Lon: float64Ptr(-179.9)},
+				Nearest:            intPtr(1),
+			})
+			require.NoError(t, err)
+			require.Len(t, found, 1)
+			assert.Equal(t, "Lavena Coastal Walk", *found[0].Name)
+		})
+	}
+}
+
+func TestTrailStorage_FindAllRanges(t *testing.T) {
+	date := func(day int) *time.Time {
+		d := time.Date(2024, 6, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	tests := []struct {
+		name          string
+		filter        *models.TrailFilter
+		expectedNames []string
+	}{
+		{
+			name:          "several difficulties",
+			filter:        &models.TrailFilter{Difficulties: []models.TrailDifficulty{models.TrailDifficultyEasy, models.TrailDifficultyMedium}},
+			expectedNames: []string{"Angel's Rest", "Trail of Ten Falls"},
+		},
+		{
+			name:          "length range",
+			filter:        &models.TrailFilter{MinLengthKm: float64Ptr(7.8), MaxLengthKm: float64Ptr(12.5)},
+			expectedNames: []string{"Angel's Rest", "Trail of Ten Falls"},
+		},
+		{
+			name:          "min length only",
+			filter:        &models.TrailFilter{MinLengthKm: float64Ptr(10)},
+			expectedNames: []string{"Lamar River Trail", "Trail of Ten Falls"},
+		},
+		{
+			name:          "created range",
+			filter:        &models.TrailFilter{CreatedAfter: date(2), CreatedBefore: date(3)},
+			expectedNames: []string{"Trail of Ten Falls"},
+		},
+		{
+			name:          "created after only",
+			filter:        &models.TrailFilter{CreatedAfter: date(2)},
+			expectedNames: []string{"Angel's Rest", "Trail of Ten Falls"},
+		},
+		{
+			name: "combined",
+			filter: &models.TrailFilter{
+				Difficulties: []models.TrailDifficulty{models.TrailDifficultyMedium, models.TrailDifficultyHard},
+				MaxLengthKm:  float64Ptr(20),
+				CreatedAfter: date(1),
+			},
+			expectedNames: []string{"Angel's Rest"},
+		},
+	}
+
+	for _, backend := range trailStorageBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := backend.storage(t)
+			for i, trail := range []*models.Trail{
+				models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53),
+				models.NewTrail("Trail of Ten Falls", 43.8242, -121.5654, models.TrailDifficultyEasy, 12.5),
+				models.NewTrail("Angel's Rest", 45.6789, -122.3456, models.TrailDifficultyMedium, 7.8),
+			} {
+				trail.CreatedAt = date(i + 1)
+				require.NoError(t, storage.Save(ctx, trail))
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					found, err := storage.FindAll(ctx, tt.filter)
+					require.NoError(t, err)
+					names := make([]string, len(found))
+					for i, trail := range found {
+						names[i] = *trail.Name
+					}
+					assert.ElementsMatch(t, tt.expectedNames, names)
+				})
+			}
+		})
+	}
+}
+
+func TestTrailStorage_FindAllText(t *testing.T) {
+	names := func(trails []*models.Trail) []string {
+		found := make([]string, len(trails))
+		for i, trail := range trails {
+			found[i] = *trail.Name
+		}
+		return found
+	}
+
+	for _, backend := range trailStorageBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := backend.storage(t)
+			renamed := models.NewTrail("Slough Creek", 44.9467, -110.3066, models.TrailDifficultyEasy, 18)
+			deleted := models.NewTrail("Lamar River", 44.9, -109.7, models.TrailDifficultyEasy, 5)
+			for _, trail := range []*models.Trail{
+				models.NewTrail("Lamar River Cutoff Trail", 44.85, -109.63, models.TrailDifficultyHard, 20),
+				models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53),
+				models.NewTrail("Río Lamar Overlook", 44.8, -109.6, models.TrailDifficultyEasy, 1),
+				models.NewTrail("Trail of Ten Falls", 43.8242, -121.5654, models.TrailDifficultyMedium, 10),
+				renamed,
+				deleted,
+			} {
+				require.NoError(t, storage.Save(ctx, trail))
+			}
+
+			// The index follows renames and deletes
+			renamed.Name = stringPtr("Lamar Valley Loop")
+			require.NoError(t, storage.Save(ctx, renamed))
+			require.NoError(t, storage.Delete(ctx, deleted.UID.String()))
+
+			found, err := storage.FindAll(ctx, &models.TrailFilter{Query: models.NewTextQuery("lamar river", false)})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"Lamar River Trail", "Lamar River Cutoff Trail"}, names(found))
+
+			found, err = storage.FindAll(ctx, &models.TrailFilter{Query: models.NewTextQuery("LAMR", false)})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"Lamar River Trail", "Lamar River Cutoff Trail", "Río Lamar Overlook", "Lamar Valley Loop"}, names(found))
+
+			found, err = storage.FindAll(ctx, &models.TrailFilter{Query: models.NewTextQuery("rio", false)})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"Río Lamar Overlook"}, names(found))
+
+			found, err = storage.FindAll(ctx, &models.TrailFilter{Query: models.NewTextQuery("slough", false)})
+			require.NoError(t, err)
+			assert.Empty(t, found)
+
+			found, err = storage.FindAll(ctx, &models.TrailFilter{Query: models.NewTextQuery("lamar val", true)})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"Lamar Valley Loop"}, names(found))
+
+			difficulty := models.TrailDifficultyEasy
+			found, err = storage.FindAll(ctx, &models.TrailFilter{
+				CreateTrailRequest: models.CreateTrailRequest{Difficulty: &difficulty},
+				Query:              models.NewTextQuery("lamar", false),
+			})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"Río Lamar Overlook", "Lamar Valley Loop"}, names(found))
+
+			count, err := storage.Count(ctx, &models.TrailFilter{Query: models.NewTextQuery("unknown words", false)})
+			require.NoError(t, err)
+			assert.Zero(t, count)
+		})
+	}
+}
+
+// seedBenchmarkStorage fills a storage with trails spread across the continental US
+func seedBenchmarkStorage(b *testing.B, count int) *trailStorage {
+	storage := NewTrailStorage()
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	difficulties := []models.TrailDifficulty{models.TrailDifficultyEasy, models.TrailDifficultyMedium, models.TrailDifficultyHard}
+
+	for i := 0; i < count; i++ {
+		trail := models.NewTrail(
+			fmt.Sprintf("Trail %d", i),
+			25+rng.Float64()*24,
+			-125+rng.Float64()*58,
+			difficulties[i%len(difficulties)],
+			1+rng.Float64()*40,
+		)
+		if err := storage.Save(ctx, trail); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return storage
+}
+
+func benchmarkRadiusFilter(radiusKm float64) *models.TrailFilter {
+	return &models.TrailFilter{
+		CreateTrailRequest: models.CreateTrailRequest{
+			Lat: float64Ptr(44.8472),
+			Lon: float64Ptr(-109.6278),
+		},
+		RadiusKm: float64Ptr(radiusKm),
+	}
+}
+
+func BenchmarkTrailStorage_FindAllRadius(b *testing.B) {
+	for _, count := range []int{100_000, 250_000} {
+		storage := seedBenchmarkStorage(b, count)
+		ctx := context.Background()
+
+		for _, radiusKm := range []float64{25, 100} {
+			filter := benchmarkRadiusFilter(radiusKm)
+
+			b.Run(fmt.Sprintf("indexed/trails=%d/radius=%.0fkm", count, radiusKm), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := storage.FindAll(ctx, filter); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+
+			// Baseline of the previous behaviour, a haversine check against every stored trail
+			b.Run(fmt.Sprintf("fullscan/trails=%d/radius=%.0fkm", count, radiusKm), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					storage.RLock()
+					trails := make([]*models.Trail, 0)
+					for _, trail := range storage.data {
+						if trail.MatchesFilter(filter) {
+							trails = append(trails, trail)
+						}
+					}
+					storage.RUnlock()
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkTrailStorage_FindAllNearest(b *testing.B) {
+	storage := seedBenchmarkStorage(b, 100_000)
+	ctx := context.Background()
+
+	for _, k := range []int{1, 10, 100} {
+		nearest := k
+		filter := &models.TrailFilter{
+			CreateTrailRequest: models.CreateTrailRequest{
+				Lat: float64Ptr(44.8472),
+				Lon: float64Ptr(-109.6278),
+			},
+			Nearest: &nearest,
+		}
+
+		b.Run(fmt.Sprintf("k=%d", k), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.FindAll(ctx, filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTrailStorage_DuplicateCheck(b *testing.B) {
+	storage := seedBenchmarkStorage(b, 100_000)
+	ctx := context.Background()
+
+	// Mirrors the lookup trailsService.CreateTrail performs before every insert
+	filter := benchmarkRadiusFilter(25)
+	filter.Name = stringPtr("Trail 42")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := storage.FindAll(ctx, filter); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTrailStorage_Save(b *testing.B) {
+	storage := seedBenchmarkStorage(b, 100_000)
+	ctx := context.Background()
+	trail := models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lat := 25 + float64(i%24)
+		trail.Lat = &lat
+		if err := storage.Save(ctx, trail); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 // Helper functions to create pointers
 func float64Ptr(v float64) *float64 {
 	return &v
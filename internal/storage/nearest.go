@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"math"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+)
+
+const (
+	nearestStartRadiusKm = 25
+	// maxSearchRadiusKm is half the earth's circumference
+	maxSearchRadiusKm = math.Pi * 6371
+)
+
+// findNearest doubles the circle searched until it holds enough matches, so only trails near the point are examined
+func findNearest(filter *models.TrailFilter, search func(*models.TrailFilter) ([]*models.Trail, error)) ([]*models.Trail, error) {
+	k := *filter.Nearest
+	maxRadiusKm := maxSearchRadiusKm
+	if filter.RadiusKm != nil {
+		maxRadiusKm = math.Min(*filter.RadiusKm, maxSearchRadiusKm)
+	}
+
+	circle := *filter
+	circle.Nearest = nil
+	var trails []*models.Trail
+	for radiusKm := math.Min(nearestStartRadiusKm, maxRadiusKm); ; radiusKm = math.Min(radiusKm*2, maxRadiusKm) {
+		circle.RadiusKm = &radiusKm
+		found, err := search(&circle)
+		if err != nil {
+			return nil, err
+		}
+		trails = found
+		if len(trails) >= k || radiusKm >= maxRadiusKm {
+			break
+		}
+	}
+
+	// Keep the k closest, ties broken by uid as in a distance sort
+	sortBy := models.TrailSortByDistance
+	closest := *filter
+	closest.SortBy = &sortBy
+	closest.SortDesc = false
+	closest.Cursor = nil
+	closest.Limit = &k
+	return closest.Paginate(trails), nil
+}
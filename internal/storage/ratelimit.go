@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+)
+
+// RateLimiter implementations shared between replicas must take from the bucket atomically
+type RateLimiter interface {
+	Take(ctx context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitResult, error)
+}
+
+type memoryBucket struct {
+	bucket models.TokenBucket
+	limit  models.RateLimit
+}
+
+type rateLimiter struct {
+	sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*memoryBucket)}
+}
+
+func (l *rateLimiter) Take(ctx context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitResult, error) {
+	l.Lock()
+	defer l.Unlock()
+	l.sweep(now)
+
+	entry, ok := l.buckets[key]
+	if !ok {
+		entry = &memoryBucket{bucket: models.NewTokenBucket(limit, now)}
+		l.buckets[key] = entry
+	}
+	entry.limit = limit
+	return entry.bucket.Take(limit, now), nil
+}
+
+// sweep forgets full buckets, callers must hold the lock
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, entry := range l.buckets {
+		if entry.bucket.IsFull(entry.limit, now) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewRateLimiter()
+	limit := models.RateLimit{Rate: 1, Burst: 1}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	result, err := limiter.Take(ctx, "reads:ip:10.0.0.1", limit, now)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = limiter.Take(ctx, "reads:ip:10.0.0.1", limit, now)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+
+	result, err = limiter.Take(ctx, "reads:ip:10.0.0.2", limit, now)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// Refilled buckets are forgotten once a sweep is due
+	_, err = limiter.Take(ctx, "reads:ip:10.0.0.3", limit, now.Add(sweepInterval))
+	require.NoError(t, err)
+	assert.Len(t, limiter.buckets, 1)
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"math"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+)
+
+// defaultCellSizeDeg gives cells of roughly 28km x 28km at the equator,
+// comparable to a precision 4 geohash and close to the duplicate check radius
+const defaultCellSizeDeg = 0.25
+
+// cellMarginDeg widens the cells searched around a circle, about a metre
+const cellMarginDeg = 1e-5
+
+type cellKey struct {
+	lat int
+	lon int
+}
+
+// spatialIndex is not safe for concurrent use, callers must hold the storage lock
+type spatialIndex struct {
+	cellSizeDeg float64
+	lonCells    int
+	cells       map[cellKey]map[string]*models.Trail
+	trailCells  map[string]cellKey
+}
+
+func newSpatialIndex(cellSizeDeg float64) *spatialIndex {
+	return &spatialIndex{
+		cellSizeDeg: cellSizeDeg,
+		lonCells:    int(math.Ceil(360 / cellSizeDeg)),
+		cells:       make(map[cellKey]map[string]*models.Trail),
+		trailCells:  make(map[string]cellKey),
+	}
+}
+
+func (i *spatialIndex) cellFor(lat, lon float64) cellKey {
+	latIdx := int(math.Floor((lat + 90) / i.cellSizeDeg))
+	lonIdx := int(math.Floor((lon + 180) / i.cellSizeDeg))
+	return cellKey{lat: latIdx, lon: ((lonIdx % i.lonCells) + i.lonCells) % i.lonCells}
+}
+
+func (i *spatialIndex) insert(trail *models.Trail) {
+	uid := trail.UID.String()
+	i.remove(uid)
+	if trail.Lat == nil || trail.Lon == nil {
+		return
+	}
+
+	key := i.cellFor(*trail.Lat, *trail.Lon)
+	cell, ok := i.cells[key]
+	if !ok {
+		cell = make(map[string]*models.Trail)
+		i.cells[key] = cell
+	}
+	cell[uid] = trail
+	i.trailCells[uid] = key
+}
+
+func (i *spatialIndex) remove(uid string) {
+	key, ok := i.trailCells[uid]
+	if !ok {
+		return
+	}
+	delete(i.cells[key], uid)
+	if len(i.cells[key]) == 0 {
+		delete(i.cells, key)
+	}
+	delete(i.trailCells, uid)
+}
+
+// candidatesWithin leaves the exact distance check to the caller
+func (i *spatialIndex) candidatesWithin(lat, lon, radiusKm float64, fn func(*models.Trail)) {
+	minLat, minLon, maxLat, maxLon := models.BoundingBox(lat, lon, radiusKm)
+	// Widen the box a little so a circle ending right on a cell boundary still takes in the next cell
+	i.candidatesInBox(minLat-cellMarginDeg, minLon-cellMarginDeg, maxLat+cellMarginDeg, maxLon+cellMarginDeg, fn)
+}
+
+func (i *spatialIndex) candidatesInBBox(box models.BBox, fn func(*models.Trail)) {
+	maxLon := box.MaxLon
+	if box.CrossesAntimeridian() {
+		maxLon += 360
+	}
+	i.candidatesInBox(box.MinLat, box.MinLon, box.MaxLat, maxLon, fn)
+}
+
+// candidatesInBox takes boxes across the antimeridian with maxLon above 180
+func (i *spatialIndex) candidatesInBox(minLat, minLon, maxLat, maxLon float64, fn func(*models.Trail)) {
+	minLatIdx := i.cellFor(minLat, 0).lat
+	maxLatIdx := i.cellFor(maxLat, 0).lat
+
+	var lonRanges [][2]int
+	if maxLon-minLon >= 360-i.cellSizeDeg {
+		lonRanges = [][2]int{{0, i.lonCells - 1}}
+	} else {
+		// Ranges that wrap across the antimeridian come back with the start after the end
+		start := i.cellFor(0, minLon).lon
+		end := i.cellFor(0, maxLon).lon
+		if start <= end {
+			lonRanges = [][2]int{{start, end}}
+		} else {
+			lonRanges = [][2]int{{start, i.lonCells - 1}, {0, end}}
+		}
+	}
+
+	cellCount := 0
+	for _, r := range lonRanges {
+		cellCount += (r[1] - r[0] + 1) * (maxLatIdx - minLatIdx + 1)
+	}
+
+	// Very large areas cover more grid cells than are populated, walk the occupied cells instead
+	if cellCount > len(i.cells) {
+		for key, cell := range i.cells {
+			if key.lat < minLatIdx || key.lat > maxLatIdx || !inLonRanges(key.lon, lonRanges) {
+				continue
+			}
+			for _, trail := range cell {
+				fn(trail)
+			}
+		}
+		return
+	}
+
+	for latIdx := minLatIdx; latIdx <= maxLatIdx; latIdx++ {
+		for _, r := range lonRanges {
+			for lonIdx := r[0]; lonIdx <= r[1]; lonIdx++ {
+				for _, trail := range i.cells[cellKey{lat: latIdx, lon: lonIdx}] {
+					fn(trail)
+				}
+			}
+		}
+	}
+}
+
+func inLonRanges(lon int, ranges [][2]int) bool {
+	for _, r := range ranges {
+		if lon >= r[0] && lon <= r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,735 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// migrations are applied in order and tracked with PRAGMA user_version,
+// so new schema changes must only ever be appended
+var migrations = []string{
+	`CREATE TABLE trails (
+		uid        TEXT PRIMARY KEY,
+		name       TEXT,
+		lat        REAL,
+		lon        REAL,
+		difficulty TEXT,
+		length_km  REAL,
+		created_at TEXT
+	);
+	CREATE INDEX idx_trails_name ON trails (name);
+	CREATE INDEX idx_trails_lat_lon ON trails (lat, lon);`,
+	`ALTER TABLE trails ADD COLUMN track TEXT;
+	ALTER TABLE trails ADD COLUMN waypoints TEXT;`,
+	`ALTER TABLE trails ADD COLUMN elevation TEXT;`,
+	`ALTER TABLE trails ADD COLUMN difficulty_score REAL;`,
+	`CREATE TABLE users (
+		uid           TEXT PRIMARY KEY,
+		username      TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		created_at    TEXT,
+		failed_logins INTEGER NOT NULL DEFAULT 0,
+		locked_until  TEXT
+	);`,
+	// Token expiries are unix seconds so expired rows can be found by comparing numbers
+	`CREATE TABLE refresh_tokens (
+		hash       TEXT PRIMARY KEY,
+		family_id  TEXT NOT NULL,
+		username   TEXT NOT NULL,
+		expires_at INTEGER NOT NULL,
+		used_at    TEXT,
+		revoked    INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+	CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens (expires_at);
+	CREATE TABLE revoked_tokens (
+		jti        TEXT PRIMARY KEY,
+		expires_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);`,
+	// Accounts from before roles existed keep the role new registrations got at the time
+	`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'contributor';
+	ALTER TABLE trails ADD COLUMN created_by TEXT;`,
+	`ALTER TABLE trails ADD COLUMN updated_by TEXT;
+	CREATE INDEX idx_trails_created_by ON trails (created_by);`,
+	`CREATE TABLE api_keys (
+		id         TEXT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		username   TEXT NOT NULL,
+		prefix     TEXT NOT NULL,
+		hash       TEXT NOT NULL UNIQUE,
+		scopes     TEXT NOT NULL,
+		created_at TEXT,
+		revoked_at TEXT
+	);
+	CREATE INDEX idx_api_keys_username ON api_keys (username);`,
+	// Inverted index of the tokens in trail names for text search, filled for existing trails by backfillTrailTerms
+	`CREATE TABLE trail_terms (
+		token TEXT NOT NULL,
+		uid   TEXT NOT NULL,
+		PRIMARY KEY (token, uid)
+	);
+	CREATE INDEX idx_trail_terms_uid ON trail_terms (uid);`,
+	// Name and creation sort keys worked out in Go so listings can be ordered and paged in SQL exactly as in
+	// memory, filled for existing trails by backfillSortKeys
+	`ALTER TABLE trails ADD COLUMN sort_name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE trails ADD COLUMN sort_created_at TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_trails_sort_name ON trails (sort_name, uid);
+	CREATE INDEX idx_trails_sort_created_at ON trails (sort_created_at, uid);`,
+	// Difficulties rated before this was recorded count as given, as there is no telling them apart
+	`ALTER TABLE trails ADD COLUMN difficulty_rated INTEGER NOT NULL DEFAULT 0;`,
+	// Accounts from an identity provider are found by issuer and subject rather than by the username it sent
+	`ALTER TABLE users ADD COLUMN external_subject TEXT NOT NULL DEFAULT '';
+	CREATE UNIQUE INDEX idx_users_external_subject ON users (external_subject) WHERE external_subject <> '';`,
+}
+
+// migrationBackfills fill in data that needs Go code after the migration at the same index has run,
+// within the same transaction
+var migrationBackfills = map[int]func(tx *sql.Tx) error{
+	9:  backfillTrailTerms,
+	10: backfillSortKeys,
+}
+
+const trailColumns = "uid, name, lat, lon, difficulty, length_km, created_at, track, waypoints, elevation, difficulty_score, difficulty_rated, created_by, updated_by"
+
+// trailSummaryColumns skips decoding the track, waypoints and elevation when only a count is needed
+const trailSummaryColumns = "uid, name, lat, lon, difficulty, length_km, created_at, NULL, NULL, NULL, difficulty_score, difficulty_rated, created_by, updated_by"
+
+// sortKeyExprs match the keys models.TrailFilter.Paginate orders by, sorts missing here are ordered in Go
+var sortKeyExprs = map[models.TrailSortField]struct {
+	expr    string
+	numeric bool
+}{
+	models.TrailSortByName:      {expr: "sort_name"},
+	models.TrailSortByCreatedAt: {expr: "sort_created_at"},
+	models.TrailSortByLengthKm:  {expr: "COALESCE(length_km, 0)", numeric: true},
+	models.TrailSortByDifficulty: {
+		expr:    "CASE difficulty WHEN 'easy' THEN 1 WHEN 'medium' THEN 2 WHEN 'hard' THEN 3 ELSE 0 END",
+		numeric: true,
+	},
+}
+
+type sqliteTrailStorage struct {
+	db *sql.DB
+}
+
+func OpenSQLite(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only allows a single writer, serialise access through one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA journal_mode = WAL; PRAGMA busy_timeout = 5000;"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func NewSQLiteTrailStorage(db *sql.DB) *sqliteTrailStorage {
+	return &sqliteTrailStorage{db: db}
+}
+
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+		if backfill, ok := migrationBackfills[i]; ok {
+			if err := backfill(tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d backfill failed: %w", i+1, err)
+			}
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteTrailStorage) Save(ctx context.Context, trail *models.Trail) error {
+	track, err := marshalNullableJSON(trail.Track)
+	if err != nil {
+		return err
+	}
+	waypoints, err := marshalNullableJSON(trail.Waypoints)
+	if err != nil {
+		return err
+	}
+	elevation, err := marshalOptionalJSON(trail.Elevation)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO trails (`+trailColumns+`, sort_name, sort_created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (uid) DO UPDATE SET
+			name = excluded.name,
+			lat = excluded.lat,
+			lon = excluded.lon,
+			difficulty = excluded.difficulty,
+			length_km = excluded.length_km,
+			created_at = excluded.created_at,
+			track = excluded.track,
+			waypoints = excluded.waypoints,
+			elevation = excluded.elevation,
+			difficulty_score = excluded.difficulty_score,
+			difficulty_rated = excluded.difficulty_rated,
+			created_by = excluded.created_by,
+			updated_by = excluded.updated_by,
+			sort_name = excluded.sort_name,
+			sort_created_at = excluded.sort_created_at`,
+		trail.UID.String(), trail.Name, trail.Lat, trail.Lon, trail.Difficulty, trail.LengthKm, formatOptionalTime(trail.CreatedAt),
+		track, waypoints, elevation, trail.DifficultyScore, trail.DifficultyRated, nullableString(trail.CreatedBy), nullableString(trail.UpdatedBy),
+		models.NameSortKey(trail), models.CreatedAtSortKey(trail),
+	)
+	if err != nil {
+		return err
+	}
+	if err := saveTrailTerms(ctx, tx, trail); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveTrailTerms(ctx context.Context, tx *sql.Tx, trail *models.Trail) error {
+	uid := trail.UID.String()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM trail_terms WHERE uid = ?", uid); err != nil {
+		return err
+	}
+	for _, token := range nameTokens(trail) {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO trail_terms (token, uid) VALUES (?, ?)", token, uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backfillTrailTerms(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT uid, name FROM trails")
+	if err != nil {
+		return err
+	}
+	var trails []*models.Trail
+	for rows.Next() {
+		var uid string
+		var name sql.NullString
+		if err := rows.Scan(&uid, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		parsed, err := uuid.Parse(uid)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		trail := &models.Trail{UID: parsed}
+		if name.Valid {
+			trail.Name = &name.String
+		}
+		trails = append(trails, trail)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, trail := range trails {
+		if err := saveTrailTerms(context.Background(), tx, trail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backfillSortKeys(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT uid, name, created_at FROM trails")
+	if err != nil {
+		return err
+	}
+	type sortKeys struct {
+		uid, name, createdAt string
+	}
+	var keys []sortKeys
+	for rows.Next() {
+		var uid string
+		var name, createdAt sql.NullString
+		if err := rows.Scan(&uid, &name, &createdAt); err != nil {
+			rows.Close()
+			return err
+		}
+		trail := &models.Trail{}
+		if name.Valid {
+			trail.Name = &name.String
+		}
+		if createdAt.Valid {
+			parsed, err := time.Parse(time.RFC3339Nano, createdAt.String)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("invalid stored created_at %q: %w", createdAt.String, err)
+			}
+			trail.CreatedAt = &parsed
+		}
+		keys = append(keys, sortKeys{uid: uid, name: models.NameSortKey(trail), createdAt: models.CreatedAtSortKey(trail)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if _, err := tx.Exec("UPDATE trails SET sort_name = ?, sort_created_at = ? WHERE uid = ?", k.name, k.createdAt, k.uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindAll pages in SQL when the sort is stored, nearest, distance and relevance listings are ordered in Go
+func (s *sqliteTrailStorage) FindAll(ctx context.Context, filter *models.TrailFilter) ([]*models.Trail, error) {
+	if filter == nil {
+		filter = &models.TrailFilter{}
+	}
+	sortKey, ok := sortKeyExprs[filter.SortField()]
+	if filter.Nearest != nil || !ok {
+		trails, err := s.matching(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		return filter.Paginate(trails), nil
+	}
+
+	where, args, ok, err := s.where(ctx, filter)
+	if err != nil || !ok {
+		return []*models.Trail{}, err
+	}
+
+	direction, after := "ASC", ">"
+	if filter.SortDesc {
+		direction, after = "DESC", "<"
+	}
+	if filter.Cursor != nil {
+		where = append(where, "("+sortKey.expr+", uid) "+after+" (?, ?)")
+		if sortKey.numeric {
+			args = append(args, filter.Cursor.Num, filter.Cursor.UID)
+		} else {
+			args = append(args, filter.Cursor.Str, filter.Cursor.UID)
+		}
+	}
+
+	query := "SELECT " + trailColumns + " FROM trails" + whereClause(where) +
+		" ORDER BY " + sortKey.expr + " " + direction + ", uid " + direction
+	// Rows checked in Go may not match, so then rows are read until the page is full instead
+	exact := !needsRowCheck(filter)
+	if exact && filter.Limit != nil {
+		query += " LIMIT ?"
+		args = append(args, *filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trails := make([]*models.Trail, 0)
+	for rows.Next() {
+		if filter.Limit != nil && len(trails) >= *filter.Limit {
+			break
+		}
+		trail, err := scanTrail(rows)
+		if err != nil {
+			return nil, err
+		}
+		if exact || trail.MatchesFilter(filter) {
+			trails = append(trails, trail)
+		}
+	}
+	return trails, rows.Err()
+}
+
+func (s *sqliteTrailStorage) Count(ctx context.Context, filter *models.TrailFilter) (int, error) {
+	if filter != nil && filter.Nearest != nil {
+		trails, err := s.matching(ctx, filter)
+		if err != nil {
+			return 0, err
+		}
+		return len(trails), nil
+	}
+
+	where, args, ok, err := s.where(ctx, filter)
+	if err != nil || !ok {
+		return 0, err
+	}
+	if !needsRowCheck(filter) {
+		var count int
+		err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM trails"+whereClause(where), args...).Scan(&count)
+		return count, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+trailSummaryColumns+" FROM trails"+whereClause(where), args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		trail, err := scanTrail(rows)
+		if err != nil {
+			return 0, err
+		}
+		if trail.MatchesFilter(filter) {
+			count++
+		}
+	}
+	return count, rows.Err()
+}
+
+func (s *sqliteTrailStorage) matching(ctx context.Context, filter *models.TrailFilter) ([]*models.Trail, error) {
+	if filter != nil && filter.Nearest != nil {
+		return findNearest(filter, func(circle *models.TrailFilter) ([]*models.Trail, error) {
+			return s.matching(ctx, circle)
+		})
+	}
+
+	where, args, ok, err := s.where(ctx, filter)
+	if err != nil || !ok {
+		return []*models.Trail{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+trailColumns+" FROM trails"+whereClause(where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trails := make([]*models.Trail, 0)
+	for rows.Next() {
+		trail, err := scanTrail(rows)
+		if err != nil {
+			return nil, err
+		}
+		if filter == nil || trail.MatchesFilter(filter) {
+			trails = append(trails, trail)
+		}
+	}
+	return trails, rows.Err()
+}
+
+// needsRowCheck reports whether rows selected by where must still be checked with MatchesFilter
+func needsRowCheck(filter *models.TrailFilter) bool {
+	return filter != nil && (filter.RadiusKm != nil || filter.Area != nil || filter.Query != nil)
+}
+
+// where reports false when no trail can match the filter
+func (s *sqliteTrailStorage) where(ctx context.Context, filter *models.TrailFilter) ([]string, []any, bool, error) {
+	var where []string
+	var args []any
+	if filter == nil {
+		return where, args, true, nil
+	}
+
+	if filter.Name != nil && *filter.Name != "" {
+		where = append(where, "name = ?")
+		args = append(args, *filter.Name)
+	}
+	if filter.Difficulty != nil {
+		where = append(where, "difficulty = ?")
+		args = append(args, string(*filter.Difficulty))
+	}
+	if filter.LengthKm != nil {
+		where = append(where, "length_km = ?")
+		args = append(args, *filter.LengthKm)
+	}
+	if len(filter.Difficulties) > 0 {
+		where = append(where, "difficulty IN (?"+strings.Repeat(", ?", len(filter.Difficulties)-1)+")")
+		for _, difficulty := range filter.Difficulties {
+			args = append(args, string(difficulty))
+		}
+	}
+	if filter.MinLengthKm != nil {
+		where = append(where, "length_km >= ?")
+		args = append(args, *filter.MinLengthKm)
+	}
+	if filter.MaxLengthKm != nil {
+		where = append(where, "length_km <= ?")
+		args = append(args, *filter.MaxLengthKm)
+	}
+	// Compared on the fixed width sort key, created_at itself does not order correctly as text
+	if filter.CreatedAfter != nil {
+		where = append(where, "sort_created_at >= ?")
+		args = append(args, models.TimeSortKey(*filter.CreatedAfter))
+	}
+	if filter.CreatedBefore != nil {
+		where = append(where, "sort_created_at <> '' AND sort_created_at < ?")
+		args = append(args, models.TimeSortKey(*filter.CreatedBefore))
+	}
+	if filter.Owner != nil {
+		where = append(where, "created_by = ?")
+		args = append(args, *filter.Owner)
+	}
+	if filter.Lat != nil && filter.Lon != nil && filter.RadiusKm != nil {
+		minLat, minLon, maxLat, maxLon := models.BoundingBox(*filter.Lat, *filter.Lon, *filter.RadiusKm)
+		where = append(where, "lat BETWEEN ? AND ?")
+		args = append(args, minLat, maxLat)
+		if minLon >= -180 && maxLon <= 180 {
+			where = append(where, "lon BETWEEN ? AND ?")
+			args = append(args, minLon, maxLon)
+		}
+	}
+	if filter.BBox != nil {
+		where, args = whereInBBox(where, args, *filter.BBox)
+	}
+	if filter.Query != nil {
+		var ok bool
+		var err error
+		where, args, ok, err = s.whereMatchesText(ctx, where, args, filter.Query)
+		// A term matching no indexed token means no trail can match
+		if err != nil || !ok {
+			return nil, nil, false, err
+		}
+	}
+	if filter.Area != nil {
+		where, args = whereInBBox(where, args, filter.Area.Bounds())
+	}
+	return where, args, true, nil
+}
+
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(where, " AND ")
+}
+
+// whereMatchesText compares terms against the distinct tokens in the index rather than every trail's name
+func (s *sqliteTrailStorage) whereMatchesText(ctx context.Context, where []string, args []any, query *models.TextQuery) ([]string, []any, bool, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT token FROM trail_terms")
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer rows.Close()
+
+	termTokens := make([][]any, len(query.Terms))
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, nil, false, err
+		}
+		for term := range query.Terms {
+			if query.TermMatches(term, token) {
+				termTokens[term] = append(termTokens[term], token)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, false, err
+	}
+
+	for _, tokens := range termTokens {
+		if len(tokens) == 0 {
+			return where, args, false, nil
+		}
+		where = append(where, "uid IN (SELECT uid FROM trail_terms WHERE token IN (?"+strings.Repeat(", ?", len(tokens)-1)+"))")
+		args = append(args, tokens...)
+	}
+	return where, args, true, nil
+}
+
+func whereInBBox(where []string, args []any, box models.BBox) ([]string, []any) {
+	where = append(where, "lat BETWEEN ? AND ?")
+	args = append(args, box.MinLat, box.MaxLat)
+	if box.CrossesAntimeridian() {
+		where = append(where, "(lon >= ? OR lon <= ?)")
+	} else {
+		where = append(where, "lon BETWEEN ? AND ?")
+	}
+	return where, append(args, box.MinLon, box.MaxLon)
+}
+
+func (s *sqliteTrailStorage) FindById(ctx context.Context, uid string) (*models.Trail, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+trailColumns+" FROM trails WHERE uid = ?", uid)
+	trail, err := scanTrail(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTrailNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return trail, nil
+}
+
+func (s *sqliteTrailStorage) Delete(ctx context.Context, uid string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM trails WHERE uid = ?", uid)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTrailNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM trail_terms WHERE uid = ?", uid); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteTrailStorage) Clear(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM trails; DELETE FROM trail_terms;")
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTrail(row rowScanner) (*models.Trail, error) {
+	var (
+		uid        string
+		name       sql.NullString
+		lat        sql.NullFloat64
+		lon        sql.NullFloat64
+		difficulty sql.NullString
+		lengthKm   sql.NullFloat64
+		createdAt  sql.NullString
+		track      sql.NullString
+		waypoints  sql.NullString
+		elevation  sql.NullString
+		score      sql.NullFloat64
+		rated      bool
+		createdBy  sql.NullString
+		updatedBy  sql.NullString
+	)
+	if err := row.Scan(&uid, &name, &lat, &lon, &difficulty, &lengthKm, &createdAt, &track, &waypoints, &elevation, &score, &rated, &createdBy, &updatedBy); err != nil {
+		return nil, err
+	}
+
+	parsedUID, err := uuid.Parse(uid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored trail id %q: %w", uid, err)
+	}
+
+	trail := &models.Trail{UID: parsedUID, DifficultyRated: rated, CreatedBy: createdBy.String, UpdatedBy: updatedBy.String}
+	if name.Valid {
+		trail.Name = &name.String
+	}
+	if lat.Valid {
+		trail.Lat = &lat.Float64
+	}
+	if lon.Valid {
+		trail.Lon = &lon.Float64
+	}
+	if difficulty.Valid {
+		d := models.TrailDifficulty(difficulty.String)
+		trail.Difficulty = &d
+	}
+	if lengthKm.Valid {
+		trail.LengthKm = &lengthKm.Float64
+	}
+	if score.Valid {
+		trail.DifficultyScore = &score.Float64
+	}
+	if createdAt.Valid {
+		parsed, err := time.Parse(time.RFC3339Nano, createdAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stored created_at %q: %w", createdAt.String, err)
+		}
+		trail.CreatedAt = &parsed
+	}
+	if track.Valid {
+		if err := json.Unmarshal([]byte(track.String), &trail.Track); err != nil {
+			return nil, fmt.Errorf("invalid stored track: %w", err)
+		}
+	}
+	if waypoints.Valid {
+		if err := json.Unmarshal([]byte(waypoints.String), &trail.Waypoints); err != nil {
+			return nil, fmt.Errorf("invalid stored waypoints: %w", err)
+		}
+	}
+	if elevation.Valid {
+		if err := json.Unmarshal([]byte(elevation.String), &trail.Elevation); err != nil {
+			return nil, fmt.Errorf("invalid stored elevation: %w", err)
+		}
+	}
+	return trail, nil
+}
+
+// marshalNullableJSON stores empty lists as NULL rather than "null" or "[]"
+func marshalNullableJSON[T any](values []T) (*string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+	return &encoded, nil
+}
+
+func marshalOptionalJSON[T any](value *T) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+	return &encoded, nil
+}
+
+func nullableString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
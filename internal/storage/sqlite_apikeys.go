@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/google/uuid"
+)
+
+const apiKeyColumns = "id, name, username, prefix, hash, scopes, created_at, revoked_at"
+
+type sqliteAPIKeyStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteAPIKeyStorage(db *sql.DB) *sqliteAPIKeyStorage {
+	return &sqliteAPIKeyStorage{db: db}
+}
+
+func (s *sqliteAPIKeyStorage) Create(ctx context.Context, key *models.APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (`+apiKeyColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.ID.String(), key.Name, key.Username, key.Prefix, key.Hash, string(scopes),
+		formatOptionalTime(key.CreatedAt), formatOptionalTime(key.RevokedAt),
+	)
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrAPIKeyExists
+	}
+	return err
+}
+
+func (s *sqliteAPIKeyStorage) FindByID(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.findOne(ctx, "id", id)
+}
+
+func (s *sqliteAPIKeyStorage) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return s.findOne(ctx, "hash", hash)
+}
+
+func (s *sqliteAPIKeyStorage) findOne(ctx context.Context, column string, value string) (*models.APIKey, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE "+column+" = ?", value)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *sqliteAPIKeyStorage) ListByUsername(ctx context.Context, username string) ([]*models.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE username = ? ORDER BY rowid", username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *sqliteAPIKeyStorage) Revoke(ctx context.Context, id string, at time.Time) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?", formatOptionalTime(&at), id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var (
+		id        string
+		key       models.APIKey
+		scopes    string
+		createdAt sql.NullString
+		revokedAt sql.NullString
+	)
+	if err := row.Scan(&id, &key.Name, &key.Username, &key.Prefix, &key.Hash, &scopes, &createdAt, &revokedAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if key.ID, err = uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid stored api key id %q: %w", id, err)
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, fmt.Errorf("invalid stored api key scopes: %w", err)
+	}
+	if key.CreatedAt, err = parseOptionalTime(createdAt); err != nil {
+		return nil, fmt.Errorf("invalid stored created_at %q: %w", createdAt.String, err)
+	}
+	if key.RevokedAt, err = parseOptionalTime(revokedAt); err != nil {
+		return nil, fmt.Errorf("invalid stored revoked_at %q: %w", revokedAt.String, err)
+	}
+	return &key, nil
+}
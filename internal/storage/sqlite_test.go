@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLiteDB(t *testing.T) (*sql.DB, string) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "trails.db")
+	db, err := OpenSQLite(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, dsn
+}
+
+func newTestSQLiteStorage(t *testing.T) (*sqliteTrailStorage, string) {
+	db, dsn := newTestSQLiteDB(t)
+	return NewSQLiteTrailStorage(db), dsn
+}
+
+func TestSQLiteTrailStorage_SaveAndFindById(t *testing.T) {
+	storage, _ := newTestSQLiteStorage(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	trail := models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53)
+	trail.CreatedAt = &now
+	trail.CreatedBy = "ranger"
+	trail.UpdatedBy = "warden"
+	require.NoError(t, storage.Save(ctx, trail))
+
+	found, err := storage.FindById(ctx, trail.UID.String())
+	require.NoError(t, err)
+	assert.Equal(t, trail.UID, found.UID)
+	assert.Equal(t, *trail.Name, *found.Name)
+	assert.Equal(t, *trail.Lat, *found.Lat)
+	assert.Equal(t, *trail.Lon, *found.Lon)
+	assert.Equal(t, *trail.Difficulty, *found.Difficulty)
+	assert.Equal(t, *trail.LengthKm, *found.LengthKm)
+	assert.True(t, now.Equal(*found.CreatedAt))
+	assert.Equal(t, "ranger", found.CreatedBy)
+	assert.Equal(t, "warden", found.UpdatedBy)
+
+	// Saving again replaces the existing row
+	trail.LengthKm = float64Ptr(54)
+	require.NoError(t, storage.Save(ctx, trail))
+	found, err = storage.FindById(ctx, trail.UID.String())
+	require.NoError(t, err)
+	assert.Equal(t, 54.0, *found.LengthKm)
+
+	_, err = storage.FindById(ctx, uuid.New().String())
+	assert.EqualError(t, err, "trail not found")
+}
+
+func TestSQLiteTrailStorage_SaveTrack(t *testing.T) {
+	storage, _ := newTestSQLiteStorage(t)
+	ctx := context.Background()
+
+	ele := 2000.0
+	trail := models.NewTrailFromTrack("Lamar River Trail", []models.Coordinate{
+		{Lat: 44.8472, Lon: -109.6278, Ele: &ele},
+		{Lat: 44.8500, Lon: -109.6200},
+	}, []models.Waypoint{{Name: "Trailhead", Lat: 44.8480, Lon: -109.6270}})
+	trail.Elevation = &models.ElevationStats{AscentM: 10, MinM: 2000, MaxM: 2010, MaxGradePct: 1.5}
+	score := 12.5
+	trail.DifficultyScore = &score
+	trail.DifficultyRated = true
+	require.NoError(t, storage.Save(ctx, trail))
+
+	found, err := storage.FindById(ctx, trail.UID.String())
+	require.NoError(t, err)
+	assert.Equal(t, trail.Track, found.Track)
+	assert.Equal(t, trail.Waypoints, found.Waypoints)
+	assert.Equal(t, trail.Elevation, found.Elevation)
+	assert.Equal(t, trail.DifficultyScore, found.DifficultyScore)
+	assert.True(t, found.DifficultyRated)
+}
+
+func TestSQLiteTrailStorage_FindAll(t *testing.T) {
+	storage, _ := newTestSQLiteStorage(t)
+	ctx := context.Background()
+
+	trails := []*models.Trail{
+		models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53),
+		models.NewTrail("Trail of Ten Falls", 43.8242, -121.5654, models.TrailDifficultyMedium, 10),
+		models.NewTrail("Angel's Rest", 45.6789, -122.3456, models.TrailDifficultyMedium, 10),
+	}
+	trails[1].CreatedBy = "ranger"
+	for _, trail := range trails {
+		require.NoError(t, storage.Save(ctx, trail))
+	}
+
+	mediumDifficulty := models.TrailDifficultyMedium
+
+	tests := []struct {
+		name          string
+		filter        *models.TrailFilter
+		expectedNames []string
+	}{
+		{
+			name:          "no filter",
+			filter:        nil,
+			expectedNames: []string{"Lamar River Trail", "Trail of Ten Falls", "Angel's Rest"},
+		},
+		{
+			name: "filter by difficulty",
+			filter: &models.TrailFilter{
+				CreateTrailRequest: models.CreateTrailRequest{
+					Difficulty: &mediumDifficulty,
+				},
+			},
+			expectedNames: []string{"Trail of Ten Falls", "Angel's Rest"},
+		},
+		{
+			name: "filter by proximity",
+			filter: &models.TrailFilter{
+				CreateTrailRequest: models.CreateTrailRequest{
+					Lat: float64Ptr(45.6789),
+					Lon: float64Ptr(-122.3456),
+				},
+				RadiusKm: float64Ptr(10),
+			},
+			expectedNames: []string{"Angel's Rest"},
+		},
+		{
+			name:          "filter by owner",
+			filter:        &models.TrailFilter{Owner: stringPtr("ranger")},
+			expectedNames: []string{"Trail of Ten Falls"},
+		},
+		{
+			name: "filter with no matches",
+			filter: &models.TrailFilter{
+				CreateTrailRequest: models.CreateTrailRequest{
+					Name: stringPtr("nonexistent"),
+				},
+			},
+			expectedNames: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := storage.FindAll(ctx, tt.filter)
+			require.NoError(t, err)
+
+			names := make([]string, len(found))
+			for i, trail := range found {
+				names[i] = *trail.Name
+			}
+			assert.ElementsMatch(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func TestSQLiteTrailStorage_Delete(t *testing.T) {
+	storage, _ := newTestSQLiteStorage(t)
+	ctx := context.Background()
+
+	trail := models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53)
+	require.NoError(t, storage.Save(ctx, trail))
+
+	require.NoError(t, storage.Delete(ctx, trail.UID.String()))
+	assert.EqualError(t, storage.Delete(ctx, trail.UID.String()), "trail not found")
+	assert.EqualError(t, storage.Delete(ctx, uuid.New().String()), "trail not found")
+}
+
+func TestSQLiteTrailStorage_PersistsAcrossReopen(t *testing.T) {
+	storage, dsn := newTestSQLiteStorage(t)
+	ctx := context.Background()
+
+	trail := models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53)
+	require.NoError(t, storage.Save(ctx, trail))
+	require.NoError(t, storage.db.Close())
+
+	db, err := OpenSQLite(dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	reopened := NewSQLiteTrailStorage(db)
+
+	found, err := reopened.FindById(ctx, trail.UID.String())
+	require.NoError(t, err)
+	assert.Equal(t, *trail.Name, *found.Name)
+}
+
+func TestSQLiteTrailStorage_BackfillsTrailTerms(t *testing.T) {
+	storage, dsn := newTestSQLiteStorage(t)
+	ctx := context.Background()
+
+	trail := models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53)
+	require.NoError(t, storage.Save(ctx, trail))
+
+	// Roll the database back to before the text index existed
+	_, err := storage.db.Exec(`DROP TABLE trail_terms;
+		DROP INDEX idx_trails_sort_name;
+		DROP INDEX idx_trails_sort_created_at;
+		ALTER TABLE trails DROP COLUMN sort_name;
+		ALTER TABLE trails DROP COLUMN sort_created_at;
+		ALTER TABLE trails DROP COLUMN difficulty_rated;
+		DROP INDEX idx_users_external_subject;
+		ALTER TABLE users DROP COLUMN external_subject;
+		PRAGMA user_version = 9;`)
+	require.NoError(t, err)
+	require.NoError(t, storage.db.Close())
+
+	db, err := OpenSQLite(dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	reopened := NewSQLiteTrailStorage(db)
+
+	found, err := reopened.FindAll(ctx, &models.TrailFilter{Query: models.NewTextQuery("lamar river", false)})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, trail.UID, found[0].UID)
+}
+
+func TestSQLiteTrailStorage_FindAllPages(t *testing.T) {
+	storage, _ := newTestSQLiteStorage(t)
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	trails := []*models.Trail{
+		models.NewTrail("Lamar River Trail", 44.8472, -109.6278, models.TrailDifficultyHard, 53),
+		models.NewTrail("Trail of Ten Falls", 43.8242, -121.5654, models.TrailDifficultyMedium, 10),
+		models.NewTrail("angel's Rest", 45.6789, -122.3456, models.TrailDifficultyMedium, 10),
+		models.NewTrail("Multnomah Falls", 45.5762, -122.1158, models.TrailDifficultyEasy, 3.9),
+		models.NewTrail("Dog Mountain", 45.6994, -121.7081, models.TrailDifficultyHard, 11.6),
+	}
+	for i, trail := range trails {
+		createdAt := start.Add(time.Duration(i%3) * time.Hour)
+		trail.CreatedAt = &createdAt
+		require.NoError(t, storage.Save(ctx, trail))
+	}
+
+	sorts := []models.TrailSortField{
+		models.TrailSortByName, models.TrailSortByCreatedAt, models.TrailSortByLengthKm, models.TrailSortByDifficulty,
+	}
+	for _, sortBy := range sorts {
+		for _, desc := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s desc=%v", sortBy, desc), func(t *testing.T) {
+				limit := 2
+				filter := &models.TrailFilter{SortBy: &sortBy, SortDesc: desc, Limit: &limit}
+
+				// Paging through in SQL gives the same order as sorting every trail in memory
+				expected := (&models.TrailFilter{SortBy: &sortBy, SortDesc: desc}).Paginate(trails)
+				var paged []*models.Trail
+				for {
+					page, err := storage.FindAll(ctx, filter)
+					require.NoError(t, err)
+					if len(page) == 0 {
+						break
+					}
+					paged = append(paged, page...)
+					filter.Cursor = filter.CursorFor(page[len(page)-1])
+				}
+
+				require.Len(t, paged, len(expected))
+				for i := range expected {
+					assert.Equal(t, expected[i].UID, paged[i].UID)
+				}
+			})
+		}
+	}
+
+	count, err := storage.Count(ctx, &models.TrailFilter{Difficulties: []models.TrailDifficulty{models.TrailDifficultyHard}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	createdAfter := start.Add(time.Hour)
+	count, err = storage.Count(ctx, &models.TrailFilter{CreatedAfter: &createdAfter})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestSQLiteTrailStorage_FindAllCreatedRangePages(t *testing.T) {
+	storage, _ := newTestSQLiteStorage(t)
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// Offsets from start, including fractions of a second that RFC 3339 text would order wrongly
+	offsets := []time.Duration{-time.Hour, 0, 500 * time.Millisecond, time.Second, time.Hour, 2 * time.Hour}
+	var trails []*models.Trail
+	for i, offset := range offsets {
+		createdAt := start.Add(offset).In(time.FixedZone("MDT", -6*60*60))
+		trail := models.NewTrail(fmt.Sprintf("Trail %d", i), 44.8472, -109.6278, models.TrailDifficultyEasy, 5)
+		trail.CreatedAt = &createdAt
+		trails = append(trails, trail)
+	}
+	undated := models.NewTrail("Undated Trail", 44.8472, -109.6278, models.TrailDifficultyEasy, 5)
+	undated.CreatedAt = nil
+	trails = append(trails, undated)
+	for _, trail := range trails {
+		require.NoError(t, storage.Save(ctx, trail))
+	}
+
+	createdAfter, createdBefore := start, start.Add(2*time.Hour)
+	sortBy := models.TrailSortByName
+	limit := 2
+	filter := &models.TrailFilter{CreatedAfter: &createdAfter, CreatedBefore: &createdBefore, SortBy: &sortBy, Limit: &limit}
+
+	count, err := storage.Count(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	var names []string
+	for {
+		page, err := storage.FindAll(ctx, filter)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, trail := range page {
+			names = append(names, *trail.Name)
+		}
+		filter.Cursor = filter.CursorFor(page[len(page)-1])
+	}
+	assert.Equal(t, []string{"Trail 1", "Trail 2", "Trail 3", "Trail 4"}, names)
+}
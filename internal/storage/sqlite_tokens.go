@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+)
+
+type sqliteTokenStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteTokenStorage(db *sql.DB) *sqliteTokenStorage {
+	return &sqliteTokenStorage{db: db}
+}
+
+func (s *sqliteTokenStorage) SaveRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE expires_at <= ?", time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (hash, family_id, username, expires_at, used_at, revoked)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		token.Hash, token.FamilyID, token.Username, token.ExpiresAt.Unix(), formatOptionalTime(token.UsedAt), token.Revoked,
+	)
+	return err
+}
+
+func (s *sqliteTokenStorage) FindRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	return findRefreshToken(ctx, s.db, hash)
+}
+
+func (s *sqliteTokenStorage) UseRefreshToken(ctx context.Context, hash string, at time.Time) (*models.RefreshToken, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	token, err := findRefreshToken(ctx, tx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if token.UsedAt == nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = ? WHERE hash = ?", formatOptionalTime(&at), hash); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func findRefreshToken(ctx context.Context, db queryRower, hash string) (*models.RefreshToken, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT hash, family_id, username, expires_at, used_at, revoked
+		FROM refresh_tokens WHERE hash = ?`, hash)
+	var (
+		token     models.RefreshToken
+		expiresAt int64
+		usedAt    sql.NullString
+	)
+	err := row.Scan(&token.Hash, &token.FamilyID, &token.Username, &expiresAt, &usedAt, &token.Revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	token.ExpiresAt = time.Unix(expiresAt, 0)
+	if token.UsedAt, err = parseOptionalTime(usedAt); err != nil {
+		return nil, fmt.Errorf("invalid stored used_at %q: %w", usedAt.String, err)
+	}
+	return &token, nil
+}
+
+func (s *sqliteTokenStorage) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked = 1 WHERE family_id = ?", familyID)
+	return err
+}
+
+func (s *sqliteTokenStorage) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= ?", time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?)
+		ON CONFLICT (jti) DO UPDATE SET expires_at = excluded.expires_at`,
+		jti, expiresAt.Unix(),
+	)
+	return err
+}
+
+func (s *sqliteTokenStorage) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ? AND expires_at > ?)",
+		jti, time.Now().Unix(),
+	).Scan(&exists)
+	return exists, err
+}
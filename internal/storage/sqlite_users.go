@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/google/uuid"
+)
+
+const userColumns = "uid, username, role, password_hash, created_at, failed_logins, locked_until, external_subject"
+
+type sqliteUserStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteUserStorage(db *sql.DB) *sqliteUserStorage {
+	return &sqliteUserStorage{db: db}
+}
+
+func (s *sqliteUserStorage) Create(ctx context.Context, user *models.User) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO users (`+userColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.UID.String(), user.Username, string(user.Role), user.PasswordHash, formatOptionalTime(user.CreatedAt),
+		user.FailedLogins, formatOptionalTime(user.LockedUntil), user.ExternalSubject,
+	)
+	// The unique constraints on username and external subject catch registrations racing each other
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrUserExists
+	}
+	return err
+}
+
+func (s *sqliteUserStorage) Save(ctx context.Context, user *models.User) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE users SET role = ?, password_hash = ?, failed_logins = ?, locked_until = ?
+		WHERE username = ?`,
+		string(user.Role), user.PasswordHash, user.FailedLogins, formatOptionalTime(user.LockedUntil), user.Username,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *sqliteUserStorage) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE username = ?", models.NormalizeUsername(username)))
+}
+
+func (s *sqliteUserStorage) FindByExternalSubject(ctx context.Context, subject string) (*models.User, error) {
+	if subject == "" {
+		return nil, ErrUserNotFound
+	}
+	return scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE external_subject = ?", subject))
+}
+
+func scanUser(row *sql.Row) (*models.User, error) {
+	var (
+		uid         string
+		user        models.User
+		createdAt   sql.NullString
+		lockedUntil sql.NullString
+	)
+	err := row.Scan(&uid, &user.Username, &user.Role, &user.PasswordHash, &createdAt, &user.FailedLogins, &lockedUntil, &user.ExternalSubject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.UID, err = uuid.Parse(uid); err != nil {
+		return nil, fmt.Errorf("invalid stored user id %q: %w", uid, err)
+	}
+	if user.CreatedAt, err = parseOptionalTime(createdAt); err != nil {
+		return nil, fmt.Errorf("invalid stored created_at %q: %w", createdAt.String, err)
+	}
+	if user.LockedUntil, err = parseOptionalTime(lockedUntil); err != nil {
+		return nil, fmt.Errorf("invalid stored locked_until %q: %w", lockedUntil.String, err)
+	}
+	return &user, nil
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.UTC().Format(time.RFC3339Nano)
+	return &formatted
+}
+
+func parseOptionalTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"slices"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+)
+
+func nameTokens(trail *models.Trail) []string {
+	if trail.Name == nil {
+		return nil
+	}
+	tokens := models.Tokenize(*trail.Name)
+	slices.Sort(tokens)
+	return slices.Compact(tokens)
+}
+
+// textIndex is not safe for concurrent use, callers must hold the storage lock
+type textIndex struct {
+	postings    map[string]map[string]*models.Trail
+	trailTokens map[string][]string
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{
+		postings:    make(map[string]map[string]*models.Trail),
+		trailTokens: make(map[string][]string),
+	}
+}
+
+func (i *textIndex) insert(trail *models.Trail) {
+	uid := trail.UID.String()
+	i.remove(uid)
+
+	tokens := nameTokens(trail)
+	for _, token := range tokens {
+		posting, ok := i.postings[token]
+		if !ok {
+			posting = make(map[string]*models.Trail)
+			i.postings[token] = posting
+		}
+		posting[uid] = trail
+	}
+	if len(tokens) > 0 {
+		i.trailTokens[uid] = tokens
+	}
+}
+
+func (i *textIndex) remove(uid string) {
+	for _, token := range i.trailTokens[uid] {
+		delete(i.postings[token], uid)
+		if len(i.postings[token]) == 0 {
+			delete(i.postings, token)
+		}
+	}
+	delete(i.trailTokens, uid)
+}
+
+// candidates leaves scoring against the whole query to the caller
+func (i *textIndex) candidates(query *models.TextQuery, fn func(*models.Trail)) {
+	var matched map[string]*models.Trail
+	for term := range query.Terms {
+		// Only the distinct tokens are compared against the term, not every trail
+		termMatches := make(map[string]*models.Trail)
+		for token, posting := range i.postings {
+			if !query.TermMatches(term, token) {
+				continue
+			}
+			for uid, trail := range posting {
+				if matched == nil || matched[uid] != nil {
+					termMatches[uid] = trail
+				}
+			}
+		}
+		matched = termMatches
+		if len(matched) == 0 {
+			return
+		}
+	}
+	for _, trail := range matched {
+		fn(trail)
+	}
+}
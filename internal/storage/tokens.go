@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+)
+
+const sweepInterval = time.Minute
+
+type TokenStorage interface {
+	SaveRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	FindRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// UseRefreshToken marks the token used at the given time and returns it as it was beforehand,
+	// so a caller seeing UsedAt already set knows the token is being reused
+	UseRefreshToken(ctx context.Context, hash string, at time.Time) (*models.RefreshToken, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+type tokenStorage struct {
+	sync.Mutex
+	refresh   map[string]*models.RefreshToken
+	revoked   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewTokenStorage() *tokenStorage {
+	return &tokenStorage{
+		refresh: make(map[string]*models.RefreshToken),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+func (s *tokenStorage) SaveRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	s.Lock()
+	defer s.Unlock()
+	s.sweep(time.Now())
+	copied := *token
+	s.refresh[token.Hash] = &copied
+	return nil
+}
+
+func (s *tokenStorage) FindRefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	s.Lock()
+	defer s.Unlock()
+	token, ok := s.refresh[hash]
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (s *tokenStorage) UseRefreshToken(ctx context.Context, hash string, at time.Time) (*models.RefreshToken, error) {
+	s.Lock()
+	defer s.Unlock()
+	token, ok := s.refresh[hash]
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+	before := *token
+	if token.UsedAt == nil {
+		token.UsedAt = &at
+	}
+	return &before, nil
+}
+
+func (s *tokenStorage) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	s.Lock()
+	defer s.Unlock()
+	for _, token := range s.refresh {
+		if token.FamilyID == familyID {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (s *tokenStorage) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	s.sweep(time.Now())
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *tokenStorage) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	expiresAt, ok := s.revoked[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+// sweep must be called with the lock held
+func (s *tokenStorage) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for hash, token := range s.refresh {
+		if token.IsExpired(now) {
+			delete(s.refresh, hash)
+		}
+	}
+	for jti, expiresAt := range s.revoked {
+		if !now.Before(expiresAt) {
+			delete(s.revoked, jti)
+		}
+	}
+}
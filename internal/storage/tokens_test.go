@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenStorage(t *testing.T) {
+	backends := []struct {
+		name    string
+		storage func(t *testing.T) TokenStorage
+	}{
+		{
+			name:    "memory",
+			storage: func(t *testing.T) TokenStorage { return NewTokenStorage() },
+		},
+		{
+			name: "sqlite",
+			storage: func(t *testing.T) TokenStorage {
+				db, _ := newTestSQLiteDB(t)
+				return NewSQLiteTokenStorage(db)
+			},
+		},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name+" refresh tokens", func(t *testing.T) {
+			storage := backend.storage(t)
+			ctx := context.Background()
+			expiresAt := time.Now().Add(time.Hour)
+
+			for _, hash := range []string{"first", "second"} {
+				require.NoError(t, storage.SaveRefreshToken(ctx, &models.RefreshToken{
+					Hash: hash, FamilyID: "family", Username: "ranger", ExpiresAt: expiresAt,
+				}))
+			}
+
+			used, err := storage.UseRefreshToken(ctx, "first", time.Now())
+			require.NoError(t, err)
+			assert.Nil(t, used.UsedAt)
+			assert.Equal(t, "ranger", used.Username)
+			assert.Equal(t, expiresAt.Unix(), used.ExpiresAt.Unix())
+
+			reused, err := storage.UseRefreshToken(ctx, "first", time.Now())
+			require.NoError(t, err)
+			assert.NotNil(t, reused.UsedAt)
+
+			require.NoError(t, storage.RevokeRefreshTokenFamily(ctx, "family"))
+			found, err := storage.FindRefreshToken(ctx, "second")
+			require.NoError(t, err)
+			assert.True(t, found.Revoked)
+			assert.Nil(t, found.UsedAt)
+
+			_, err = storage.UseRefreshToken(ctx, "missing", time.Now())
+			assert.EqualError(t, err, "refresh token not found")
+		})
+
+		t.Run(backend.name+" access token revocation", func(t *testing.T) {
+			storage := backend.storage(t)
+			ctx := context.Background()
+
+			require.NoError(t, storage.RevokeAccessToken(ctx, "active", time.Now().Add(time.Hour)))
+			require.NoError(t, storage.RevokeAccessToken(ctx, "expired", time.Now().Add(-time.Second)))
+
+			revoked, err := storage.IsAccessTokenRevoked(ctx, "active")
+			require.NoError(t, err)
+			assert.True(t, revoked)
+
+			revoked, err = storage.IsAccessTokenRevoked(ctx, "expired")
+			require.NoError(t, err)
+			assert.False(t, revoked, "revocations lapse once the token would have expired anyway")
+
+			revoked, err = storage.IsAccessTokenRevoked(ctx, "unknown")
+			require.NoError(t, err)
+			assert.False(t, revoked)
+		})
+	}
+}
+
+func TestTokenStorage_SweepsExpiredEntries(t *testing.T) {
+	storage := NewTokenStorage()
+	ctx := context.Background()
+
+	require.NoError(t, storage.SaveRefreshToken(ctx, &models.RefreshToken{Hash: "expired", ExpiresAt: time.Now().Add(-time.Second)}))
+	require.NoError(t, storage.RevokeAccessToken(ctx, "expired", time.Now().Add(-time.Second)))
+
+	storage.lastSweep = time.Time{}
+	storage.sweep(time.Now())
+	assert.Empty(t, storage.refresh)
+	assert.Empty(t, storage.revoked)
+}
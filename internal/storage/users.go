@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+)
+
+type UserStorage interface {
+	// Create stores a new user, failing when the username is already taken
+	Create(ctx context.Context, user *models.User) error
+	Save(ctx context.Context, user *models.User) error
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	FindByExternalSubject(ctx context.Context, subject string) (*models.User, error)
+}
+
+type userStorage struct {
+	sync.RWMutex
+	data     map[string]*models.User
+	subjects map[string]string
+}
+
+func NewUserStorage() *userStorage {
+	return &userStorage{data: make(map[string]*models.User), subjects: make(map[string]string)}
+}
+
+func (s *userStorage) Create(ctx context.Context, user *models.User) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := s.data[user.Username]; exists {
+		return ErrUserExists
+	}
+	if _, exists := s.subjects[user.ExternalSubject]; exists && user.ExternalSubject != "" {
+		return ErrUserExists
+	}
+	copied := *user
+	s.data[user.Username] = &copied
+	if user.ExternalSubject != "" {
+		s.subjects[user.ExternalSubject] = user.Username
+	}
+	return nil
+}
+
+func (s *userStorage) Save(ctx context.Context, user *models.User) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := s.data[user.Username]; !exists {
+		return ErrUserNotFound
+	}
+	copied := *user
+	s.data[user.Username] = &copied
+	return nil
+}
+
+// FindByUsername returns a copy of the user so callers can change it without racing other requests
+func (s *userStorage) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	s.RLock()
+	defer s.RUnlock()
+	user, ok := s.data[models.NormalizeUsername(username)]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *userStorage) FindByExternalSubject(ctx context.Context, subject string) (*models.User, error) {
+	s.RLock()
+	username, ok := s.subjects[subject]
+	s.RUnlock()
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return s.FindByUsername(ctx, username)
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dnakolan/trail-data-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserStorage(t *testing.T) {
+	backends := []struct {
+		name    string
+		storage func(t *testing.T) UserStorage
+	}{
+		{
+			name:    "memory",
+			storage: func(t *testing.T) UserStorage { return NewUserStorage() },
+		},
+		{
+			name: "sqlite",
+			storage: func(t *testing.T) UserStorage {
+				db, _ := newTestSQLiteDB(t)
+				return NewSQLiteUserStorage(db)
+			},
+		},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			storage := backend.storage(t)
+			ctx := context.Background()
+
+			user, err := models.NewUser(&models.RegisterUserRequest{Username: "ranger", Password: "correct horse"}, models.RoleContributor)
+			require.NoError(t, err)
+			require.NoError(t, storage.Create(ctx, user))
+
+			duplicate, err := models.NewUser(&models.RegisterUserRequest{Username: "Ranger", Password: "another horse"}, models.RoleContributor)
+			require.NoError(t, err)
+			assert.EqualError(t, storage.Create(ctx, duplicate), "user already exists")
+
+			found, err := storage.FindByUsername(ctx, "RANGER")
+			require.NoError(t, err)
+			assert.Equal(t, user.UID, found.UID)
+			assert.True(t, found.CheckPassword("correct horse"))
+			assert.Equal(t, models.RoleContributor, found.Role)
+			assert.WithinDuration(t, *user.CreatedAt, *found.CreatedAt, time.Millisecond)
+
+			lockedUntil := time.Now().Add(time.Minute).UTC()
+			found.Role = models.RoleModerator
+			found.FailedLogins = 2
+			found.LockedUntil = &lockedUntil
+			require.NoError(t, storage.Save(ctx, found))
+
+			updated, err := storage.FindByUsername(ctx, "ranger")
+			require.NoError(t, err)
+			assert.Equal(t, models.RoleModerator, updated.Role)
+			assert.Equal(t, 2, updated.FailedLogins)
+			assert.True(t, lockedUntil.Equal(*updated.LockedUntil))
+
+			external := &models.User{UID: uuid.New(), Username: "explorer", Role: models.RoleViewer, ExternalSubject: models.ExternalSubject("https://idp.example.com", "user-1")}
+			require.NoError(t, storage.Create(ctx, external))
+			found, err = storage.FindByExternalSubject(ctx, models.ExternalSubject("https://idp.example.com", "user-1"))
+			require.NoError(t, err)
+			assert.Equal(t, "explorer", found.Username)
+			// Another account cannot take the same subject, and local accounts have none to find
+			assert.EqualError(t, storage.Create(ctx, &models.User{UID: uuid.New(), Username: "another", ExternalSubject: external.ExternalSubject}), "user already exists")
+			_, err = storage.FindByExternalSubject(ctx, models.ExternalSubject("https://other.example.com", "user-1"))
+			assert.EqualError(t, err, "user not found")
+			_, err = storage.FindByExternalSubject(ctx, "")
+			assert.EqualError(t, err, "user not found")
+
+			_, err = storage.FindByUsername(ctx, "nobody")
+			assert.EqualError(t, err, "user not found")
+			assert.EqualError(t, storage.Save(ctx, &models.User{Username: "nobody"}), "user not found")
+		})
+	}
+}